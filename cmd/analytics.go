@@ -12,6 +12,7 @@ var (
 	formatFlag        string
 	mediaOnlyFlag     bool
 	duplicatesFlag    bool
+	nearDistFlag      int
 	maxDepthFlag      int
 	includeHiddenFlag bool
 	browseFlag        bool
@@ -44,6 +45,7 @@ and provide insights about media files. Skips common cache/build folders for per
 			IncludeHidden:  includeHiddenFlag,
 			MediaOnly:      mediaOnlyFlag,
 			FindDuplicates: duplicatesFlag,
+			NearDistance:   nearDistFlag,
 			Format:         formatFlag,
 			CreateBrowse:   browseFlag,
 		}
@@ -63,6 +65,7 @@ func init() {
 	analyticsCmd.Flags().StringVar(&formatFlag, "format", "table", "Output format: table, json")
 	analyticsCmd.Flags().BoolVar(&mediaOnlyFlag, "media-only", false, "Focus only on media files analysis")
 	analyticsCmd.Flags().BoolVar(&duplicatesFlag, "duplicates", false, "Include duplicate detection (slower)")
+	analyticsCmd.Flags().IntVar(&nearDistFlag, "near-distance", 0, fmt.Sprintf("Also group images within this perceptual hash distance as near-duplicates with --duplicates, decoding every image (0 = off, %d is a good start)", internal.DefaultNearDistance))
 	analyticsCmd.Flags().IntVar(&maxDepthFlag, "max-depth", 0, "Maximum recursion depth (0 = unlimited)")
 	analyticsCmd.Flags().BoolVar(&includeHiddenFlag, "include-hidden", false, "Include hidden files and folders")
 	analyticsCmd.Flags().BoolVar(&browseFlag, "browse", false, "Create .browse folder with hardlinks organized by type")
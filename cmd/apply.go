@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"anduril/internal"
+	"github.com/spf13/cobra"
+)
+
+var applyJobsFlag int
+
+var applyCmd = &cobra.Command{
+	Use:   "apply [plan-file]",
+	Short: "Carry out an import plan",
+	Long: `Import files exactly as planned by 'anduril import --plan'.
+
+Every file goes to the destination recorded in the plan with the recorded action
+(copy, timestamp suffix, replace or skip), using the plan's library, user, transfer
+and conflict options. A source whose size, modification time or hash changed since
+planning is refused, as is a destination that appeared or a library file to replace
+that changed in the meantime.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		loaded, err := internal.LoadConfig()
+		if err != nil {
+			return err
+		}
+
+		plan, err := internal.ReadImportPlan(args[0])
+		if err != nil {
+			return err
+		}
+		conf, run := plan.Run(loaded)
+		files := run.Files
+
+		if cmd.Flags().Changed("jobs") {
+			conf.Jobs = applyJobsFlag
+		}
+		if conf.Jobs < 1 {
+			conf.Jobs = 1
+		}
+		if conf.Jobs > 1 && internal.NeedsSequentialRead(files) {
+			fmt.Println("Compressed tar archives are read front to back: using 1 job")
+			conf.Jobs = 1
+		}
+
+		// Filesystem checks run against the directory holding an archive
+		sourceDir := plan.InputDir
+		if info, err := os.Stat(sourceDir); err != nil {
+			return fmt.Errorf("planned input %s is no longer available: %w", plan.InputDir, err)
+		} else if !info.IsDir() {
+			sourceDir = filepath.Dir(sourceDir)
+		}
+
+		fmt.Println("Configuration (from plan):")
+		fmt.Printf("  Plan: %s (created %s)\n", args[0], plan.Created)
+		fmt.Printf("  User: %s\n", plan.User)
+		fmt.Printf("  Input: %s\n", plan.InputDir)
+		fmt.Printf("  Library: %s\n", conf.Library)
+		fmt.Printf("  Video Library: %s\n", conf.VideoLib)
+		fmt.Printf("  Hardlinks: %v\n", conf.UseHardlinks)
+		fmt.Printf("  Reflink: %s\n", conf.Reflink)
+		fmt.Printf("  Verify: %s\n", conf.Verify)
+		fmt.Printf("  Move: %v\n", conf.MoveFiles)
+		fmt.Printf("  Mtime: %s\n", conf.Mtime)
+		fmt.Printf("  Write dates: %s\n", writeDatesLabel(conf.WriteDates))
+		fmt.Printf("  On conflict: %s\n", conflictLabel(conf.OnConflict))
+		fmt.Printf("  Jobs: %d\n", conf.Jobs)
+		fmt.Printf("  Hash index: %v\n", conf.UseHashIndex)
+		fmt.Println()
+
+		if skipped := len(plan.Entries) - len(files); skipped > 0 {
+			fmt.Printf("Leaving out %d files the plan could not handle\n", skipped)
+		}
+
+		logger, err := internal.NewLogger("anduril.log")
+		if err != nil {
+			return err
+		}
+		defer logger.Close()
+		defer internal.CloseExifTool()
+		defer internal.CloseArchives()
+		internal.SetArchiveSpoolDir(internal.ArchiveSpoolDir(conf.Library))
+
+		// The index is updated with what the plan imports
+		if conf.UseHashIndex {
+			index, err := internal.OpenHashIndex(conf.Library, false)
+			if err != nil {
+				return err
+			}
+			defer index.Close()
+			run.Index = index
+		}
+
+		// Applied files are remembered so later imports can skip them
+		sources, err := internal.OpenSourceCache(conf.Library, false)
+		if err != nil {
+			return err
+		}
+		defer sources.Close()
+		run.Sources = sources
+
+		if err := checkTransferSupport(conf, sourceDir, false); err != nil {
+			return err
+		}
+		if err := preflight(files, conf, run, false); err != nil {
+			return err
+		}
+
+		if err := processFiles(files, conf, run, plan.User, plan.InputDir, false); err != nil {
+			return fmt.Errorf("failed to apply plan: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	applyCmd.Flags().IntVar(&applyJobsFlag, "jobs", 1, "Number of files to copy in parallel")
+
+	rootCmd.AddCommand(applyCmd)
+}
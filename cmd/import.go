@@ -3,6 +3,9 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"anduril/internal"
@@ -16,18 +19,53 @@ var (
 	dryRunFlag       bool
 	useExifTool      bool
 	useHardlinks     bool
+	jobsFlag         int
+	resumeFlag       string
+	indexFlag        bool
+	noIndexFlag      bool
+	moveFlag         bool
+	reflinkFlag      string
+	verifyFlag       string
+	excludeFlags     []string
+	minSizeFlag      int64
+	minDimensionFlag int
+	tzFlag           string
+	offsetFlags      []string
+	fixExtFlag       bool
+	planFlag         string
+	rescanFlag       bool
+	mtimeFlag        string
+	writeDatesFlag   string
+	onConflictFlag   string
+	nearDupFlag      string
+	nearDistanceFlag int
 )
 
 var importCmd = &cobra.Command{
 	Use:   "import [folder]",
 	Short: "Import media files from folder",
-	Args:  cobra.ExactArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		folder := args[0]
+	Long: `Import media files from folder into the library.
 
-		info, err := os.Stat(folder)
-		if err != nil || !info.IsDir() {
-			return fmt.Errorf("folder does not exist or is not a directory: %s", folder)
+The folder may also be a .zip, .tar or .tar.gz archive, and archives found inside the
+folder are imported too. Their files are read in place without extracting the archive.
+
+Use --resume <session-id> to continue an interrupted import: files already recorded
+in imports/<session-id>/manifest.jsonl are skipped and new events are appended to it.
+
+Sources imported before are remembered in LIBRARY/.anduril/sources.jsonl; unless
+--rescan is given, files whose size, modification time and inode are unchanged since
+then are skipped without being read.
+
+Use --plan plan.json to write what the import would do (hash, date, destination and
+action for every file) without touching the library. Review it, then run
+'anduril apply plan.json' to carry out exactly that plan.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if resumeFlag == "" && len(args) != 1 {
+			return fmt.Errorf("requires a folder argument (or --resume <session-id>)")
+		}
+		if planFlag != "" && (resumeFlag != "" || dryRunFlag) {
+			return fmt.Errorf("--plan cannot be combined with --resume or --dry-run")
 		}
 
 		// Load config
@@ -43,6 +81,110 @@ var importCmd = &cobra.Command{
 		if useHardlinks {
 			conf.UseHardlinks = true
 		}
+		if cmd.Flags().Changed("jobs") {
+			conf.Jobs = jobsFlag
+		}
+		if conf.Jobs < 1 {
+			conf.Jobs = 1
+		}
+		if indexFlag {
+			conf.UseHashIndex = true
+		}
+		if noIndexFlag {
+			conf.UseHashIndex = false
+		}
+		if cmd.Flags().Changed("reflink") {
+			conf.Reflink = reflinkFlag
+		}
+		switch conf.Reflink {
+		case internal.ReflinkNever, internal.ReflinkAlways, internal.ReflinkAuto:
+		default:
+			return fmt.Errorf("invalid reflink mode %q (use always, auto or never)", conf.Reflink)
+		}
+		if conf.Reflink != internal.ReflinkNever && conf.UseHardlinks {
+			return fmt.Errorf("--reflink and --link cannot be combined")
+		}
+		if cmd.Flags().Changed("verify") {
+			conf.Verify = verifyFlag
+		}
+		switch conf.Verify {
+		case internal.VerifyDest, internal.VerifyFull, internal.VerifyNone:
+		default:
+			return fmt.Errorf("invalid verify mode %q (use dest, full or none)", conf.Verify)
+		}
+		if cmd.Flags().Changed("mtime") {
+			conf.Mtime = mtimeFlag
+		}
+		switch conf.Mtime {
+		case internal.MtimeSource, internal.MtimeCapture:
+		default:
+			return fmt.Errorf("invalid mtime mode %q (use source or capture)", conf.Mtime)
+		}
+		if cmd.Flags().Changed("write-dates") {
+			conf.WriteDates = writeDatesFlag
+		}
+		switch conf.WriteDates {
+		case internal.WriteDatesOff, internal.WriteDatesFile, internal.WriteDatesXMP:
+		case "never":
+			conf.WriteDates = internal.WriteDatesOff
+		default:
+			return fmt.Errorf("invalid write-dates mode %q (use file, xmp or never)", conf.WriteDates)
+		}
+		if cmd.Flags().Changed("on-conflict") {
+			conf.OnConflict = onConflictFlag
+		}
+		switch conf.OnConflict {
+		case internal.ConflictSuffix, internal.ConflictSkip, internal.ConflictKeepBest, internal.ConflictReplace, internal.ConflictFail:
+		default:
+			return fmt.Errorf("invalid on-conflict policy %q (use suffix, skip, keep-best, replace or fail)", conf.OnConflict)
+		}
+		if cmd.Flags().Changed("near-duplicates") {
+			conf.NearDuplicates.Policy = nearDupFlag
+			if nearDupFlag == "off" {
+				conf.NearDuplicates.Policy = internal.NearDuplicatesOff
+			}
+		}
+		if cmd.Flags().Changed("near-distance") {
+			conf.NearDuplicates.Distance = nearDistanceFlag
+		}
+		if err := conf.NearDuplicates.Validate(); err != nil {
+			return err
+		}
+		if moveFlag {
+			if conf.UseHardlinks {
+				return fmt.Errorf("--move and --link cannot be combined")
+			}
+			if conf.Verify == internal.VerifyNone {
+				return fmt.Errorf("--move deletes sources and needs --verify=dest or full")
+			}
+			conf.MoveFiles = true
+		}
+		if fixExtFlag {
+			conf.Naming.FixExtension = true
+		}
+		conf.Scan.Exclude = append(conf.Scan.Exclude, excludeFlags...)
+		if cmd.Flags().Changed("min-size") {
+			conf.Scan.MinSize = minSizeFlag
+		}
+		if cmd.Flags().Changed("min-dimension") {
+			conf.Scan.MinDimension = minDimensionFlag
+		}
+		if err := conf.Scan.Validate(); err != nil {
+			return err
+		}
+		for _, value := range offsetFlags {
+			rule, err := internal.ParseClockOffsetFlag(value)
+			if err != nil {
+				return err
+			}
+			// Command line rules take precedence over the config file
+			conf.ClockOffsets = append([]internal.ClockOffset{rule}, conf.ClockOffsets...)
+		}
+		if cmd.Flags().Changed("tz") {
+			if err := conf.SetTimezone(tzFlag); err != nil {
+				return err
+			}
+		}
 
 		// Determine user and library
 		user := userFlag
@@ -58,6 +200,45 @@ var importCmd = &cobra.Command{
 			videolibrary = conf.VideoLib
 		}
 
+		// Resuming takes user, input folder and video library from the original session
+		var resumed *internal.ImportSession
+		if resumeFlag != "" {
+			if library == "" {
+				return fmt.Errorf("missing --library and no default set")
+			}
+			resumed, err = internal.ResumeImportSession(library, resumeFlag)
+			if err != nil {
+				return fmt.Errorf("failed to resume session %s: %w", resumeFlag, err)
+			}
+			defer resumed.Close()
+
+			if len(args) == 1 {
+				argAbs, err := filepath.Abs(args[0])
+				if err != nil || argAbs != resumed.InputDirAbs {
+					return fmt.Errorf("session %s imported %s, not %s", resumed.ID, resumed.InputDirAbs, args[0])
+				}
+			}
+			if userFlag != "" && userFlag != resumed.User {
+				return fmt.Errorf("session %s was started for user %s, not %s", resumed.ID, resumed.User, userFlag)
+			}
+			user = resumed.User
+			if resumed.VideoLibraryPath != "" {
+				videolibrary = resumed.VideoLibraryPath
+			}
+			args = []string{resumed.InputDirAbs}
+		}
+
+		folder := args[0]
+		info, err := os.Stat(folder)
+		if err != nil || (!info.IsDir() && !internal.IsArchive(folder)) {
+			return fmt.Errorf("folder does not exist or is not a directory or archive: %s", folder)
+		}
+		// Filesystem checks run against the directory holding an archive
+		sourceDir := folder
+		if !info.IsDir() {
+			sourceDir = filepath.Dir(folder)
+		}
+
 		if user == "" || library == "" {
 			return fmt.Errorf("missing --user or --library and no defaults set")
 		}
@@ -73,6 +254,25 @@ var importCmd = &cobra.Command{
 		fmt.Printf("  Video Library: %s\n", videolibrary)
 		fmt.Printf("  ExifTool: %v\n", conf.UseExifTool)
 		fmt.Printf("  Hardlinks: %v\n", conf.UseHardlinks)
+		fmt.Printf("  Reflink: %s\n", conf.Reflink)
+		fmt.Printf("  Verify: %s\n", conf.Verify)
+		fmt.Printf("  Move: %v\n", conf.MoveFiles)
+		fmt.Printf("  Mtime: %s\n", conf.Mtime)
+		fmt.Printf("  Write dates: %s\n", writeDatesLabel(conf.WriteDates))
+		fmt.Printf("  On conflict: %s\n", conflictLabel(conf.OnConflict))
+		fmt.Printf("  Jobs: %d\n", conf.Jobs)
+		fmt.Printf("  Timezone: %s\n", conf.Timezone)
+		for _, rule := range conf.ClockOffsets {
+			fmt.Printf("  Clock offset: %s\n", rule)
+		}
+		for _, rule := range conf.UserRules {
+			fmt.Printf("  User rule: %s → %s\n", rule, rule.User)
+		}
+		fmt.Printf("  Fix extensions: %v\n", conf.Naming.FixExtension)
+		fmt.Printf("  Hash index: %v\n", conf.UseHashIndex)
+		if conf.NearDuplicates.Policy != internal.NearDuplicatesOff {
+			fmt.Printf("  Near-duplicates: %s (distance %d)\n", conf.NearDuplicates.Policy, conf.NearDuplicates.Distance)
+		}
 		fmt.Println()
 
 		logger, err := internal.NewLogger("anduril.log")
@@ -81,36 +281,106 @@ var importCmd = &cobra.Command{
 		}
 		defer logger.Close()
 		defer internal.CloseExifTool() // Ensure ExifTool cleanup
+		defer internal.CloseArchives()
+		internal.SetArchiveSpoolDir(internal.ArchiveSpoolDir(conf.Library))
+
+		// Open the library hash index so content already in the library is skipped
+		run := &internal.ImportRun{}
+		if conf.UseHashIndex {
+			index, err := internal.OpenHashIndex(library, dryRunFlag || planFlag != "")
+			if err != nil {
+				return err
+			}
+			defer index.Close()
+			run.Index = index
+			if index.Len() == 0 {
+				fmt.Println("Hash index is empty - run 'anduril index rebuild' to include existing library files")
+			}
+		}
+
+		// Open the perceptual index so recompressed copies of library images are found.
+		// Plans only deduplicate by content.
+		if conf.NearDuplicates.Policy != internal.NearDuplicatesOff && planFlag == "" {
+			dhashes, err := internal.OpenPerceptualIndex(library, dryRunFlag)
+			if err != nil {
+				return err
+			}
+			defer dhashes.Close()
+			run.DHashes = dhashes
+			if dhashes.Len() == 0 {
+				fmt.Println("Perceptual index is empty - run 'anduril index rebuild --perceptual' to include existing library images")
+			}
+		}
+
+		// Open the source cache so files imported by earlier runs are not read again
+		sources, err := internal.OpenSourceCache(library, dryRunFlag || planFlag != "")
+		if err != nil {
+			return err
+		}
+		defer sources.Close()
+		run.Sources = sources
 
 		// Scan media files using config
-		files, err := internal.ScanMediaFiles(folder, conf)
+		scan, err := internal.ScanMediaFiles(folder, conf)
 		if err != nil {
 			return err
 		}
+		run.ScanResult = *scan
 
-		fmt.Printf("Found %d media files\n", len(files))
+		fmt.Printf("Found %d media files\n", len(run.Files))
+		if !rescanFlag {
+			run.SkipUnchangedSources()
+			if run.Unchanged > 0 {
+				fmt.Printf("Skipping %d unchanged files imported before (use --rescan to check them again)\n", run.Unchanged)
+			}
+		}
+		files := run.Files
+		if len(run.Excluded) > 0 {
+			fmt.Printf("Excluded %d files and folders by scan rules (thumbnails, caches, --exclude, size limits)\n", len(run.Excluded))
+		}
+		if sidecars := countSidecars(run); sidecars > 0 {
+			fmt.Printf("Found %d sidecar files (.xmp, .aae, ...) to keep with their media\n", sidecars)
+		}
+		if archived := countArchiveEntries(files); archived > 0 {
+			fmt.Printf("%d of them are inside archives and will be copied (archives are never linked, moved or deleted)\n", archived)
+			if conf.Jobs > 1 && internal.NeedsSequentialRead(files) {
+				fmt.Println("Compressed tar archives are read front to back: using 1 job")
+				conf.Jobs = 1
+			}
+		}
+		if planFlag != "" {
+			return writePlan(conf, run, user, planFlag)
+		}
 		if dryRunFlag {
 			fmt.Println("Dry run mode: no files will be copied")
 		}
 
-		// Test hardlink support before starting (if --link is used)
-		if conf.UseHardlinks {
-			fmt.Println("Testing hardlink support...")
-			// Test against image library
-			if err := internal.TestHardlinkSupport(folder, library); err != nil {
-				return err
-			}
-			// Test against video library if different
-			if videolibrary != "" && videolibrary != library {
-				if err := internal.TestHardlinkSupport(folder, videolibrary); err != nil {
-					return err
+		if err := checkTransferSupport(conf, sourceDir, dryRunFlag); err != nil {
+			return err
+		}
+
+		// Files a resumed session already imported need no space
+		pending := files
+		if resumed != nil {
+			pending = nil
+			for _, f := range files {
+				if !resumed.IsCompleted(f) {
+					pending = append(pending, f)
 				}
 			}
-			fmt.Println("Hardlink support: OK")
+		}
+		if err := preflight(pending, conf, run, dryRunFlag); err != nil {
+			return err
 		}
 
-		// Process files sequentially with progress reporting
-		if err := processFiles(files, conf, user, folder, dryRunFlag); err != nil {
+		// Process files on the worker pool with progress reporting
+		if resumed != nil {
+			if err := resumeFiles(files, conf, run, resumed, dryRunFlag); err != nil {
+				return fmt.Errorf("failed to process files: %w", err)
+			}
+			return nil
+		}
+		if err := processFiles(files, conf, run, user, folder, dryRunFlag); err != nil {
 			return fmt.Errorf("failed to process files: %w", err)
 		}
 
@@ -118,13 +388,120 @@ var importCmd = &cobra.Command{
 	},
 }
 
-// processFiles processes files sequentially with progress reporting
-func processFiles(files []string, conf *internal.Config, user, inputDir string, dryRun bool) error {
-	total := len(files)
-	startTime := time.Now()
-	errorStats := internal.NewErrorStats()
-	successCount := 0
+// writePlan plans the import of the scanned files and saves the plan for 'anduril apply'
+func writePlan(conf *internal.Config, run *internal.ImportRun, user, planPath string) error {
+	plan, err := internal.BuildImportPlan(conf, run, user)
+	if err != nil {
+		return err
+	}
+	if err := internal.WriteImportPlan(plan, planPath); err != nil {
+		return err
+	}
+
+	counts := plan.Counts()
+	fmt.Printf("\nImport Plan (%d files):\n", len(plan.Entries))
+	fmt.Printf("  ✓ Copy:              %d files\n", counts[internal.PlanCopy])
+	if n := counts[internal.PlanTimestampSuffix]; n > 0 {
+		fmt.Printf("  ✓ Timestamp suffix:  %d files\n", n)
+	}
+	if n := counts[internal.PlanReplace]; n > 0 {
+		fmt.Printf("  ⇄ Replace:           %d files (replaced files kept in superseded/)\n", n)
+	}
+	if n := counts[internal.PlanSkipDuplicate]; n > 0 {
+		fmt.Printf("  ⊘ Skip (duplicates): %d files\n", n)
+	}
+	if n := counts[internal.PlanSkipConflict]; n > 0 {
+		fmt.Printf("  ⊘ Skip (conflicts):  %d files\n", n)
+	}
+	if n := counts[internal.PlanError]; n > 0 {
+		fmt.Printf("  ✗ Errors:            %d files (left out of the import)\n", n)
+	}
+	fmt.Printf("\n📝 Plan written to %s - review it, then run 'anduril apply %s'\n", planPath, planPath)
+	return nil
+}
+
+// checkTransferSupport tests that --link and --reflink work between sourceDir and the
+// libraries before any file is imported. Reflink=auto falls back to copying.
+func checkTransferSupport(conf *internal.Config, sourceDir string, dryRun bool) error {
+	library, videolibrary := conf.Library, conf.VideoLib
+
+	// Test hardlink support before starting (if --link is used)
+	if conf.UseHardlinks {
+		fmt.Println("Testing hardlink support...")
+		// Test against image library
+		if err := internal.TestHardlinkSupport(sourceDir, library); err != nil {
+			return err
+		}
+		// Test against video library if different
+		if videolibrary != "" && videolibrary != library {
+			if err := internal.TestHardlinkSupport(sourceDir, videolibrary); err != nil {
+				return err
+			}
+		}
+		fmt.Println("Hardlink support: OK")
+	}
+
+	// Test reflink support before starting (if --reflink is used)
+	if conf.Reflink != internal.ReflinkNever && !dryRun {
+		fmt.Println("Testing reflink support...")
+		targets := []string{library}
+		if videolibrary != "" && videolibrary != library {
+			targets = append(targets, videolibrary)
+		}
+		for _, target := range targets {
+			err := internal.TestReflinkSupport(sourceDir, target)
+			if err == nil {
+				continue
+			}
+			if conf.Reflink == internal.ReflinkAlways {
+				fmt.Printf("\n❌ Reflink Error: %v\n", err)
+				fmt.Println("   Reflinks need a copy-on-write filesystem (btrfs, XFS, bcachefs) with source and library on the same filesystem.")
+				fmt.Println("   Use --reflink=auto to fall back to copying, or omit --reflink.")
+				return err
+			}
+			fmt.Printf("Reflink not available (%v), copying instead\n", err)
+			conf.Reflink = internal.ReflinkNever
+			break
+		}
+		if conf.Reflink != internal.ReflinkNever {
+			fmt.Println("Reflink support: OK")
+		}
+	}
+	return nil
+}
+
+// preflight checks the libraries have room for files and can be written to, and
+// aborts with a report before anything is copied. Dry runs only report.
+func preflight(files []string, conf *internal.Config, run *internal.ImportRun, dryRun bool) error {
+	fmt.Println("Checking free space and permissions...")
+	report, err := internal.PreflightImport(files, conf, run, !dryRun)
+	if err != nil {
+		return err
+	}
+	fmt.Print(report.Report())
+	if report.OK() {
+		fmt.Println("Preflight: OK")
+		fmt.Println()
+		return nil
+	}
+	if dryRun {
+		fmt.Println("Preflight: this import would be aborted")
+		fmt.Println()
+		return nil
+	}
+	fmt.Println("\n❌ Preflight failed: nothing was copied")
+	fmt.Println("   Free up space, choose another --library, or check that you can write to the library.")
+	return fmt.Errorf("import preflight failed: %s", strings.Join(report.Problems, "; "))
+}
+
+// fileResult carries the outcome of one ProcessFile call back to the coordinator
+type fileResult struct {
+	path string
+	err  error
+}
 
+// processFiles starts a new import session (unless dry-run) and imports files into it
+func processFiles(files []string, conf *internal.Config, run *internal.ImportRun, user, inputDir string, dryRun bool) error {
 	// Create import session (unless dry-run)
 	var session *internal.ImportSession
 	if !dryRun {
@@ -136,40 +513,139 @@ func processFiles(files []string, conf *internal.Config, user, inputDir string,
 		defer session.Close()
 
 		// Log session start
-		if err := session.LogSessionStart(total); err != nil {
+		if err := session.LogSessionStart(len(files)); err != nil {
 			return fmt.Errorf("failed to log session start: %w", err)
 		}
+		if err := session.LogExcluded(run.Excluded, conf.Scan.LogExcluded); err != nil {
+			return fmt.Errorf("failed to log excluded files: %w", err)
+		}
+		session.LogSkippedUnchanged(run.Unchanged)
 
 		fmt.Printf("Import session: %s\n", session.ID)
 		fmt.Printf("Browse imported files: %s\n\n", session.SessionDir)
 	}
 
-	for i, filePath := range files {
-		if err := internal.ProcessFile(filePath, conf, user, dryRun, session); err != nil {
+	return runImport(files, len(files)+run.Unchanged, conf, run, user, dryRun, session)
+}
+
+// resumeFiles continues an interrupted session, skipping sources its manifest already
+// records as imported. The session is closed by the caller.
+func resumeFiles(files []string, conf *internal.Config, run *internal.ImportRun, session *internal.ImportSession, dryRun bool) error {
+	var pending []string
+	for _, filePath := range files {
+		if !session.IsCompleted(filePath) {
+			pending = append(pending, filePath)
+		}
+	}
+	done := len(files) - len(pending)
+
+	fmt.Printf("Resuming session %s: %d already imported, %d remaining\n", session.ID, done, len(pending))
+
+	user := session.User
+	if dryRun {
+		// Nothing is written to the manifest in dry-run mode
+		return runImport(pending, len(files), conf, run, user, dryRun, nil)
+	}
+
+	if err := session.LogSessionResume(len(pending), done); err != nil {
+		return fmt.Errorf("failed to log session resume: %w", err)
+	}
+	// Excluded files were already logged when the session started
+	session.LogExcluded(run.Excluded, false)
+	session.LogSkippedUnchanged(run.Unchanged)
+	fmt.Printf("Browse imported files: %s\n\n", session.SessionDir)
+
+	return runImport(pending, len(files), conf, run, user, dryRun, session)
+}
+
+// runImport processes files on a bounded worker pool (conf.Jobs workers) with progress reporting.
+// Error accounting and abort decisions happen on the calling goroutine only.
+// totalScanned is recorded in session_end and may exceed len(files) when resuming.
+func runImport(files []string, totalScanned int, conf *internal.Config, run *internal.ImportRun, user string, dryRun bool, session *internal.ImportSession) error {
+	total := len(files)
+	startTime := time.Now()
+	errorStats := internal.NewErrorStats()
+	successCount := 0
+
+	jobs := conf.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	// An asset group goes to one worker, lead first, so its members can follow the lead
+	work := make(chan []string)
+	results := make(chan fileResult)
+	stop := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range work {
+				for _, filePath := range batch {
+					err := internal.ProcessFile(filePath, conf, run, user, dryRun, session)
+					results <- fileResult{path: filePath, err: err}
+				}
+			}
+		}()
+	}
+
+	// Feed workers until all files are dispatched or an abort is requested
+	go func() {
+		defer close(work)
+		for _, batch := range run.Batches(files) {
+			select {
+			case work <- batch:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var abortErr error
+	processed := 0
+	for res := range results {
+		processed++
+
+		// After an abort, only drain files that were already in flight
+		if abortErr != nil {
+			continue
+		}
+
+		if res.err != nil {
 			// Categorize the error
-			procErr := internal.CategorizeError(filePath, err)
+			procErr := internal.CategorizeError(res.path, res.err)
 			errorStats.Add(procErr)
 			errorStats.Consecutive++
 
 			// Log detailed error to session
 			if session != nil {
-				session.LogDetailedError(filePath, procErr)
+				session.LogDetailedError(res.path, procErr)
 			}
 
 			// Check if we should abort
 			if shouldAbort, reason := errorStats.ShouldAbort(); shouldAbort {
 				fmt.Printf("\n⚠️  ABORTING IMPORT: %s\n", reason)
-				fmt.Printf("Processed: %d/%d files before abort\n", i+1, total)
-				return fmt.Errorf("import aborted: %s", reason)
+				fmt.Printf("Processed: %d/%d files before abort\n", processed, total)
+				abortErr = fmt.Errorf("import aborted: %s", reason)
+				close(stop)
+				continue
 			}
 
 			// Check error rate threshold (50% errors with at least 20 files processed)
-			processed := i + 1
 			if processed >= 20 && errorStats.Total > processed/2 {
 				fmt.Printf("\n⚠️  ABORTING IMPORT: Error rate too high (%d/%d = %.1f%%)\n",
 					errorStats.Total, processed, float64(errorStats.Total)/float64(processed)*100)
 				fmt.Printf("This suggests a systemic problem - check system resources and permissions\n")
-				return fmt.Errorf("import aborted: error rate exceeds 50%%")
+				abortErr = fmt.Errorf("import aborted: error rate exceeds 50%%")
+				close(stop)
+				continue
 			}
 		} else {
 			// Success - reset consecutive error counter
@@ -178,7 +654,6 @@ func processFiles(files []string, conf *internal.Config, user, inputDir string,
 		}
 
 		// Update progress every 10 files or at the end
-		processed := i + 1
 		if processed%10 == 0 || processed == total {
 			elapsed := time.Since(startTime)
 			rate := float64(processed) / elapsed.Seconds()
@@ -199,10 +674,28 @@ func processFiles(files []string, conf *internal.Config, user, inputDir string,
 		}
 	}
 
+	if abortErr != nil {
+		if conf.MoveFiles && session != nil {
+			fmt.Printf("Move mode: queued source deletions were cancelled because the import aborted (--resume %s completes them)\n", session.ID)
+		}
+		return abortErr
+	}
+
+	// Move mode: delete sources only now that the whole import ran without aborting
+	if session != nil && conf.MoveFiles {
+		removed, kept := session.RemoveQueuedSources()
+		for _, src := range kept {
+			fmt.Printf("Warning: kept source %s (changed, or library copy missing or different)\n", src)
+		}
+		if removed > 0 {
+			fmt.Printf("Removed %d verified source files\n", removed)
+		}
+	}
+
 	// Log session end
 	if session != nil {
 		stats := session.GetStats()
-		stats.TotalScanned = total
+		stats.TotalScanned = totalScanned
 		if err := session.LogSessionEnd(stats); err != nil {
 			fmt.Printf("Warning: failed to log session end: %v\n", err)
 		}
@@ -223,6 +716,27 @@ func processFiles(files []string, conf *internal.Config, user, inputDir string,
 		if stats.SkippedDuplicate > 0 {
 			fmt.Printf("  ⊘ Skipped (duplicates): %d files\n", stats.SkippedDuplicate)
 		}
+		if stats.Conflicts > 0 {
+			fmt.Printf("  ⇄ Conflicts:         %d files (%d replaced, %d kept existing)\n", stats.Conflicts, stats.Replaced, stats.SkippedConflict)
+		}
+		if stats.Sidecars > 0 {
+			fmt.Printf("  📎 Sidecars:          %d files\n", stats.Sidecars)
+		}
+		if stats.NearDuplicates > 0 {
+			fmt.Printf("  ≈ Near-duplicates:   %d files (%d skipped)\n", stats.NearDuplicates, stats.SkippedNear)
+		}
+		if stats.DatesWritten > 0 {
+			fmt.Printf("  🗓 Dates written:     %d files\n", stats.DatesWritten)
+		}
+		if stats.SourcesRemoved > 0 {
+			fmt.Printf("  ✂ Sources removed:   %d files\n", stats.SourcesRemoved)
+		}
+		if stats.SkippedUnchanged > 0 {
+			fmt.Printf("  ⊘ Skipped (unchanged): %d files\n", stats.SkippedUnchanged)
+		}
+		if stats.Excluded > 0 {
+			fmt.Printf("  🚫 Excluded:          %d files\n", stats.Excluded)
+		}
 		if errorStats.Total > 0 {
 			fmt.Printf("  ✗ Errors:            %d files\n", errorStats.Total)
 		}
@@ -239,6 +753,45 @@ func processFiles(files []string, conf *internal.Config, user, inputDir string,
 	return nil
 }
 
+// countSidecars returns how many sidecar files the scan associated with media files
+func countSidecars(run *internal.ImportRun) int {
+	seen := make(map[string]bool)
+	for _, sidecars := range run.Sidecars {
+		for _, sidecar := range sidecars {
+			seen[sidecar] = true
+		}
+	}
+	return len(seen)
+}
+
+// countArchiveEntries returns how many of files are read from inside an archive
+func countArchiveEntries(files []string) int {
+	n := 0
+	for _, f := range files {
+		if internal.IsArchiveEntry(f) {
+			n++
+		}
+	}
+	return n
+}
+
+// conflictLabel describes a conflict policy for the configuration printout; plans made
+// before policies existed carry none and suffix
+func conflictLabel(policy string) string {
+	if policy == "" {
+		return internal.ConflictSuffix
+	}
+	return policy
+}
+
+// writeDatesLabel describes a date write-back mode for the configuration printout
+func writeDatesLabel(mode string) string {
+	if mode == internal.WriteDatesOff {
+		return "never"
+	}
+	return mode
+}
+
 func init() {
 	importCmd.Flags().StringVar(&userFlag, "user", "", "User folder under library")
 	importCmd.Flags().StringVar(&libraryFlag, "library", "", "Root library folder")
@@ -246,6 +799,29 @@ func init() {
 	importCmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "Show files without copying")
 	importCmd.Flags().BoolVar(&useExifTool, "exiftool", false, "Force to use exiftool binary")
 	importCmd.Flags().BoolVar(&useHardlinks, "link", false, "Use hardlinks instead of copying (instant, no extra space)")
+	importCmd.Flags().StringVar(&reflinkFlag, "reflink", "", "Copy-on-write clone instead of copying: always (default when given bare), auto or never")
+	importCmd.Flags().Lookup("reflink").NoOptDefVal = internal.ReflinkAlways
+	importCmd.Flags().StringVar(&verifyFlag, "verify", internal.VerifyDest, "Copy verification: dest (re-read copy), full (also re-read source) or none")
+	importCmd.Flags().StringVar(&mtimeFlag, "mtime", internal.MtimeSource, "Modification time of library files: source (preserved) or capture (the detected capture date)")
+	importCmd.Flags().StringVar(&writeDatesFlag, "write-dates", "", "Write Takeout and file name dates back: file (into the library copy, the default when given bare), xmp (sidecar) or never")
+	importCmd.Flags().Lookup("write-dates").NoOptDefVal = internal.WriteDatesFile
+	importCmd.Flags().StringVar(&onConflictFlag, "on-conflict", internal.ConflictSuffix, "When the destination holds a different file: suffix (keep both), skip, keep-best (higher quality wins), replace or fail; replaced files go to superseded/")
+	importCmd.Flags().StringVar(&nearDupFlag, "near-duplicates", "", "Find recompressed or resized copies of library images: flag (log them, the default when given bare), skip (lower-quality ones) or off")
+	importCmd.Flags().Lookup("near-duplicates").NoOptDefVal = internal.NearDuplicatesFlag
+	importCmd.Flags().IntVar(&nearDistanceFlag, "near-distance", internal.DefaultNearDistance, "Largest perceptual hash distance (of 64 bits) counted as a near-duplicate")
+	importCmd.Flags().StringVar(&resumeFlag, "resume", "", "Resume an interrupted import session by ID (see imports/<id>)")
+	importCmd.Flags().BoolVar(&moveFlag, "move", false, "Delete sources after verified import (rename when on the same filesystem)")
+	importCmd.Flags().BoolVar(&rescanFlag, "rescan", false, "Check every source again, even those unchanged since an earlier import")
+	importCmd.Flags().BoolVar(&indexFlag, "index", false, "Consult and update the library hash index (hashes each source before copying it)")
+	importCmd.Flags().BoolVar(&noIndexFlag, "no-index", false, "Do not consult or update the library hash index")
+	importCmd.Flags().IntVar(&jobsFlag, "jobs", 1, "Number of files to hash, date and copy in parallel")
+	importCmd.Flags().StringArrayVar(&excludeFlags, "exclude", nil, "Glob of files or folders to skip, e.g. '@eaDir' or 'DCIM/.thumbnails' (repeatable)")
+	importCmd.Flags().Int64Var(&minSizeFlag, "min-size", 0, "Skip media files smaller than this many bytes")
+	importCmd.Flags().IntVar(&minDimensionFlag, "min-dimension", 0, "Skip images whose width or height is below this many pixels")
+	importCmd.Flags().StringArrayVar(&offsetFlags, "offset", nil, "Shift EXIF dates of one camera, e.g. 'Canon/Canon EOS 80D=+2h' or 'Make/Model/Serial=-1y3d' (repeatable)")
+	importCmd.Flags().BoolVar(&fixExtFlag, "fix-extensions", false, "Rename files whose content disagrees with their extension (e.g. HEIC saved as .jpg)")
+	importCmd.Flags().StringVar(&planFlag, "plan", "", "Write the import plan to this file instead of importing (see 'anduril apply')")
+	importCmd.Flags().StringVar(&tzFlag, "tz", "", "Timezone for day folders, e.g. Europe/Rome (default: timezone from config, or the system's)")
 
 	rootCmd.AddCommand(importCmd)
 }
@@ -44,17 +44,19 @@ func TestImport_WithSession(t *testing.T) {
 	}
 
 	// Scan media files
-	files, err := internal.ScanMediaFiles(inputDir, conf)
+	scan, err := internal.ScanMediaFiles(inputDir, conf)
 	if err != nil {
 		t.Fatalf("ScanMediaFiles failed: %v", err)
 	}
+	run := internal.NewImportRun(scan)
+	files := run.Files
 
 	if len(files) != 3 {
 		t.Fatalf("Expected 3 files, got %d", len(files))
 	}
 
 	// Process files with session
-	err = processFiles(files, conf, conf.User, inputDir, false)
+	err = processFiles(files, conf, run, conf.User, inputDir, false)
 	if err != nil {
 		t.Fatalf("processFiles failed: %v", err)
 	}
@@ -161,13 +163,15 @@ func TestImport_DryRunSkipsSession(t *testing.T) {
 	}
 
 	// Scan media files
-	files, err := internal.ScanMediaFiles(inputDir, conf)
+	scan, err := internal.ScanMediaFiles(inputDir, conf)
 	if err != nil {
 		t.Fatalf("ScanMediaFiles failed: %v", err)
 	}
+	run := internal.NewImportRun(scan)
+	files := run.Files
 
 	// Process files with DRY RUN
-	err = processFiles(files, conf, conf.User, inputDir, true)
+	err = processFiles(files, conf, run, conf.User, inputDir, true)
 	if err != nil {
 		t.Fatalf("processFiles failed: %v", err)
 	}
@@ -210,3 +214,287 @@ func TestImport_SessionIDFormat(t *testing.T) {
 
 	t.Logf("Session ID format test passed: %s", session.ID)
 }
+
+func TestImport_ParallelWorkersResolveDuplicates(t *testing.T) {
+	tempDir := t.TempDir()
+	inputDir := filepath.Join(tempDir, "input")
+	libraryDir := filepath.Join(tempDir, "library")
+
+	fileTime := time.Date(2024, 5, 4, 10, 0, 0, 0, time.UTC)
+
+	// Every source maps to the same destination: half share content, half differ
+	for i := 0; i < 16; i++ {
+		dir := filepath.Join(inputDir, fmt.Sprintf("dir%02d", i))
+		os.MkdirAll(dir, 0755)
+		path := filepath.Join(dir, "photo.jpg")
+		content := "same content"
+		if i%2 == 1 {
+			content = fmt.Sprintf("unique content %d", i)
+		}
+		os.WriteFile(path, []byte(content), 0644)
+		_ = os.Chtimes(path, fileTime, fileTime)
+	}
+
+	conf := &internal.Config{
+		User:     "testuser",
+		Library:  libraryDir,
+		ImageExt: []string{".jpg"},
+		VideoExt: []string{".mp4"},
+		Jobs:     8,
+	}
+
+	scan, err := internal.ScanMediaFiles(inputDir, conf)
+	if err != nil {
+		t.Fatalf("ScanMediaFiles failed: %v", err)
+	}
+	run := internal.NewImportRun(scan)
+	files := run.Files
+
+	if err := processFiles(files, conf, run, conf.User, inputDir, false); err != nil {
+		t.Fatalf("processFiles failed: %v", err)
+	}
+
+	destDir := filepath.Join(libraryDir, "testuser", "noexif", "2024-05")
+	entries, err := os.ReadDir(destDir)
+	if err != nil {
+		t.Fatalf("Failed to read destination: %v", err)
+	}
+
+	// One copy of the shared content plus 8 unique files, each with its own name
+	if len(entries) != 9 {
+		names := []string{}
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Fatalf("Expected 9 files in %s, got %d: %v", destDir, len(entries), names)
+	}
+
+	hashes := make(map[string]string)
+	for _, e := range entries {
+		hash, err := internal.FileHash(filepath.Join(destDir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other, ok := hashes[hash]; ok {
+			t.Errorf("Duplicate content stored twice: %s and %s", other, e.Name())
+		}
+		hashes[hash] = e.Name()
+	}
+}
+
+func TestImport_ResumeSkipsCompletedSources(t *testing.T) {
+	tempDir := t.TempDir()
+	inputDir := filepath.Join(tempDir, "input")
+	libraryDir := filepath.Join(tempDir, "library")
+	os.MkdirAll(inputDir, 0755)
+
+	fileTime := time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		path := filepath.Join(inputDir, fmt.Sprintf("photo%d.jpg", i))
+		os.WriteFile(path, []byte(fmt.Sprintf("data %d", i)), 0644)
+		_ = os.Chtimes(path, fileTime, fileTime)
+	}
+
+	conf := &internal.Config{
+		User:     "testuser",
+		Library:  libraryDir,
+		ImageExt: []string{".jpg"},
+		VideoExt: []string{".mp4"},
+	}
+
+	scan, err := internal.ScanMediaFiles(inputDir, conf)
+	if err != nil {
+		t.Fatalf("ScanMediaFiles failed: %v", err)
+	}
+	run := internal.NewImportRun(scan)
+	files := run.Files
+
+	// Simulate an import that died after the first file
+	session, err := internal.NewImportSession(libraryDir, "", conf.User, inputDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	session.LogSessionStart(len(files))
+	if err := internal.ProcessFile(files[0], conf, run, conf.User, false, session, true); err != nil {
+		t.Fatalf("ProcessFile failed: %v", err)
+	}
+	session.Close()
+
+	resumed, err := internal.ResumeImportSession(libraryDir, session.ID)
+	if err != nil {
+		t.Fatalf("ResumeImportSession failed: %v", err)
+	}
+	defer resumed.Close()
+
+	rescanned, err := internal.ScanMediaFiles(resumed.InputDirAbs, conf)
+	if err != nil {
+		t.Fatalf("ScanMediaFiles failed: %v", err)
+	}
+	if err := resumeFiles(rescanned.Files, conf, internal.NewImportRun(rescanned), resumed, false); err != nil {
+		t.Fatalf("resumeFiles failed: %v", err)
+	}
+
+	events, err := internal.ReadManifest(filepath.Join(resumed.SessionDir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+
+	counts := make(map[string]int)
+	for _, e := range events {
+		counts[e.Event]++
+	}
+	if counts["copied"] != 3 {
+		t.Errorf("Expected 3 copied events across both runs, got %d", counts["copied"])
+	}
+	if counts["skipped_duplicate"] != 0 {
+		t.Errorf("Completed source was reprocessed: %d skipped_duplicate events", counts["skipped_duplicate"])
+	}
+	if counts["session_resume"] != 1 || counts["session_end"] != 1 {
+		t.Errorf("Expected one session_resume and one session_end, got %v", counts)
+	}
+
+	last := events[len(events)-1]
+	if last.Event != "session_end" || last.Copied != 3 || last.TotalScanned != 3 {
+		t.Errorf("Unexpected session_end: %+v", last)
+	}
+}
+
+func TestImport_MoveRemovesVerifiedSources(t *testing.T) {
+	tempDir := t.TempDir()
+	inputDir := filepath.Join(tempDir, "input")
+	libraryDir := filepath.Join(tempDir, "library")
+	os.MkdirAll(inputDir, 0755)
+
+	fileTime := time.Date(2024, 8, 2, 9, 0, 0, 0, time.UTC)
+	newSrc := filepath.Join(inputDir, "new.jpg")
+	dupSrc := filepath.Join(inputDir, "dup.jpg")
+	os.WriteFile(newSrc, []byte("new photo"), 0644)
+	os.WriteFile(dupSrc, []byte("already imported"), 0644)
+	_ = os.Chtimes(newSrc, fileTime, fileTime)
+	_ = os.Chtimes(dupSrc, fileTime, fileTime)
+
+	// The duplicate already sits at its destination with identical content
+	destDir := filepath.Join(libraryDir, "testuser", "noexif", "2024-08")
+	os.MkdirAll(destDir, 0755)
+	os.WriteFile(filepath.Join(destDir, "dup.jpg"), []byte("already imported"), 0644)
+
+	conf := &internal.Config{
+		User:      "testuser",
+		Library:   libraryDir,
+		ImageExt:  []string{".jpg"},
+		VideoExt:  []string{".mp4"},
+		MoveFiles: true,
+	}
+
+	scan, err := internal.ScanMediaFiles(inputDir, conf)
+	if err != nil {
+		t.Fatalf("ScanMediaFiles failed: %v", err)
+	}
+	run := internal.NewImportRun(scan)
+	files := run.Files
+	if err := processFiles(files, conf, run, conf.User, inputDir, false); err != nil {
+		t.Fatalf("processFiles failed: %v", err)
+	}
+
+	for _, src := range []string{newSrc, dupSrc} {
+		if _, err := os.Stat(src); !os.IsNotExist(err) {
+			t.Errorf("Expected source %s to be removed", src)
+		}
+	}
+	for _, name := range []string{"new.jpg", "dup.jpg"} {
+		if _, err := os.Stat(filepath.Join(destDir, name)); err != nil {
+			t.Errorf("Expected %s in library: %v", name, err)
+		}
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(libraryDir, "imports"))
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 session, got %d", len(entries))
+	}
+	events, err := internal.ReadManifest(filepath.Join(libraryDir, "imports", entries[0].Name(), "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	removed := 0
+	for _, e := range events {
+		if e.Event == "source_removed" {
+			removed++
+		}
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 source_removed events, got %d", removed)
+	}
+}
+
+func TestImport_SkipsUnchangedSources(t *testing.T) {
+	tempDir := t.TempDir()
+	inputDir := filepath.Join(tempDir, "input")
+	libraryDir := filepath.Join(tempDir, "library")
+	os.MkdirAll(inputDir, 0755)
+
+	fileTime := time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		path := filepath.Join(inputDir, fmt.Sprintf("photo%d.jpg", i))
+		os.WriteFile(path, []byte(fmt.Sprintf("data %d", i)), 0644)
+		_ = os.Chtimes(path, fileTime, fileTime)
+	}
+
+	sources, err := internal.OpenSourceCache(libraryDir, false)
+	if err != nil {
+		t.Fatalf("OpenSourceCache failed: %v", err)
+	}
+	defer sources.Close()
+	conf := &internal.Config{
+		User:     "testuser",
+		Library:  libraryDir,
+		VideoLib: libraryDir,
+		ImageExt: []string{".jpg"},
+		VideoExt: []string{".mp4"},
+	}
+
+	// An earlier run imported the first three files
+	scan, err := internal.ScanMediaFiles(inputDir, conf)
+	if err != nil {
+		t.Fatalf("ScanMediaFiles failed: %v", err)
+	}
+	run := internal.NewImportRun(scan)
+	run.Sources = sources
+	files := run.Files
+	for _, f := range files {
+		if err := internal.ProcessFile(f, conf, run, conf.User, false, nil, true); err != nil {
+			t.Fatalf("ProcessFile failed: %v", err)
+		}
+	}
+
+	newFile := filepath.Join(inputDir, "photo3.jpg")
+	os.WriteFile(newFile, []byte("data 3"), 0644)
+	_ = os.Chtimes(newFile, fileTime, fileTime)
+
+	scan, err = internal.ScanMediaFiles(inputDir, conf)
+	if err != nil {
+		t.Fatalf("ScanMediaFiles failed: %v", err)
+	}
+	run = internal.NewImportRun(scan)
+	run.Sources = sources
+	run.SkipUnchangedSources()
+	pending := run.Files
+	if len(pending) != 1 || pending[0] != newFile {
+		t.Fatalf("Expected only the new file pending, got %v", pending)
+	}
+	if err := processFiles(pending, conf, run, conf.User, inputDir, false); err != nil {
+		t.Fatalf("processFiles failed: %v", err)
+	}
+
+	sessions, _ := os.ReadDir(filepath.Join(libraryDir, "imports"))
+	if len(sessions) != 1 {
+		t.Fatalf("Expected one session, got %d", len(sessions))
+	}
+	events, err := internal.ReadManifest(filepath.Join(libraryDir, "imports", sessions[0].Name(), "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	last := events[len(events)-1]
+	if last.Event != "session_end" || last.Copied != 1 || last.SkippedUnchanged != 3 || last.TotalScanned != 4 {
+		t.Errorf("Unexpected session_end: %+v", last)
+	}
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"anduril/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	indexLibraryFlag      string
+	indexVideoLibraryFlag string
+	indexPerceptualFlag   bool
+)
+
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Manage the library content hash index",
+	Long: `The hash index maps content hashes to library paths so imports can skip files
+that already exist anywhere in the library, whatever their name or date folder.`,
+}
+
+var indexRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "Rebuild the hash index from the files in the library",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conf, err := internal.LoadConfig()
+		if err != nil {
+			return err
+		}
+
+		if indexLibraryFlag != "" {
+			conf.Library = indexLibraryFlag
+		}
+		if indexVideoLibraryFlag != "" {
+			conf.VideoLib = indexVideoLibraryFlag
+		}
+		if conf.Library == "" {
+			return fmt.Errorf("missing --library and no default set")
+		}
+
+		fmt.Printf("Rebuilding hash index for %s\n", conf.Library)
+		if conf.VideoLib != "" && conf.VideoLib != conf.Library {
+			fmt.Printf("  including video library %s\n", conf.VideoLib)
+		}
+
+		startTime := time.Now()
+		count, err := internal.RebuildHashIndex(conf)
+		if err != nil {
+			return fmt.Errorf("failed to rebuild hash index: %w", err)
+		}
+
+		fmt.Printf("\n✅ Indexed %d files in %v\n", count, time.Since(startTime).Round(time.Second))
+		fmt.Printf("Index: %s\n", internal.HashIndexPath(conf.Library))
+
+		if indexPerceptualFlag {
+			fmt.Println("\nRebuilding perceptual index for near-duplicate detection")
+			startTime := time.Now()
+			count, err := internal.RebuildPerceptualIndex(conf)
+			if err != nil {
+				return fmt.Errorf("failed to rebuild perceptual index: %w", err)
+			}
+			fmt.Printf("\n✅ Hashed %d images in %v\n", count, time.Since(startTime).Round(time.Second))
+			fmt.Printf("Index: %s\n", internal.PerceptualIndexPath(conf.Library))
+		}
+		return nil
+	},
+}
+
+func init() {
+	indexRebuildCmd.Flags().StringVar(&indexLibraryFlag, "library", "", "Root library folder")
+	indexRebuildCmd.Flags().StringVar(&indexVideoLibraryFlag, "videolibrary", "", "Video library folder")
+	indexRebuildCmd.Flags().BoolVar(&indexPerceptualFlag, "perceptual", false, "Also rebuild the perceptual index of images used by --near-duplicates (decodes every image)")
+
+	indexCmd.AddCommand(indexRebuildCmd)
+	rootCmd.AddCommand(indexCmd)
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+
+	"anduril/internal"
+	"github.com/spf13/cobra"
+)
+
+var offsetCmd = &cobra.Command{
+	Use:   "offset",
+	Short: "Work out camera clock offsets",
+	Long: `Cameras whose clock was never set put their photos in the wrong date folders.
+Clock offsets shift the EXIF dates of one camera during import, configured as
+[[clock_offset]] in anduril.toml or with 'anduril import --offset Make/Model=+2h'.`,
+}
+
+var offsetSuggestCmd = &cobra.Command{
+	Use:   "suggest CAMERA_FILE REFERENCE_FILE [CAMERA_FILE REFERENCE_FILE ...]",
+	Short: "Suggest an offset from photos of the same moments",
+	Long: `Give pairs of files showing the same moment: first one from the camera with the
+wrong clock, then one from a device with a correct clock (e.g. a phone). The median
+difference between their capture dates is printed as a clock offset rule.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 || len(args)%2 != 0 {
+			return fmt.Errorf("requires pairs of files: CAMERA_FILE REFERENCE_FILE")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conf, err := internal.LoadConfig()
+		if err != nil {
+			return err
+		}
+		if useExifTool {
+			conf.UseExifTool = true
+		}
+		defer internal.CloseExifTool()
+
+		var pairs [][2]string
+		for i := 0; i < len(args); i += 2 {
+			pairs = append(pairs, [2]string{args[i], args[i+1]})
+		}
+
+		rule, err := internal.SuggestClockOffset(pairs, conf)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("\n✅ Suggested clock offset from %d pairs: %s\n\n", len(pairs), rule.Offset)
+		fmt.Printf("Import with:\n  anduril import --offset '%s' INPUT_DIR\n\n", rule)
+		fmt.Println("or add to anduril.toml:")
+		fmt.Println("  [[clock_offset]]")
+		fmt.Printf("  make = %q\n", rule.Make)
+		fmt.Printf("  model = %q\n", rule.Model)
+		fmt.Printf("  offset = %q\n", rule.Offset)
+		return nil
+	},
+}
+
+func init() {
+	offsetSuggestCmd.Flags().BoolVar(&useExifTool, "exiftool", false, "Force to use exiftool binary")
+
+	offsetCmd.AddCommand(offsetSuggestCmd)
+	rootCmd.AddCommand(offsetCmd)
+}
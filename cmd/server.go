@@ -90,7 +90,7 @@ func setupPhotoSchema(app *pocketbase.PocketBase) error {
 
 // startFilesystemWatcher monitors the photo library for changes
 func startFilesystemWatcher(app *pocketbase.PocketBase, conf *internal.Config) {
-	watcher, err := internal.NewWatcher(conf.Library, conf.VideoLib)
+	watcher, err := internal.NewWatcher(conf.Library, conf.VideoLib, conf)
 	if err != nil {
 		log.Printf("Failed to start filesystem watcher: %v", err)
 		return
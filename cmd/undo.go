@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+
+	"anduril/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	undoLibraryFlag string
+	undoDryRunFlag  bool
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo [session-id]",
+	Short: "Undo an import session",
+	Long: `Remove the files an import session added to the library.
+
+Each destination recorded in imports/<session-id>/manifest.jsonl is deleted only if its
+SHA256 still matches the hash recorded at import time. Files imported with --move are
+moved back to their source path, and sources --move deleted as duplicates are copied
+back from the library file they duplicate; a source path taken since is left alone.
+Browse hardlinks and empty date folders are cleaned up and an undo record is appended
+to the manifest.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conf, err := internal.LoadConfig()
+		if err != nil {
+			return err
+		}
+
+		library := undoLibraryFlag
+		if library == "" {
+			library = conf.Library
+		}
+		if library == "" {
+			return fmt.Errorf("missing --library and no default set")
+		}
+
+		if undoDryRunFlag {
+			fmt.Println("Dry run mode: no files will be deleted")
+		}
+
+		result, err := internal.UndoImportSession(library, args[0], undoDryRunFlag)
+		if err != nil {
+			return fmt.Errorf("failed to undo session %s: %w", args[0], err)
+		}
+
+		for _, path := range result.Modified {
+			fmt.Printf("Keeping %s (content changed since import)\n", path)
+		}
+		for _, path := range result.Conflicts {
+			fmt.Printf("Keeping %s (the path to return it to is occupied)\n", path)
+		}
+		if !result.DryRun {
+			for _, path := range result.Restored {
+				fmt.Printf("Restored %s\n", path)
+			}
+		}
+
+		fmt.Printf("\nUndo Summary (session %s):\n", result.SessionID)
+		if result.DryRun {
+			fmt.Printf("  ✗ Would remove:      %d files\n", len(result.Removed))
+		} else {
+			fmt.Printf("  ✗ Removed:           %d files\n", len(result.Removed))
+		}
+		if len(result.Restored) > 0 {
+			fmt.Printf("  ↩ Restored to source: %d files\n", len(result.Restored))
+		}
+		if len(result.Reinstated) > 0 {
+			fmt.Printf("  ⇄ Reinstated:        %d files (from superseded/)\n", len(result.Reinstated))
+		}
+		if len(result.Modified) > 0 {
+			fmt.Printf("  ⚠ Kept (modified):   %d files\n", len(result.Modified))
+		}
+		if len(result.Conflicts) > 0 {
+			fmt.Printf("  ⚠ Kept (source path occupied): %d files\n", len(result.Conflicts))
+		}
+		if len(result.Missing) > 0 {
+			fmt.Printf("  ⊘ Already missing:   %d files\n", len(result.Missing))
+		}
+		if result.BrowseRemoved > 0 {
+			fmt.Printf("  🔗 Browse links:      %d\n", result.BrowseRemoved)
+		}
+		if len(result.DirsRemoved) > 0 {
+			fmt.Printf("  📁 Empty folders:     %d\n", len(result.DirsRemoved))
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	undoCmd.Flags().StringVar(&undoLibraryFlag, "library", "", "Root library folder containing imports/")
+	undoCmd.Flags().BoolVar(&undoDryRunFlag, "dry-run", false, "Show what would be deleted without deleting")
+
+	rootCmd.AddCommand(undoCmd)
+}
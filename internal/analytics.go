@@ -17,6 +17,7 @@ type AnalyticsOptions struct {
 	IncludeHidden  bool
 	MediaOnly      bool
 	FindDuplicates bool
+	NearDistance   int // Largest dHash distance grouping images as near-duplicates (0: exact duplicates only)
 	Format         string
 	CreateBrowse   bool
 }
@@ -76,9 +77,11 @@ type QualityDistribution struct {
 }
 
 type DuplicateSet struct {
-	Hash  string   `json:"hash"`
-	Files []string `json:"files"`
-	Size  int64    `json:"size_bytes"`
+	Hash       string    `json:"hash"` // SHA256, or the dHash of the first image of a near-duplicate set
+	Files      []string  `json:"files"`
+	Size       int64     `json:"size_bytes"`           // Of each file, or of the first image of a near-duplicate set
+	Near       bool      `json:"near,omitempty"`       // Similar images rather than identical files
+	Similarity []float64 `json:"similarity,omitempty"` // Of each file to the first, the highest quality image (near-duplicates only)
 }
 
 // LargeFileInfo contains information about large files (>100MB)
@@ -96,18 +99,13 @@ type ProgressInfo struct {
 	StartTime    time.Time
 }
 
-// Default folders to skip for performance
+// Folders analytics skips for performance on top of the import's default excludes
+// (defaultScanExcludes): build and tool output, which could in principle hold photos
+// and so is only skipped when counting
 var defaultSkipPatterns = []string{
-	"node_modules",
-	".git",
-	".cache",
 	"cache",
-	"Cache",
 	"Lightroom Previews",
-	"Lightroom Previews.lrdata",
 	".lightroom",
-	"Thumbs.db",
-	".DS_Store",
 	".idea",
 	".vscode",
 	"build",
@@ -124,8 +122,8 @@ var defaultSkipPatterns = []string{
 
 // File type categories
 var fileTypeCategories = map[string][]string{
-	"Images":        {".jpg", ".jpeg", ".png", ".gif", ".bmp", ".tiff", ".tif", ".webp", ".heic", ".heif", ".raw", ".cr2", ".nef", ".arw", ".dng"},
-	"Videos":        {".mp4", ".mov", ".avi", ".mkv", ".wmv", ".flv", ".webm", ".m4v", ".mpg", ".mpeg", ".3gp"},
+	"Images":        formatExtensions(TypeImage),
+	"Videos":        formatExtensions(TypeVideo),
 	"Documents":     {".pdf", ".doc", ".docx", ".rtf", ".odt"},
 	"Spreadsheets":  {".xls", ".xlsx", ".csv", ".ods"},
 	"Presentations": {".ppt", ".pptx", ".odp"},
@@ -185,7 +183,7 @@ func AnalyzeFolder(folderPath string, cfg *Config, options *AnalyticsOptions) (*
 	go displayProgress(progress, done)
 
 	// Scan folder
-	err := scanFolderRecursive(folderPath, "", options, results, duplicateHashes, progress)
+	err := scanFolderRecursive(folderPath, "", cfg, options, results, duplicateHashes, progress)
 	if err != nil {
 		done <- true
 		return nil, err
@@ -198,7 +196,7 @@ func AnalyzeFolder(folderPath string, cfg *Config, options *AnalyticsOptions) (*
 
 	// Create browse structure if requested
 	if options.CreateBrowse {
-		if err := CreateBrowseStructure(results); err != nil {
+		if err := CreateBrowseStructure(results, cfg); err != nil {
 			fmt.Printf("Warning: failed to create browse structure: %v\n", err)
 		}
 	}
@@ -206,6 +204,9 @@ func AnalyzeFolder(folderPath string, cfg *Config, options *AnalyticsOptions) (*
 	// Analyze duplicates if requested
 	if options.FindDuplicates {
 		results.Duplicates = findDuplicateSets(duplicateHashes)
+		if options.NearDistance > 0 {
+			results.Duplicates = append(results.Duplicates, findNearDuplicateSets(duplicateHashes, options.NearDistance, cfg)...)
+		}
 	}
 
 	// Sort and keep top 5 largest files
@@ -225,7 +226,7 @@ func AnalyzeFolder(folderPath string, cfg *Config, options *AnalyticsOptions) (*
 }
 
 // scanFolderRecursive recursively scans folder with smart filtering
-func scanFolderRecursive(currentPath, relativePath string, options *AnalyticsOptions, results *AnalyticsResults, duplicateHashes map[string][]string, progress *ProgressInfo) error {
+func scanFolderRecursive(currentPath, relativePath string, cfg *Config, options *AnalyticsOptions, results *AnalyticsResults, duplicateHashes map[string][]string, progress *ProgressInfo) error {
 	// Check max depth
 	if options.MaxDepth > 0 {
 		depth := strings.Count(relativePath, string(filepath.Separator))
@@ -269,7 +270,7 @@ func scanFolderRecursive(currentPath, relativePath string, options *AnalyticsOpt
 
 			// Recurse into subdirectory
 			newRelativePath := filepath.Join(relativePath, name)
-			if err := scanFolderRecursive(fullPath, newRelativePath, options, results, duplicateHashes, progress); err != nil {
+			if err := scanFolderRecursive(fullPath, newRelativePath, cfg, options, results, duplicateHashes, progress); err != nil {
 				// Log error but continue scanning
 				fmt.Printf("Warning: error scanning %s: %v\n", fullPath, err)
 			}
@@ -278,7 +279,7 @@ func scanFolderRecursive(currentPath, relativePath string, options *AnalyticsOpt
 			atomic.AddInt64(&progress.FilesScanned, 1)
 
 			// Process file
-			if err := analyzeFile(fullPath, results, options, duplicateHashes); err != nil {
+			if err := analyzeFile(fullPath, cfg, results, options, duplicateHashes); err != nil {
 				fmt.Printf("Warning: error analyzing %s: %v\n", fullPath, err)
 			}
 		}
@@ -289,21 +290,13 @@ func scanFolderRecursive(currentPath, relativePath string, options *AnalyticsOpt
 
 // shouldSkipFolder checks if a folder should be skipped for performance
 func shouldSkipFolder(folderName string) bool {
-	folderLower := strings.ToLower(folderName)
-
-	for _, pattern := range defaultSkipPatterns {
-		if folderLower == strings.ToLower(pattern) {
-			return true
-		}
-		// Also check for pattern prefixes (e.g., "Lightroom*")
-		if strings.HasSuffix(pattern, "*") {
-			prefix := strings.TrimSuffix(strings.ToLower(pattern), "*")
-			if strings.HasPrefix(folderLower, prefix) {
+	for _, patterns := range [][]string{defaultScanExcludes, defaultSkipPatterns} {
+		for _, pattern := range patterns {
+			if matchScanPattern(pattern, folderName) {
 				return true
 			}
 		}
 	}
-
 	return false
 }
 
@@ -346,7 +339,7 @@ func displayProgress(progress *ProgressInfo, done <-chan bool) {
 }
 
 // analyzeFile analyzes a single file and updates results
-func analyzeFile(filePath string, results *AnalyticsResults, options *AnalyticsOptions, duplicateHashes map[string][]string) error {
+func analyzeFile(filePath string, cfg *Config, results *AnalyticsResults, options *AnalyticsOptions, duplicateHashes map[string][]string) error {
 	info, err := os.Stat(filePath)
 	if err != nil {
 		return err
@@ -359,7 +352,7 @@ func analyzeFile(filePath string, results *AnalyticsResults, options *AnalyticsO
 	ext := strings.ToLower(filepath.Ext(filePath))
 
 	// Categorize file
-	category := categorizeFile(ext)
+	category := categorizeFile(filePath, cfg)
 
 	// Skip non-media if media-only mode
 	if options.MediaOnly && category != "Images" && category != "Videos" {
@@ -399,8 +392,21 @@ func analyzeFile(filePath string, results *AnalyticsResults, options *AnalyticsO
 	return nil
 }
 
-// categorizeFile determines the category of a file based on extension
-func categorizeFile(ext string) string {
+// categorizeFile determines the category of a file: media by the configured extensions,
+// sniffing the content of files whose extension is unknown, anything else by its
+// extension
+func categorizeFile(filePath string, cfg *Config) string {
+	switch determineFileType(filePath, cfg) {
+	case TypeImage:
+		return "Images"
+	case TypeVideo:
+		return "Videos"
+	}
+	return categorizeExtension(strings.ToLower(filepath.Ext(filePath)))
+}
+
+// categorizeExtension determines the category of a file extension
+func categorizeExtension(ext string) string {
 	for category, extensions := range fileTypeCategories {
 		for _, e := range extensions {
 			if ext == e {
@@ -459,6 +465,94 @@ func findDuplicateSets(hashes map[string][]string) []DuplicateSet {
 	return duplicates
 }
 
+// findNearDuplicateSets groups images whose dHashes are within maxDistance of another
+// in the group: recompressed, resized or re-saved copies. Identical files take part
+// once, as they already form an exact set. Each set lists its highest resolution (then
+// largest) image first. Images Go can't decode are left out.
+func findNearDuplicateSets(hashes map[string][]string, maxDistance int, cfg *Config) []DuplicateSet {
+	type nearImage struct {
+		path   string
+		dhash  uint64
+		pixels int
+		size   int64
+	}
+	var images []nearImage
+	for _, files := range hashes {
+		if categorizeFile(files[0], cfg) != "Images" {
+			continue
+		}
+		dhash, err := perceptualHash(files[0])
+		if err != nil {
+			continue
+		}
+		img := nearImage{path: files[0], dhash: dhash}
+		if w, h, err := getImageResolution(files[0]); err == nil {
+			img.pixels = w * h
+		}
+		img.size, _ = getFileSize(files[0])
+		images = append(images, img)
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].path < images[j].path })
+	var tree bkTree
+	byPath := make(map[string]int, len(images))
+	for i, img := range images {
+		tree.add(img.dhash, img.path)
+		byPath[img.path] = i
+	}
+
+	// Union-find over the pairs close enough, found through the tree
+	parent := make([]int, len(images))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i, img := range images {
+		tree.within(img.dhash, maxDistance, func(path string, _ int) {
+			if j := byPath[path]; j > i {
+				parent[find(j)] = find(i)
+			}
+		})
+	}
+
+	groups := make(map[int][]nearImage)
+	var roots []int
+	for i, img := range images {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], img)
+	}
+
+	var sets []DuplicateSet
+	for _, root := range roots {
+		members := groups[root]
+		if len(members) < 2 {
+			continue
+		}
+		sort.SliceStable(members, func(i, j int) bool {
+			if members[i].pixels != members[j].pixels {
+				return members[i].pixels > members[j].pixels
+			}
+			return members[i].size > members[j].size
+		})
+		best := members[0]
+		set := DuplicateSet{Hash: fmt.Sprintf("%016x", best.dhash), Size: best.size, Near: true}
+		for _, m := range members {
+			set.Files = append(set.Files, m.path)
+			set.Similarity = append(set.Similarity, perceptualSimilarity(hammingDistance(best.dhash, m.dhash)))
+		}
+		sets = append(sets, set)
+	}
+	return sets
+}
+
 // analyzeMedia provides media-specific insights
 func analyzeMedia(folderPath string, results *AnalyticsResults, options *AnalyticsOptions) *MediaInsights {
 	insights := &MediaInsights{
@@ -618,18 +712,41 @@ func displayTable(results *AnalyticsResults, options *AnalyticsOptions) error {
 	}
 
 	// Duplicates
-	if options.FindDuplicates && len(results.Duplicates) > 0 {
-		fmt.Printf("\n🔍 Duplicates Found (%d sets):\n", len(results.Duplicates))
+	var exact, near []DuplicateSet
+	for _, dup := range results.Duplicates {
+		if dup.Near {
+			near = append(near, dup)
+		} else {
+			exact = append(exact, dup)
+		}
+	}
+	if options.FindDuplicates && len(exact) > 0 {
+		fmt.Printf("\n🔍 Duplicates Found (%d sets):\n", len(exact))
 		totalWaste := int64(0)
-		for i, dup := range results.Duplicates[:min(5, len(results.Duplicates))] {
+		for i, dup := range exact[:min(5, len(exact))] {
 			fmt.Printf("  - Set %d: %d files (%s each)\n", i+1, len(dup.Files), formatBytes(dup.Size))
 			totalWaste += dup.Size * int64(len(dup.Files)-1)
 		}
-		if len(results.Duplicates) > 5 {
-			fmt.Printf("  - ... and %d more sets\n", len(results.Duplicates)-5)
+		if len(exact) > 5 {
+			fmt.Printf("  - ... and %d more sets\n", len(exact)-5)
 		}
 		fmt.Printf("  💾 Potential space savings: %s\n", formatBytes(totalWaste))
 	}
+	if options.FindDuplicates && len(near) > 0 {
+		fmt.Printf("\n🔍 Near-duplicate Images (%d sets):\n", len(near))
+		for i, dup := range near[:min(5, len(near))] {
+			lowest := dup.Similarity[len(dup.Similarity)-1]
+			for _, s := range dup.Similarity {
+				if s < lowest {
+					lowest = s
+				}
+			}
+			fmt.Printf("  - Set %d: %d images, best %s (%.0f%%+ similar)\n", i+1, len(dup.Files), filepath.Base(dup.Files[0]), lowest*100)
+		}
+		if len(near) > 5 {
+			fmt.Printf("  - ... and %d more sets\n", len(near)-5)
+		}
+	}
 
 	// Recommendations
 	fmt.Printf("\n💡 Recommendations:\n")
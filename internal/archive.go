@@ -0,0 +1,470 @@
+package internal
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Archive entries are imported without extracting the archive first. Their source
+// path is written as "<archive>!/<path in archive>", e.g.
+// takeout.zip!/Takeout/Google Photos/IMG_1.jpg, and every place that reads a source
+// goes through openSource/statSource. Entries are never hardlinked, reflinked,
+// moved or deleted: the archive itself is left untouched.
+
+// archiveSep separates the archive path from the entry path
+const archiveSep = "!/"
+
+// maxBufferedEntry is the largest compressed-tar entry kept in memory; bigger
+// entries are spooled to a file (see SetArchiveSpoolDir) so they can be read more
+// than once
+const maxBufferedEntry = 32 << 20
+
+type archiveKind int
+
+const (
+	archiveZip archiveKind = iota
+	archiveTar
+	archiveTarGz
+)
+
+// archiveKindOf returns the archive type by file name
+func archiveKindOf(p string) (archiveKind, bool) {
+	lower := strings.ToLower(p)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return archiveZip, true
+	case strings.HasSuffix(lower, ".tar"):
+		return archiveTar, true
+	case strings.HasSuffix(lower, ".tgz"), strings.HasSuffix(lower, ".tar.gz"):
+		return archiveTarGz, true
+	}
+	return 0, false
+}
+
+// IsArchive reports whether path names a supported archive (.zip, .tar, .tgz, .tar.gz)
+func IsArchive(path string) bool {
+	_, ok := archiveKindOf(path)
+	return ok
+}
+
+// IsArchiveEntry reports whether src is a path inside an archive
+func IsArchiveEntry(src string) bool {
+	_, _, ok := splitArchivePath(src)
+	return ok
+}
+
+// NeedsSequentialRead reports whether any source lives in a compressed tar, which can
+// only be read front to back and so must be imported by a single worker
+func NeedsSequentialRead(files []string) bool {
+	for _, f := range files {
+		if archivePath, _, ok := splitArchivePath(f); ok {
+			if kind, _ := archiveKindOf(archivePath); kind == archiveTarGz {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func archiveEntryPath(archivePath, name string) string {
+	return archivePath + archiveSep + name
+}
+
+// splitArchivePath splits "<archive>!/<entry>" into its parts. The archive is the first
+// prefix ending in "!/" that has an archive extension and is a regular file, so folder
+// names and entry names containing "!/" are not mistaken for the boundary.
+func splitArchivePath(src string) (string, string, bool) {
+	for i := strings.Index(src, archiveSep); i >= 0; {
+		if archivePath := src[:i]; IsArchive(archivePath) && isArchiveFile(archivePath) {
+			return archivePath, src[i+len(archiveSep):], true
+		}
+		next := strings.Index(src[i+len(archiveSep):], archiveSep)
+		if next < 0 {
+			break
+		}
+		i += len(archiveSep) + next
+	}
+	return "", "", false
+}
+
+// isArchiveFile reports whether archivePath is an open archive or a regular file
+func isArchiveFile(archivePath string) bool {
+	openArchives.Lock()
+	_, open := openArchives.m[archivePath]
+	openArchives.Unlock()
+	if open {
+		return true
+	}
+	info, err := os.Stat(archivePath)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// archiveEntry is one regular file inside an archive
+type archiveEntry struct {
+	name    string
+	index   int // Position in the archive, for sequential readers
+	size    int64
+	modTime time.Time
+	offset  int64     // Data offset in an uncompressed tar
+	zf      *zip.File // Zip entry
+}
+
+// sourceArchive is an open archive with its entry table
+type sourceArchive struct {
+	path    string
+	kind    archiveKind
+	entries map[string]*archiveEntry
+	order   []*archiveEntry
+
+	file *os.File        // Tar and compressed tar
+	zr   *zip.ReadCloser // Zip
+
+	// Compressed tar cursor and the last materialized entry
+	mu         sync.Mutex
+	gz         *gzip.Reader
+	tr         *tar.Reader
+	pos        int
+	cachedName string
+	cachedData []byte
+	cachedFile string
+}
+
+var openArchives = struct {
+	sync.Mutex
+	m        map[string]*sourceArchive
+	spoolDir string // Where large compressed-tar entries are spooled ("" for the temp dir)
+}{m: make(map[string]*sourceArchive)}
+
+// ArchiveSpoolDir is the folder in the library where large compressed-tar entries are
+// spooled during an import, so they take space on the filesystem preflight checks
+func ArchiveSpoolDir(libraryPath string) string {
+	return filepath.Join(libraryPath, ".anduril", "spool")
+}
+
+// SetArchiveSpoolDir makes large compressed-tar entries spool to dir instead of the
+// system temp dir, which may be a small tmpfs
+func SetArchiveSpoolDir(dir string) {
+	openArchives.Lock()
+	defer openArchives.Unlock()
+	openArchives.spoolDir = dir
+}
+
+// getArchive opens an archive once and indexes its entries
+func getArchive(archivePath string) (*sourceArchive, error) {
+	openArchives.Lock()
+	defer openArchives.Unlock()
+
+	if a, ok := openArchives.m[archivePath]; ok {
+		return a, nil
+	}
+
+	kind, ok := archiveKindOf(archivePath)
+	if !ok {
+		return nil, fmt.Errorf("unsupported archive: %s", archivePath)
+	}
+
+	a := &sourceArchive{path: archivePath, kind: kind, entries: make(map[string]*archiveEntry), pos: -1}
+	var err error
+	switch kind {
+	case archiveZip:
+		err = a.indexZip()
+	default:
+		err = a.indexTar()
+	}
+	if err != nil {
+		a.close()
+		return nil, fmt.Errorf("failed to read archive %s: %w", archivePath, err)
+	}
+
+	openArchives.m[archivePath] = a
+	return a, nil
+}
+
+// CloseArchives closes every archive opened during an import and removes spooled entries
+func CloseArchives() {
+	openArchives.Lock()
+	defer openArchives.Unlock()
+
+	for p, a := range openArchives.m {
+		a.close()
+		delete(openArchives.m, p)
+	}
+	if openArchives.spoolDir != "" {
+		os.Remove(openArchives.spoolDir) // Only if empty
+	}
+}
+
+func (a *sourceArchive) close() {
+	if a.zr != nil {
+		a.zr.Close()
+	}
+	if a.file != nil {
+		a.file.Close()
+	}
+	if a.cachedFile != "" {
+		os.Remove(a.cachedFile)
+	}
+}
+
+// addEntry records a regular file, ignoring directories and unsafe names
+func (a *sourceArchive) addEntry(e *archiveEntry) {
+	name := path.Clean(strings.TrimPrefix(e.name, "/"))
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return
+	}
+	e.name = name
+	e.index = len(a.order)
+	a.entries[name] = e
+	a.order = append(a.order, e)
+}
+
+func (a *sourceArchive) indexZip() error {
+	zr, err := zip.OpenReader(a.path)
+	if err != nil {
+		return err
+	}
+	a.zr = zr
+
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		a.addEntry(&archiveEntry{name: zf.Name, size: int64(zf.UncompressedSize64), modTime: zf.Modified, zf: zf})
+	}
+	return nil
+}
+
+func (a *sourceArchive) indexTar() error {
+	f, err := os.Open(a.path)
+	if err != nil {
+		return err
+	}
+	a.file = f
+
+	var r io.Reader = f
+	if a.kind == archiveTarGz {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		e := &archiveEntry{name: hdr.Name, size: hdr.Size, modTime: hdr.ModTime}
+		if a.kind == archiveTar {
+			// tar.Reader reads whole blocks without buffering ahead, so the file
+			// position right after Next is where this entry's data starts
+			if e.offset, err = f.Seek(0, io.SeekCurrent); err != nil {
+				return err
+			}
+		}
+		a.addEntry(e)
+	}
+	return nil
+}
+
+// open returns a reader for one entry
+func (a *sourceArchive) open(name string) (io.ReadCloser, error) {
+	e, ok := a.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", archiveEntryPath(a.path, name), os.ErrNotExist)
+	}
+
+	switch a.kind {
+	case archiveZip:
+		return e.zf.Open()
+	case archiveTar:
+		return io.NopCloser(io.NewSectionReader(a.file, e.offset, e.size)), nil
+	default:
+		return a.openSequential(e)
+	}
+}
+
+// openSequential serves a compressed tar entry. The stream can only move forward, so
+// the requested entry is materialized (in memory, or spooled to a temp file when
+// large) and repeated reads of it - date, hash, copy - don't restart decompression.
+func (a *sourceArchive) openSequential(e *archiveEntry) (io.ReadCloser, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.cachedName != e.name {
+		if err := a.materialize(e); err != nil {
+			return nil, err
+		}
+	}
+
+	if a.cachedFile != "" {
+		return os.Open(a.cachedFile)
+	}
+	return io.NopCloser(bytes.NewReader(a.cachedData)), nil
+}
+
+// materialize advances the stream to e (restarting it for an earlier entry) and
+// caches its content. a.mu must be held.
+func (a *sourceArchive) materialize(e *archiveEntry) error {
+	if a.tr == nil || a.pos >= e.index {
+		if _, err := a.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		gz, err := gzip.NewReader(a.file)
+		if err != nil {
+			return err
+		}
+		a.gz, a.tr, a.pos = gz, tar.NewReader(gz), -1
+	}
+
+	for a.pos < e.index {
+		hdr, err := a.tr.Next()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("failed to seek to %s: %w", e.name, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if known, ok := a.entries[name]; ok && known.index > a.pos {
+			a.pos = known.index
+		}
+	}
+
+	// Drop the previous entry
+	if a.cachedFile != "" {
+		os.Remove(a.cachedFile)
+	}
+	a.cachedName, a.cachedData, a.cachedFile = "", nil, ""
+
+	if e.size <= maxBufferedEntry {
+		data, err := io.ReadAll(a.tr)
+		if err != nil {
+			return err
+		}
+		a.cachedData = data
+	} else {
+		openArchives.Lock()
+		dir := openArchives.spoolDir
+		openArchives.Unlock()
+		if dir != "" {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		}
+		spool, err := os.CreateTemp(dir, "anduril-entry-*")
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(spool, a.tr); err != nil {
+			spool.Close()
+			os.Remove(spool.Name())
+			return err
+		}
+		if err := spool.Close(); err != nil {
+			os.Remove(spool.Name())
+			return err
+		}
+		a.cachedFile = spool.Name()
+	}
+
+	// The tar reader is now past this entry's data
+	a.cachedName = e.name
+	return nil
+}
+
+// openSource opens a source file, which may be an archive entry
+func openSource(src string) (io.ReadCloser, error) {
+	archivePath, name, ok := splitArchivePath(src)
+	if !ok {
+		return os.Open(src)
+	}
+	a, err := getArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	return a.open(name)
+}
+
+// spooledSize returns the size of src when reading it spools it to disk: an entry of
+// a compressed tar larger than maxBufferedEntry. Other sources return 0.
+func spooledSize(src string) int64 {
+	archivePath, _, ok := splitArchivePath(src)
+	if !ok {
+		return 0
+	}
+	if kind, _ := archiveKindOf(archivePath); kind != archiveTarGz {
+		return 0
+	}
+	size, _, err := statSource(src)
+	if err != nil || size <= maxBufferedEntry {
+		return 0
+	}
+	return size
+}
+
+// statSource returns the size and modification time of a source file or archive entry
+func statSource(src string) (int64, time.Time, error) {
+	archivePath, name, ok := splitArchivePath(src)
+	if !ok {
+		info, err := os.Stat(src)
+		if err != nil {
+			return 0, time.Time{}, err
+		}
+		return info.Size(), info.ModTime(), nil
+	}
+
+	a, err := getArchive(archivePath)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	e, ok := a.entries[name]
+	if !ok {
+		return 0, time.Time{}, fmt.Errorf("%s: %w", src, os.ErrNotExist)
+	}
+	return e.size, e.modTime, nil
+}
+
+// scanArchive lists the media files and sidecars inside an archive as entry paths,
+// remembering the formats it detects in formats
+func scanArchive(archivePath string, cfg *Config, formats *FormatCache) ([]string, []string, error) {
+	a, err := getArchive(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var files, sidecars []string
+	for _, e := range a.order {
+		src := archiveEntryPath(archivePath, e.name)
+		switch {
+		case isSidecar(src, cfg):
+			sidecars = append(sidecars, src)
+		case mediaType(src, cfg, formats) != TypeOther:
+			files = append(files, src)
+		}
+	}
+	return files, sidecars, nil
+}
@@ -0,0 +1,269 @@
+package internal
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testEntry struct {
+	name    string
+	data    []byte
+	modTime time.Time
+}
+
+// writeTestArchive writes entries into a .zip, .tar or .tar.gz depending on path
+func writeTestArchive(t *testing.T, path string, entries []testEntry) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if strings.HasSuffix(path, ".zip") {
+		zw := zip.NewWriter(f)
+		for _, e := range entries {
+			w, err := zw.CreateHeader(&zip.FileHeader{Name: e.name, Method: zip.Deflate, Modified: e.modTime})
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write(e.data)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	var w io.Writer = f
+	var gz *gzip.Writer
+	if strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".tgz") {
+		gz = gzip.NewWriter(f)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.data)), ModTime: e.modTime, Typeflag: tar.TypeReg}
+		if strings.HasSuffix(e.name, "/") {
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(e.data)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSplitArchivePath(t *testing.T) {
+	in := t.TempDir()
+	for _, name := range []string{"takeout.zip", "b.tgz", "b.tar"} {
+		os.WriteFile(filepath.Join(in, name), nil, 0644)
+	}
+	// A folder whose name ends in ".zip!"
+	os.MkdirAll(filepath.Join(in, "saved.zip!"), 0755)
+
+	takeout := filepath.Join(in, "takeout.zip")
+	archive, name, ok := splitArchivePath(takeout + "!/Photos/IMG_1.jpg")
+	if !ok || archive != takeout || name != "Photos/IMG_1.jpg" {
+		t.Errorf("splitArchivePath = %q, %q, %v", archive, name, ok)
+	}
+	archive, name, ok = splitArchivePath(takeout + "!/Wow!/old.zip!/IMG_1.jpg")
+	if !ok || archive != takeout || name != "Wow!/old.zip!/IMG_1.jpg" {
+		t.Errorf("Expected the split at the archive file, got %q, %q, %v", archive, name, ok)
+	}
+	if IsArchiveEntry(filepath.Join(in, "wow!", "IMG_1.jpg")) {
+		t.Error("A \"!/\" after a non-archive name is not an archive entry")
+	}
+	if IsArchiveEntry(filepath.Join(in, "saved.zip!", "IMG_1.jpg")) {
+		t.Error("A folder named like an archive is not an archive")
+	}
+	if !NeedsSequentialRead([]string{filepath.Join(in, "a.jpg"), filepath.Join(in, "b.tgz") + "!/c.jpg"}) ||
+		NeedsSequentialRead([]string{filepath.Join(in, "b.tar") + "!/c.jpg"}) {
+		t.Error("Only compressed tar entries need sequential reads")
+	}
+}
+
+func TestProcessFile_ImportsFromArchives(t *testing.T) {
+	tempDir := t.TempDir()
+	exifPath := filepath.Join(tempDir, "exif.jpg")
+	exifDate := time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC)
+	writeExifJPEG(t, exifPath, exifDate)
+	exifData, err := os.ReadFile(exifPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entryTime := time.Date(2022, 1, 2, 12, 0, 0, 0, time.UTC)
+	bigData := []byte(strings.Repeat("video frame ", 1000))
+
+	for _, archiveName := range []string{"photos.zip", "photos.tar", "photos.tar.gz"} {
+		t.Run(archiveName, func(t *testing.T) {
+			defer CloseArchives()
+
+			dir := t.TempDir()
+			library := filepath.Join(dir, "library")
+			inputDir := filepath.Join(dir, "input")
+			os.MkdirAll(inputDir, 0755)
+
+			archivePath := filepath.Join(inputDir, archiveName)
+			writeTestArchive(t, archivePath, []testEntry{
+				{"Takeout/", nil, entryTime},
+				{"Takeout/IMG_1.jpg", exifData, entryTime},
+				{"Takeout/photo.jpg", []byte("no exif here"), entryTime},
+				{"Takeout/photo.xmp", []byte("<xmp/>"), entryTime},
+				{"Takeout/clip.mp4", bigData, entryTime},
+				{"Takeout/notes.txt", []byte("ignored"), entryTime},
+			})
+
+			cfg := &Config{
+				User:       "user",
+				Library:    library,
+				VideoLib:   library,
+				ImageExt:   []string{".jpg"},
+				VideoExt:   []string{".mp4"},
+				SidecarExt: []string{".xmp"},
+				MoveFiles:  true,
+			}
+
+			scan, err := ScanMediaFiles(inputDir, cfg)
+			if err != nil {
+				t.Fatalf("ScanMediaFiles failed: %v", err)
+			}
+			run := NewImportRun(scan)
+			files := run.Files
+			sort.Strings(files)
+			want := []string{
+				archivePath + "!/Takeout/IMG_1.jpg",
+				archivePath + "!/Takeout/clip.mp4",
+				archivePath + "!/Takeout/photo.jpg",
+			}
+			if strings.Join(files, "\n") != strings.Join(want, "\n") {
+				t.Fatalf("Expected %v, got %v", want, files)
+			}
+
+			session, err := NewImportSession(library, library, cfg.User, inputDir)
+			if err != nil {
+				t.Fatalf("NewImportSession failed: %v", err)
+			}
+			// Out of archive order on purpose: compressed tars must rewind
+			for _, i := range []int{2, 0, 1} {
+				if err := ProcessFile(files[i], cfg, run, cfg.User, false, session, true); err != nil {
+					t.Fatalf("ProcessFile(%s) failed: %v", files[i], err)
+				}
+			}
+			if removed, _ := session.RemoveQueuedSources(); removed != 0 {
+				t.Errorf("Expected no source removals for archive entries, got %d", removed)
+			}
+			session.Close()
+
+			expected := map[string][]byte{
+				filepath.Join(library, "user", "2023", "05", "06", "IMG_1.jpg"):  exifData,
+				filepath.Join(library, "user", "noexif", "2022-01", "photo.jpg"): []byte("no exif here"),
+				filepath.Join(library, "user", "noexif", "2022-01", "photo.xmp"): []byte("<xmp/>"),
+				filepath.Join(library, "user", "noexif", "2022-01", "clip.mp4"):  bigData,
+			}
+			for path, data := range expected {
+				got, err := os.ReadFile(path)
+				if err != nil {
+					t.Errorf("Expected %s: %v", path, err)
+					continue
+				}
+				if string(got) != string(data) {
+					t.Errorf("Content mismatch for %s", path)
+				}
+			}
+			if _, err := os.Stat(archivePath); err != nil {
+				t.Errorf("Archive must be left in place with --move: %v", err)
+			}
+
+			events, err := ReadManifest(filepath.Join(session.SessionDir, "manifest.jsonl"))
+			if err != nil {
+				t.Fatalf("ReadManifest failed: %v", err)
+			}
+			copied := 0
+			for _, event := range events {
+				if event.Event != "copied" {
+					continue
+				}
+				copied++
+				if !strings.HasPrefix(event.Src, archivePath+"!/Takeout/") {
+					t.Errorf("Expected archive entry src, got %s", event.Src)
+				}
+				if event.Method != TransferCopy {
+					t.Errorf("Expected %s to be copied, got method %q", event.Src, event.Method)
+				}
+				if event.Hash == "" {
+					t.Errorf("Expected a hash for %s", event.Src)
+				}
+			}
+			if copied != 3 {
+				t.Errorf("Expected 3 copied events, got %d", copied)
+			}
+		})
+	}
+}
+
+func TestOpenSource_SpoolsLargeCompressedEntries(t *testing.T) {
+	spoolDir := ArchiveSpoolDir(t.TempDir())
+	SetArchiveSpoolDir(spoolDir)
+	defer SetArchiveSpoolDir("")
+	defer CloseArchives()
+
+	archivePath := filepath.Join(t.TempDir(), "big.tgz")
+	big := []byte(strings.Repeat("x", maxBufferedEntry+1))
+	writeTestArchive(t, archivePath, []testEntry{
+		{"small.jpg", []byte("small"), time.Now()},
+		{"big.mp4", big, time.Now()},
+	})
+
+	for _, name := range []string{"big.mp4", "small.jpg", "big.mp4"} {
+		f, err := openSource(archivePath + "!/" + name)
+		if err != nil {
+			t.Fatalf("openSource(%s) failed: %v", name, err)
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("read %s failed: %v", name, err)
+		}
+		if name == "big.mp4" && len(data) != len(big) {
+			t.Errorf("Expected %d bytes, got %d", len(big), len(data))
+		}
+		if name == "small.jpg" && string(data) != "small" {
+			t.Errorf("Expected small entry content, got %q", data)
+		}
+	}
+
+	a, err := getArchive(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spool := a.cachedFile
+	if spool == "" || filepath.Dir(spool) != spoolDir {
+		t.Fatalf("Expected the large entry spooled in %s, got %q", spoolDir, spool)
+	}
+	CloseArchives()
+	if _, err := os.Stat(spool); !os.IsNotExist(err) {
+		t.Errorf("Expected spool file to be removed, got %v", err)
+	}
+	if _, err := os.Stat(spoolDir); !os.IsNotExist(err) {
+		t.Errorf("Expected the empty spool folder removed, got %v", err)
+	}
+}
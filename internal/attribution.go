@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// UserRule files matching media under User instead of the import's --user. Every
+// criterion set must match; rules are tried in order and the first match wins.
+// Path uses the scan pattern syntax; Filename is a glob on the file name alone.
+type UserRule struct {
+	User     string `mapstructure:"user"`
+	Make     string `mapstructure:"make"`
+	Model    string `mapstructure:"model"`
+	Serial   string `mapstructure:"serial"`
+	Path     string `mapstructure:"path"`     // e.g. "Phones/Alice", "*/Camera Uploads/Bob"
+	Filename string `mapstructure:"filename"` // e.g. "PXL_*", "signal-*"
+}
+
+// userAttribution is the user a file is filed under and the rule that chose it, if any
+type userAttribution struct {
+	User string
+	Rule string // UserRule.String() of the matching rule, "" for the fallback user
+}
+
+// usesCamera reports whether the rule needs the file's EXIF camera tags
+func (r UserRule) usesCamera() bool {
+	return r.Make != "" || r.Model != "" || r.Serial != ""
+}
+
+// Validate checks the rule names a safe user folder and at least one criterion
+func (r UserRule) Validate() error {
+	user := strings.TrimSpace(r.User)
+	if user == "" || user == "." || user == ".." || strings.ContainsAny(user, `/\`) {
+		return fmt.Errorf("user_rule user %q must be a plain folder name", r.User)
+	}
+	if !r.usesCamera() && r.Path == "" && r.Filename == "" {
+		return fmt.Errorf("user_rule for %q needs a make, model, serial, path or filename", r.User)
+	}
+	for _, pattern := range []string{r.Path, r.Filename} {
+		if _, err := path.Match(strings.ToLower(filepath.ToSlash(pattern)), ""); err != nil {
+			return fmt.Errorf("invalid user_rule pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matches reports whether the rule applies to the file at rel in the import folder.
+// camera is read lazily, only for rules with camera criteria.
+func (r UserRule) matches(rel string, camera func() cameraInfo) bool {
+	if r.Path != "" && !matchScanPattern(r.Path, rel) {
+		return false
+	}
+	if r.Filename != "" {
+		if ok, _ := path.Match(strings.ToLower(r.Filename), strings.ToLower(path.Base(rel))); !ok {
+			return false
+		}
+	}
+	if r.usesCamera() {
+		info := camera()
+		if info.Make == "" && info.Model == "" && info.Serial == "" {
+			return false
+		}
+		if !(ClockOffset{Make: r.Make, Model: r.Model, Serial: r.Serial}).matches(info) {
+			return false
+		}
+	}
+	return true
+}
+
+// String describes the rule's criteria, e.g. `path="Phones/Alice" make="Apple"`
+func (r UserRule) String() string {
+	var parts []string
+	for _, c := range []struct{ key, value string }{
+		{"make", r.Make}, {"model", r.Model}, {"serial", r.Serial},
+		{"path", r.Path}, {"filename", r.Filename},
+	} {
+		if c.value != "" {
+			parts = append(parts, fmt.Sprintf("%s=%q", c.key, c.value))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ValidateUserRules checks all configured rules
+func (c *Config) ValidateUserRules() error {
+	for _, rule := range c.UserRules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveUser returns the user src is filed under: the first matching user rule,
+// or fallback (the --user or config default). Rule paths are relative to inputDir.
+func resolveUser(src string, cfg *Config, inputDir, fallback string) userAttribution {
+	if len(cfg.UserRules) == 0 {
+		return userAttribution{User: fallback}
+	}
+
+	rel := scanRelPath(inputDir, src)
+	var camera *cameraInfo
+	readCamera := func() cameraInfo {
+		if camera == nil {
+			info := getCameraInfo(src, cfg)
+			camera = &info
+		}
+		return *camera
+	}
+	for i := range cfg.UserRules {
+		if rule := &cfg.UserRules[i]; rule.matches(rel, readCamera) {
+			return userAttribution{User: strings.TrimSpace(rule.User), Rule: rule.String()}
+		}
+	}
+	return userAttribution{User: fallback}
+}
+
+// assetUser resolves the user for src, using the group lead so a RAW+JPEG pair or
+// Live Photo always lands under one user
+func assetUser(src string, cfg *Config, run *ImportRun, fallback string) userAttribution {
+	if group := run.Groups[src]; group != nil {
+		src = group.Lead
+	}
+	return resolveUser(src, cfg, run.InputDir, fallback)
+}
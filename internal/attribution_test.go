@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUserRuleValidate(t *testing.T) {
+	for _, bad := range []UserRule{
+		{User: "alice"},
+		{User: "", Path: "Phones/Alice"},
+		{User: "../alice", Path: "Phones/Alice"},
+		{User: "alice", Filename: "[unclosed"},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", bad)
+		}
+	}
+	if err := (UserRule{User: "alice", Make: "Apple"}).Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestResolveUser(t *testing.T) {
+	inputDir := t.TempDir()
+	for _, dir := range []string{"Phones/Alice", "Phones/Bob", "Camera"} {
+		os.MkdirAll(filepath.Join(inputDir, dir), 0755)
+	}
+	date := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	alice := filepath.Join(inputDir, "Phones", "Alice", "IMG_1.jpg")
+	bob := filepath.Join(inputDir, "Phones", "Bob", "PXL_20230501_100000.jpg")
+	camera := filepath.Join(inputDir, "Camera", "DSC_1.jpg")
+	unknown := filepath.Join(inputDir, "Camera", "DSC_2.jpg")
+	writeCameraJPEG(t, alice, date, "Apple", "iPhone 12")
+	writeCameraJPEG(t, bob, date, "Google", "Pixel 7")
+	writeCameraJPEG(t, camera, date, "Nikon", "D750")
+	writeCameraJPEG(t, unknown, date, "Canon", "Canon EOS 80D")
+
+	cfg := &Config{
+		ImageExt: []string{".jpg"},
+		UserRules: []UserRule{
+			{User: "alice", Path: "Phones/Alice"},
+			{User: "bob", Filename: "pxl_*"},
+			{User: "carol", Make: "nikon", Model: "D750"},
+		},
+	}
+
+	tests := []struct {
+		src, want string
+		matched   bool
+	}{
+		{alice, "alice", true},
+		{bob, "bob", true},
+		{camera, "carol", true},
+		{unknown, "family", false},
+	}
+	for _, tt := range tests {
+		got := resolveUser(tt.src, cfg, inputDir, "family")
+		if got.User != tt.want || (got.Rule != "") != tt.matched {
+			t.Errorf("resolveUser(%s) = %+v, want %s", filepath.Base(tt.src), got, tt.want)
+		}
+	}
+}
+
+func TestProcessFile_UserRule(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	inputDir := filepath.Join(tempDir, "input")
+	os.MkdirAll(filepath.Join(inputDir, "Alice"), 0755)
+
+	date := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	src := filepath.Join(inputDir, "Alice", "IMG_1.jpg")
+	writeCameraJPEG(t, src, date, "Apple", "iPhone 12")
+	other := filepath.Join(inputDir, "IMG_2.jpg")
+	writeCameraJPEG(t, other, date, "Apple", "iPhone 12")
+
+	cfg := &Config{
+		User:      "family",
+		Library:   library,
+		VideoLib:  library,
+		ImageExt:  []string{".jpg"},
+		VideoExt:  []string{".mp4"},
+		Timezone:  "UTC",
+		UserRules: []UserRule{{User: "alice", Path: "Alice"}},
+	}
+	scan, err := ScanMediaFiles(inputDir, cfg)
+	if err != nil {
+		t.Fatalf("ScanMediaFiles failed: %v", err)
+	}
+	run := NewImportRun(scan)
+	files := run.Files
+
+	session, err := NewImportSession(library, library, cfg.User, inputDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	session.LogSessionStart(len(files))
+	for _, f := range files {
+		if err := ProcessFile(f, cfg, run, cfg.User, false, session, true); err != nil {
+			t.Fatalf("ProcessFile(%s) failed: %v", f, err)
+		}
+	}
+	session.Close()
+
+	aliceDest := filepath.Join(library, "alice", "2023", "05", "01", "IMG_1.jpg")
+	if _, err := os.Stat(aliceDest); err != nil {
+		t.Errorf("Expected file under the rule's user: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(library, "family", "2023", "05", "01", "IMG_2.jpg")); err != nil {
+		t.Errorf("Expected unmatched file under the default user: %v", err)
+	}
+
+	events, err := ReadManifest(filepath.Join(session.SessionDir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	for _, event := range events {
+		if event.Event != "copied" {
+			continue
+		}
+		if event.Dest == aliceDest && (event.User != "alice" || event.UserRule != `path="Alice"`) {
+			t.Errorf("Expected user rule in manifest, got %+v", event)
+		}
+		if event.Dest != aliceDest && event.UserRule != "" {
+			t.Errorf("Expected no user rule for %s, got %+v", event.Src, event)
+		}
+	}
+
+	// Undo removes the rule user's empty date folders but keeps the user folder
+	if _, err := UndoImportSession(library, session.ID, false); err != nil {
+		t.Fatalf("UndoImportSession failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(library, "alice", "2023")); !os.IsNotExist(err) {
+		t.Errorf("Expected empty date folders removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(library, "alice")); err != nil {
+		t.Errorf("Expected user folder kept: %v", err)
+	}
+}
@@ -8,7 +8,7 @@ import (
 )
 
 // CreateBrowseStructure creates a .browse folder with hardlinks organized by file type
-func CreateBrowseStructure(results *AnalyticsResults) error {
+func CreateBrowseStructure(results *AnalyticsResults, cfg *Config) error {
 	browseDir := filepath.Join(results.FolderPath, ".browse")
 
 	// Create .browse directory
@@ -53,8 +53,7 @@ func CreateBrowseStructure(results *AnalyticsResults) error {
 			}
 
 			// Check if file belongs to this category
-			ext := strings.ToLower(filepath.Ext(path))
-			if categorizeFile(ext) != category {
+			if categorizeFile(path, cfg) != category {
 				return nil
 			}
 
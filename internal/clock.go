@@ -0,0 +1,216 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ClockOffset shifts the EXIF dates of one camera whose clock was set wrong. Make,
+// Model and Serial are matched case-insensitively; empty ones match any camera.
+type ClockOffset struct {
+	Make   string `mapstructure:"make"`
+	Model  string `mapstructure:"model"`
+	Serial string `mapstructure:"serial"`
+	Offset string `mapstructure:"offset"` // e.g. "+2h", "-1y3d", "+1h30m"
+
+	shift clockShift
+}
+
+// clockShift is a parsed offset. Years and days are calendar steps, so "+1y" lands
+// on the same date a year later.
+type clockShift struct {
+	years    int
+	days     int
+	duration time.Duration
+}
+
+var clockOffsetPattern = regexp.MustCompile(`^([+-]?)((?:\d+(?:y|d|h|m|s))+)$`)
+var clockOffsetPart = regexp.MustCompile(`(\d+)(y|d|h|m|s)`)
+
+// parseClockShift parses offsets such as "+2h", "-1y3d" or "+0h45m10s"
+func parseClockShift(offset string) (clockShift, error) {
+	m := clockOffsetPattern.FindStringSubmatch(strings.ReplaceAll(strings.TrimSpace(offset), " ", ""))
+	if m == nil {
+		return clockShift{}, fmt.Errorf("invalid clock offset %q (use e.g. +2h, -1y3d, +1h30m)", offset)
+	}
+
+	sign := 1
+	if m[1] == "-" {
+		sign = -1
+	}
+	var shift clockShift
+	for _, part := range clockOffsetPart.FindAllStringSubmatch(m[2], -1) {
+		n, err := strconv.Atoi(part[1])
+		if err != nil {
+			return clockShift{}, fmt.Errorf("invalid clock offset %q: %w", offset, err)
+		}
+		switch part[2] {
+		case "y":
+			shift.years += sign * n
+		case "d":
+			shift.days += sign * n
+		case "h":
+			shift.duration += time.Duration(sign*n) * time.Hour
+		case "m":
+			shift.duration += time.Duration(sign*n) * time.Minute
+		case "s":
+			shift.duration += time.Duration(sign*n) * time.Second
+		}
+	}
+	return shift, nil
+}
+
+func (s clockShift) apply(t time.Time) time.Time {
+	return t.AddDate(s.years, 0, s.days).Add(s.duration)
+}
+
+// ParseClockOffsetFlag parses a command line rule "Make[/Model[/Serial]]=offset",
+// e.g. "Canon/Canon EOS 80D=+2h"
+func ParseClockOffsetFlag(value string) (ClockOffset, error) {
+	camera, offset, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(camera) == "" {
+		return ClockOffset{}, fmt.Errorf("invalid --offset %q (use Make/Model=+2h)", value)
+	}
+	parts := strings.SplitN(camera, "/", 3)
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	rule := ClockOffset{
+		Make:   strings.TrimSpace(parts[0]),
+		Model:  strings.TrimSpace(parts[1]),
+		Serial: strings.TrimSpace(parts[2]),
+		Offset: strings.TrimSpace(offset),
+	}
+	if err := rule.parse(); err != nil {
+		return ClockOffset{}, err
+	}
+	return rule, nil
+}
+
+// parse validates the rule and caches its parsed offset
+func (o *ClockOffset) parse() error {
+	if o.Make == "" && o.Model == "" && o.Serial == "" {
+		return fmt.Errorf("clock offset %q must name a camera make, model or serial", o.Offset)
+	}
+	shift, err := parseClockShift(o.Offset)
+	if err != nil {
+		return err
+	}
+	o.shift = shift
+	return nil
+}
+
+// matches reports whether the rule applies to camera
+func (o ClockOffset) matches(camera cameraInfo) bool {
+	match := func(want, got string) bool {
+		return want == "" || strings.EqualFold(strings.TrimSpace(want), got)
+	}
+	return match(o.Make, camera.Make) && match(o.Model, camera.Model) && match(o.Serial, camera.Serial)
+}
+
+// String describes the rule in --offset syntax
+func (o ClockOffset) String() string {
+	camera := strings.TrimRight(strings.Join([]string{o.Make, o.Model, o.Serial}, "/"), "/")
+	return camera + "=" + o.Offset
+}
+
+// ValidateClockOffsets parses all configured rules
+func (c *Config) ValidateClockOffsets() error {
+	for i := range c.ClockOffsets {
+		if err := c.ClockOffsets[i].parse(); err != nil {
+			return fmt.Errorf("invalid clock_offset: %w", err)
+		}
+	}
+	return nil
+}
+
+// correctCameraClock shifts an EXIF date by the first rule matching the camera of
+// filePath. Returns the rule applied, if any.
+func correctCameraClock(filePath string, t time.Time, cfg *Config) (time.Time, *ClockOffset) {
+	if len(cfg.ClockOffsets) == 0 {
+		return t, nil
+	}
+	camera := getCameraInfo(filePath, cfg)
+	if camera.Make == "" && camera.Model == "" && camera.Serial == "" {
+		return t, nil
+	}
+	for i := range cfg.ClockOffsets {
+		if rule := &cfg.ClockOffsets[i]; rule.matches(camera) {
+			return rule.shift.apply(t), rule
+		}
+	}
+	return t, nil
+}
+
+// SuggestClockOffset compares pairs of files showing the same moment, the first taken
+// by the camera with the wrong clock and the second by a reference device (e.g. a phone),
+// and returns a rule for the camera using the median difference, rounded to the minute.
+func SuggestClockOffset(pairs [][2]string, cfg *Config) (ClockOffset, error) {
+	if len(pairs) == 0 {
+		return ClockOffset{}, fmt.Errorf("no file pairs to compare")
+	}
+
+	var camera cameraInfo
+	var diffs []time.Duration
+	for _, pair := range pairs {
+		cameraTime, _, err := captureTimestamp(pair[0], cfg.UseExifTool, nil)
+		if err != nil {
+			return ClockOffset{}, fmt.Errorf("no capture date in %s: %w", pair[0], err)
+		}
+		referenceTime, _, err := captureTimestamp(pair[1], cfg.UseExifTool, nil)
+		if err != nil {
+			return ClockOffset{}, fmt.Errorf("no capture date in %s: %w", pair[1], err)
+		}
+
+		info := getCameraInfo(pair[0], cfg)
+		if len(diffs) == 0 {
+			camera = info
+		} else if info != camera {
+			return ClockOffset{}, fmt.Errorf("%s was taken by %s %s, not %s %s", pair[0], info.Make, info.Model, camera.Make, camera.Model)
+		}
+		// Both read as wall clocks, so the difference is what the camera clock is off by
+		diffs = append(diffs, wallClockIn(referenceTime, time.UTC).Sub(wallClockIn(cameraTime, time.UTC)))
+	}
+	if camera.Make == "" && camera.Model == "" && camera.Serial == "" {
+		return ClockOffset{}, fmt.Errorf("%s has no camera make or model to attach the offset to", pairs[0][0])
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i] < diffs[j] })
+	median := diffs[len(diffs)/2].Round(time.Minute)
+
+	rule := ClockOffset{Make: camera.Make, Model: camera.Model, Offset: formatClockShift(median)}
+	if err := rule.parse(); err != nil {
+		return ClockOffset{}, err
+	}
+	return rule, nil
+}
+
+// formatClockShift writes a duration in offset syntax using days, hours and minutes
+func formatClockShift(d time.Duration) string {
+	sign := "+"
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+
+	var b strings.Builder
+	b.WriteString(sign)
+	if days > 0 {
+		fmt.Fprintf(&b, "%dd", days)
+	}
+	if hours > 0 {
+		fmt.Fprintf(&b, "%dh", hours)
+	}
+	if minutes > 0 || (days == 0 && hours == 0) {
+		fmt.Fprintf(&b, "%dm", minutes)
+	}
+	return b.String()
+}
@@ -0,0 +1,210 @@
+package internal
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCameraJPEG writes a minimal JPEG with EXIF Make, Model and DateTimeOriginal
+func writeCameraJPEG(t *testing.T, path string, date time.Time, cameraMake, cameraModel string) {
+	t.Helper()
+
+	le := binary.LittleEndian
+	type entry struct {
+		tag   uint16
+		value string
+	}
+	ifd0 := []entry{{0x010f, cameraMake}, {0x0110, cameraModel}}
+	exifIFD := []entry{{0x9003, date.Format("2006:01:02 15:04:05")}}
+
+	ifd0Size := 2 + 12*(len(ifd0)+1) + 4
+	exifStart := 8 + ifd0Size
+	dataStart := exifStart + 2 + 12*len(exifIFD) + 4
+
+	var data []byte
+	putIFD := func(entries []entry, extra []byte) []byte {
+		ifd := make([]byte, 2, 2+12*len(entries)+16)
+		le.PutUint16(ifd, uint16(len(entries)+len(extra)/12))
+		for _, e := range entries {
+			b := make([]byte, 12)
+			le.PutUint16(b[0:], e.tag)
+			le.PutUint16(b[2:], 2)
+			le.PutUint32(b[4:], uint32(len(e.value)+1))
+			le.PutUint32(b[8:], uint32(dataStart+len(data)))
+			data = append(data, []byte(e.value+"\x00")...)
+			ifd = append(ifd, b...)
+		}
+		ifd = append(ifd, extra...)
+		return append(ifd, 0, 0, 0, 0)
+	}
+
+	pointer := make([]byte, 12)
+	le.PutUint16(pointer[0:], 0x8769)
+	le.PutUint16(pointer[2:], 4)
+	le.PutUint32(pointer[4:], 1)
+	le.PutUint32(pointer[8:], uint32(exifStart))
+
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = append(tiff, putIFD(ifd0, pointer)...)
+	tiff = append(tiff, putIFD(exifIFD, nil)...)
+	tiff = append(tiff, data...)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	jpeg := []byte{0xFF, 0xD8, 0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(jpeg[4:], uint16(len(payload)+2))
+	jpeg = append(jpeg, payload...)
+	jpeg = append(jpeg, 0xFF, 0xD9)
+
+	if err := os.WriteFile(path, jpeg, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseClockShift(t *testing.T) {
+	base := time.Date(2020, 2, 28, 22, 0, 0, 0, time.UTC)
+	tests := []struct {
+		offset string
+		want   time.Time
+	}{
+		{"+2h", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"-1h30m", time.Date(2020, 2, 28, 20, 30, 0, 0, time.UTC)},
+		{"+1y", time.Date(2021, 2, 28, 22, 0, 0, 0, time.UTC)},
+		{"-3y2d", time.Date(2017, 2, 26, 22, 0, 0, 0, time.UTC)},
+		{"10s", time.Date(2020, 2, 28, 22, 0, 10, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		shift, err := parseClockShift(tt.offset)
+		if err != nil {
+			t.Errorf("parseClockShift(%q) failed: %v", tt.offset, err)
+			continue
+		}
+		if got := shift.apply(base); !got.Equal(tt.want) {
+			t.Errorf("parseClockShift(%q) applied = %s, want %s", tt.offset, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "2", "+2x", "2h+1m", "+h"} {
+		if _, err := parseClockShift(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestParseClockOffsetFlag(t *testing.T) {
+	rule, err := ParseClockOffsetFlag("Canon/Canon EOS 80D=+2h")
+	if err != nil {
+		t.Fatalf("ParseClockOffsetFlag failed: %v", err)
+	}
+	if rule.Make != "Canon" || rule.Model != "Canon EOS 80D" || rule.Serial != "" || rule.Offset != "+2h" {
+		t.Errorf("Unexpected rule %+v", rule)
+	}
+	if !rule.matches(cameraInfo{Make: "CANON", Model: "canon eos 80d", Serial: "123"}) {
+		t.Error("Expected case-insensitive match ignoring serial")
+	}
+	if rule.matches(cameraInfo{Make: "Canon", Model: "Canon EOS R5"}) {
+		t.Error("Expected other model not to match")
+	}
+	if rule.String() != "Canon/Canon EOS 80D=+2h" {
+		t.Errorf("Unexpected String() %q", rule.String())
+	}
+
+	for _, bad := range []string{"+2h", "=+2h", "Canon", "Canon=soon"} {
+		if _, err := ParseClockOffsetFlag(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestProcessFile_ClockOffset(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	inputDir := filepath.Join(tempDir, "input")
+	os.MkdirAll(inputDir, 0755)
+
+	rule, err := ParseClockOffsetFlag("Canon/Canon EOS 80D=+2h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{
+		User:         "user",
+		Library:      library,
+		VideoLib:     library,
+		ImageExt:     []string{".jpg"},
+		VideoExt:     []string{".mp4"},
+		ClockOffsets: []ClockOffset{rule},
+		Timezone:     "UTC",
+	}
+
+	// The camera clock says 23:00, two hours behind: the photo belongs to the next day
+	src := filepath.Join(inputDir, "IMG_1.jpg")
+	writeCameraJPEG(t, src, time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC), "Canon", "Canon EOS 80D")
+	other := filepath.Join(inputDir, "IMG_2.jpg")
+	writeCameraJPEG(t, other, time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC), "Apple", "iPhone 12")
+
+	session, err := NewImportSession(library, library, cfg.User, inputDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	for _, f := range []string{src, other} {
+		if err := ProcessFile(f, cfg, nil, cfg.User, false, session, true); err != nil {
+			t.Fatalf("ProcessFile(%s) failed: %v", f, err)
+		}
+	}
+	session.Close()
+
+	if _, err := os.Stat(filepath.Join(library, "user", "2024", "01", "01", "IMG_1.jpg")); err != nil {
+		t.Errorf("Expected corrected photo under 2024/01/01: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(library, "user", "2023", "12", "31", "IMG_2.jpg")); err != nil {
+		t.Errorf("Expected other camera untouched under 2023/12/31: %v", err)
+	}
+
+	events, err := ReadManifest(filepath.Join(session.SessionDir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	for _, event := range events {
+		if event.Event != "copied" {
+			continue
+		}
+		switch filepath.Base(event.Src) {
+		case "IMG_1.jpg":
+			if event.OriginalDate != "2023-12-31T23:00:00Z" || event.CaptureDate != "2024-01-01T01:00:00Z" || event.ClockOffset != "Canon/Canon EOS 80D=+2h" {
+				t.Errorf("Expected original and corrected dates, got %+v", event)
+			}
+		case "IMG_2.jpg":
+			if event.OriginalDate != "" || event.ClockOffset != "" {
+				t.Errorf("Expected no correction for another camera, got %+v", event)
+			}
+		}
+	}
+}
+
+func TestSuggestClockOffset(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := &Config{ImageExt: []string{".jpg"}}
+
+	var pairs [][2]string
+	for i, skew := range []time.Duration{-3 * time.Hour, -3*time.Hour + 20*time.Second, -3*time.Hour - 40*time.Second} {
+		moment := time.Date(2022, 8, 10, 12, i, 0, 0, time.UTC)
+		camera := filepath.Join(tempDir, "cam"+string(rune('a'+i))+".jpg")
+		reference := filepath.Join(tempDir, "ref"+string(rune('a'+i))+".jpg")
+		writeCameraJPEG(t, camera, moment.Add(skew), "Nikon", "D750")
+		writeCameraJPEG(t, reference, moment, "Apple", "iPhone 12")
+		pairs = append(pairs, [2]string{camera, reference})
+	}
+
+	rule, err := SuggestClockOffset(pairs, cfg)
+	if err != nil {
+		t.Fatalf("SuggestClockOffset failed: %v", err)
+	}
+	if rule.String() != "Nikon/D750=+3h" {
+		t.Errorf("Expected Nikon/D750=+3h, got %s", rule)
+	}
+	if formatClockShift(-(26*time.Hour+5*time.Minute)) != "-1d2h5m" || formatClockShift(0) != "+0m" {
+		t.Errorf("Unexpected formatClockShift output")
+	}
+}
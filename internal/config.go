@@ -15,8 +15,27 @@ type Config struct {
 	VideoLib     string   `mapstructure:"videolibrary"`
 	ImageExt     []string `mapstructure:"image_extensions"`
 	VideoExt     []string `mapstructure:"video_extensions"`
+	SidecarExt   []string `mapstructure:"sidecar_extensions"` // Companion files that follow their media file
 	UseExifTool  bool
-	UseHardlinks bool // Use hardlinks instead of copying files
+	UseHardlinks bool   // Use hardlinks instead of copying files
+	MoveFiles    bool   // Remove sources after verified import (rename on same filesystem)
+	Reflink      string `mapstructure:"reflink"`     // Copy-on-write clones: "never", "always" or "auto"
+	Verify       string `mapstructure:"verify"`      // Copy verification: "dest", "full" or "none"
+	Mtime        string `mapstructure:"mtime"`       // Library file modification time: "source" or "capture"
+	WriteDates   string `mapstructure:"write_dates"` // Write Takeout and file name dates back: "", "file" or "xmp"
+	OnConflict   string `mapstructure:"on_conflict"` // Destination holds other content: "suffix", "skip", "keep-best", "replace" or "fail"
+	Jobs         int    `mapstructure:"jobs"`        // Number of parallel import workers
+	UseHashIndex bool   `mapstructure:"hash_index"`  // Skip content already anywhere in the library
+	Timezone     string `mapstructure:"timezone"`    // Library timezone for day folders, e.g. "Europe/Rome" ("" = system)
+
+	Layout LayoutConfig `mapstructure:"layout"` // Destination path templates
+	Naming NamingConfig `mapstructure:"naming"` // Optional file renaming on import
+	Scan   ScanConfig   `mapstructure:"scan"`   // Include/exclude rules for the import scan
+
+	NearDuplicates NearDuplicateConfig `mapstructure:"near_duplicates"` // Perceptual matching of recompressed and resized images
+
+	ClockOffsets []ClockOffset `mapstructure:"clock_offset"` // Per-camera EXIF date corrections
+	UserRules    []UserRule    `mapstructure:"user_rule"`    // Per-file user attribution
 }
 
 func LoadConfig() (*Config, error) {
@@ -42,6 +61,21 @@ func LoadConfig() (*Config, error) {
 	viper.SetDefault("video_extensions", []string{
 		".mp4", ".mov", ".avi", ".mkv", ".webm", ".flv", ".wmv", ".m4v",
 	})
+	viper.SetDefault("sidecar_extensions", []string{
+		".xmp", ".aae", ".thm", ".lrv", ".srt",
+	})
+	viper.SetDefault("jobs", 1)
+	viper.SetDefault("hash_index", false)
+	viper.SetDefault("verify", "dest")
+	viper.SetDefault("mtime", MtimeSource)
+	viper.SetDefault("on_conflict", ConflictSuffix)
+	viper.SetDefault("reflink", ReflinkNever)
+	viper.SetDefault("layout.image", DefaultLayout)
+	viper.SetDefault("layout.image_noexif", DefaultLayoutNoExif)
+	viper.SetDefault("layout.video", DefaultLayout)
+	viper.SetDefault("layout.video_noexif", DefaultLayoutNoExif)
+	viper.SetDefault("scan.default_excludes", true)
+	viper.SetDefault("near_duplicates.distance", DefaultNearDistance)
 
 	if err := viper.ReadInConfig(); err != nil {
 		// Config file not found; that's OK, just use defaults
@@ -65,6 +99,35 @@ func LoadConfig() (*Config, error) {
 	for i, ext := range cfg.VideoExt {
 		cfg.VideoExt[i] = strings.ToLower(ext)
 	}
+	for i, ext := range cfg.SidecarExt {
+		cfg.SidecarExt[i] = strings.ToLower(ext)
+	}
+	// An empty reflink setting, as older configs wrote it, means no clones
+	if cfg.Reflink == "" {
+		cfg.Reflink = ReflinkNever
+	}
+
+	if err := cfg.Layout.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := cfg.Naming.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := cfg.Scan.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := cfg.NearDuplicates.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := cfg.SetTimezone(cfg.Timezone); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := cfg.ValidateClockOffsets(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := cfg.ValidateUserRules(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
 
 	return &cfg, nil
 }
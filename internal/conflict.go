@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A conflict is a destination that already holds different content. Config.OnConflict
+// decides what the import does about it. Replacing never deletes anything: the
+// displaced library file is kept under LIBRARY/superseded/ at its library path, the
+// conflict manifest event records where, and undo puts it back.
+
+// Conflict policies for Config.OnConflict
+const (
+	ConflictSuffix   = "suffix"    // Keep both, the incoming file under a timestamp-suffixed name
+	ConflictSkip     = "skip"      // Keep the library file and leave the incoming one out
+	ConflictKeepBest = "keep-best" // Keep the higher quality file at the name; suffix when they can't be compared or aren't the same shot
+	ConflictReplace  = "replace"   // Put the incoming file at the name
+	ConflictFail     = "fail"      // Report an error for the incoming file
+)
+
+// Conflict resolutions, recorded in conflict manifest events
+const (
+	ResolutionSuffixed = "suffixed" // Incoming file saved under a timestamp-suffixed name
+	ResolutionSkipped  = "skipped"  // Library file kept, incoming file not imported
+	ResolutionReplaced = "replaced" // Incoming file at the name, library file moved to superseded/
+	ResolutionFailed   = "failed"   // Import of the incoming file refused
+)
+
+// supersededDir is the folder in each library root holding replaced library files
+const supersededDir = "superseded"
+
+// qualityNames spell QualityResult values in manifests and plans
+var qualityNames = map[QualityResult]string{
+	HIGHER: "higher", LOWER: "lower", EQUAL: "equal", UNKNOWN: "unknown",
+}
+
+// qualityDifferent is the keep-best quality of files that aren't the same shot
+const qualityDifferent = "different"
+
+// fileConflict is a destination holding other content and what the policy made of it
+type fileConflict struct {
+	Dest         string // The contested library path
+	ExistingHash string // Content hash of the library file at Dest
+	Policy       string
+	Resolution   string
+	Quality      string // keep-best: how the incoming file compared, one of qualityNames
+	Superseded   string // Where the displaced library file is kept (replaced only)
+}
+
+// resolveConflict applies policy to src, an incoming file whose destination dest holds
+// content hashed existingHash. keep-best replaces a library file of lower quality,
+// skips one of equal or higher quality, and keeps both when the comparators can't tell
+// (videos of different lengths, formats without readable dimensions) or the two aren't
+// the same shot.
+func resolveConflict(src, dest, existingHash string, fileType FileType, policy string, cfg *Config) *fileConflict {
+	c := &fileConflict{Dest: dest, ExistingHash: existingHash, Policy: policy, Resolution: ResolutionSuffixed}
+	switch policy {
+	case ConflictSkip:
+		c.Resolution = ResolutionSkipped
+	case ConflictReplace:
+		c.Resolution = ResolutionReplaced
+	case ConflictFail:
+		c.Resolution = ResolutionFailed
+	case ConflictKeepBest:
+		if !sameShot(src, dest, fileType, cfg) {
+			c.Quality = qualityDifferent
+			break
+		}
+		quality := compareImageQuality(src, dest)
+		if fileType == TypeVideo {
+			quality = compareVideoQuality(src, dest)
+		}
+		c.Quality = qualityNames[quality]
+		switch quality {
+		case HIGHER:
+			c.Resolution = ResolutionReplaced
+		case LOWER, EQUAL:
+			c.Resolution = ResolutionSkipped
+		}
+	default:
+		c.Policy = ConflictSuffix
+	}
+	return c
+}
+
+// reason describes the decision for import output
+func (c *fileConflict) reason() string {
+	switch {
+	case c.Policy != ConflictKeepBest:
+		return "on-conflict " + c.Policy
+	case c.Quality == qualityDifferent:
+		return "not the same shot"
+	}
+	return c.Quality + " quality"
+}
+
+// sameShot reports whether src and dest are versions of one photo or video, which
+// keep-best may rank against each other: images within the near-duplicate distance,
+// or files with the same capture time. Files that only share a name and day (two
+// cameras, a reset counter) are different shots.
+func sameShot(src, dest string, fileType FileType, cfg *Config) bool {
+	if fileType == TypeImage {
+		distance := cfg.NearDuplicates.Distance
+		if distance <= 0 {
+			distance = DefaultNearDistance
+		}
+		a, errA := perceptualHash(src)
+		b, errB := perceptualHash(dest)
+		if errA == nil && errB == nil && hammingDistance(a, b) <= distance {
+			return true
+		}
+	}
+	srcTime, _, err := captureTimestamp(src, false, nil)
+	if err != nil {
+		return false
+	}
+	destTime, _, err := captureTimestamp(dest, false, nil)
+	return err == nil && srcTime.Equal(destTime)
+}
+
+// supersededPath is where dest is kept when an import replaces it: the same path under
+// its library's superseded/ folder, suffixed when an earlier replacement is already there
+func supersededPath(dest string, fileType FileType, cfg *Config) string {
+	root := cfg.Library
+	if fileType == TypeVideo {
+		root = cfg.VideoLib
+	}
+	rel, err := filepath.Rel(root, dest)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = filepath.Base(dest)
+	}
+
+	target := filepath.Join(root, supersededDir, rel)
+	if _, err := os.Lstat(target); err == nil {
+		return timestampSuffixCopyPath(target)
+	}
+	return target
+}
+
+// supersedeFile keeps the library file dest, about to be replaced, under superseded/: a
+// hardlink where the filesystem allows, otherwise a verified copy. dest itself stays in
+// place until the replacement is renamed over it, so a failed import loses nothing.
+func supersedeFile(dest, existingHash string, fileType FileType, cfg *Config) (string, error) {
+	target := supersededPath(dest, fileType, cfg)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %w", filepath.Dir(target), err)
+	}
+
+	if err := linkFile(dest, target); err == nil {
+		return target, nil
+	}
+	hash, err := copyFileAtomic(dest, target)
+	if err != nil {
+		return "", fmt.Errorf("failed to keep %s in %s: %w", dest, target, err)
+	}
+	if hash != existingHash {
+		_ = os.Remove(target)
+		return "", fmt.Errorf("%s changed while being kept in %s", dest, target)
+	}
+	return target, nil
+}
+
+// restoreSuperseded undoes supersedeFile after a replacement failed: the kept file goes
+// back to dest unless dest still is that file, in which case the extra link is dropped
+func restoreSuperseded(superseded, dest string) error {
+	keptInfo, err := os.Stat(superseded)
+	if err != nil {
+		return fmt.Errorf("failed to restore %s: %w", dest, err)
+	}
+	destInfo, err := os.Stat(dest)
+	switch {
+	case err == nil && os.SameFile(keptInfo, destInfo):
+		return os.Remove(superseded)
+	case errors.Is(err, os.ErrNotExist):
+		return os.Rename(superseded, dest)
+	case err != nil:
+		return fmt.Errorf("failed to restore %s: %w", dest, err)
+	}
+	return fmt.Errorf("%s is occupied, replaced file kept at %s", dest, superseded)
+}
+
+// settleConflict finishes a conflict once placeFile knows its outcome. A replacement that
+// failed puts the library file back; one that succeeded indexes the displaced content at
+// its superseded/ path. Decisions that took effect are logged.
+func settleConflict(src, srcHash string, c *fileConflict, cfg *Config, run *ImportRun, session *ImportSession, err error) {
+	if c.Superseded != "" {
+		if err != nil {
+			if err := restoreSuperseded(c.Superseded, c.Dest); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+			return
+		}
+		recordInIndex(run, c.ExistingHash, c.Superseded)
+	} else if err != nil && c.Resolution != ResolutionFailed {
+		return
+	}
+	if session != nil {
+		session.LogConflict(src, srcHash, c)
+	}
+}
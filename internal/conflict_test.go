@@ -0,0 +1,320 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleDuplicateFile_Policies(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src", "a.jpg")
+	dest := filepath.Join(tempDir, "lib", "a.jpg")
+	os.MkdirAll(filepath.Dir(src), 0755)
+	os.MkdirAll(filepath.Dir(dest), 0755)
+	os.WriteFile(src, []byte("incoming"), 0644)
+	os.WriteFile(dest, []byte("existing"), 0644)
+	existingHash, _ := fileHash(dest)
+
+	tests := []struct {
+		policy     string
+		resolution string
+		skip       bool
+		suffixed   bool
+		fails      bool
+	}{
+		{ConflictSuffix, ResolutionSuffixed, false, true, false},
+		{ConflictSkip, ResolutionSkipped, true, false, false},
+		{ConflictReplace, ResolutionReplaced, false, false, false},
+		{ConflictFail, ResolutionFailed, false, false, true},
+		// Text content has no dimensions to compare: both are kept
+		{ConflictKeepBest, ResolutionSuffixed, false, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			final, skip, _, conflict, err := handleDuplicateFile(src, "", dest, TypeImage, tt.policy, &Config{}, true)
+			if (err != nil) != tt.fails {
+				t.Fatalf("Expected error %v, got %v", tt.fails, err)
+			}
+			if conflict == nil || conflict.Resolution != tt.resolution || conflict.ExistingHash != existingHash {
+				t.Fatalf("Expected a %s conflict with the existing hash, got %+v", tt.resolution, conflict)
+			}
+			if skip != tt.skip {
+				t.Errorf("Expected skip=%v, got %v", tt.skip, skip)
+			}
+			if suffixed := final != "" && final != dest; suffixed != tt.suffixed {
+				t.Errorf("Expected suffixed=%v, got final path %q", tt.suffixed, final)
+			}
+			if tt.resolution == ResolutionReplaced && final != dest {
+				t.Errorf("Expected the incoming file placed at %s, got %q", dest, final)
+			}
+		})
+	}
+}
+
+func TestProcessFile_OnConflictKeepBest(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	date := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	// Three sizes of a picture from different cameras, all named IMG_1.jpg
+	sources := make(map[string]string)
+	for name, width := range map[string]int{"first": 400, "better": 800, "worse": 200} {
+		src := filepath.Join(tempDir, name, "IMG_1.jpg")
+		os.MkdirAll(filepath.Dir(src), 0755)
+		writeTestImage(t, src, width, width*3/4, 90, 0)
+		os.Chtimes(src, date, date)
+		sources[name] = src
+	}
+
+	index, err := OpenHashIndex(library, false)
+	if err != nil {
+		t.Fatalf("OpenHashIndex failed: %v", err)
+	}
+	defer index.Close()
+	cfg := &Config{
+		User:       "user",
+		Library:    library,
+		VideoLib:   library,
+		ImageExt:   []string{".jpg"},
+		VideoExt:   []string{".mp4"},
+		Timezone:   "UTC",
+		Verify:     VerifyDest,
+		OnConflict: ConflictKeepBest,
+	}
+	run := &ImportRun{Index: index}
+	importOne := func(name string) *ImportSession {
+		t.Helper()
+		session, err := NewImportSession(library, library, cfg.User, filepath.Dir(sources[name]))
+		if err != nil {
+			t.Fatalf("NewImportSession failed: %v", err)
+		}
+		session.LogSessionStart(1)
+		if err := ProcessFile(sources[name], cfg, run, cfg.User, false, session, true); err != nil {
+			t.Fatalf("ProcessFile(%s) failed: %v", name, err)
+		}
+		session.Close()
+		// Session IDs have one-second resolution
+		time.Sleep(1100 * time.Millisecond)
+		return session
+	}
+
+	dest := filepath.Join(library, "user", "noexif", "2023-05", "IMG_1.jpg")
+	firstHash, _ := fileHash(sources["first"])
+	betterHash, _ := fileHash(sources["better"])
+
+	importOne("first")
+	replacing := importOne("better")
+
+	if hash, _ := fileHash(dest); hash != betterHash {
+		t.Fatalf("Expected the higher resolution file at %s", dest)
+	}
+	superseded := filepath.Join(library, supersededDir, "user", "noexif", "2023-05", "IMG_1.jpg")
+	if hash, _ := fileHash(superseded); hash != firstHash {
+		t.Fatalf("Expected the replaced file kept at %s", superseded)
+	}
+	if path, ok := index.Lookup(firstHash); !ok || path != superseded {
+		t.Errorf("Expected the replaced content indexed at its superseded path, got %q", path)
+	}
+
+	events, err := ReadManifest(filepath.Join(replacing.SessionDir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	var conflict *ManifestEvent
+	for i := range events {
+		if events[i].Event == "conflict" {
+			conflict = &events[i]
+		}
+	}
+	if conflict == nil {
+		t.Fatalf("Expected a conflict event, got %+v", events)
+	}
+	if conflict.Hash != betterHash || conflict.ExistingHash != firstHash || conflict.Action != ConflictKeepBest ||
+		conflict.Resolution != ResolutionReplaced || conflict.Quality != "higher" || conflict.Superseded != superseded {
+		t.Errorf("Expected both hashes and the replacement recorded, got %+v", conflict)
+	}
+
+	// A lower resolution copy leaves the library alone
+	skipping := importOne("worse")
+	if hash, _ := fileHash(dest); hash != betterHash {
+		t.Errorf("Expected the lower resolution file not to replace %s", dest)
+	}
+	if stats := skipping.GetStats(); stats.Conflicts != 1 || stats.SkippedConflict != 1 || stats.Copied != 0 {
+		t.Errorf("Expected one kept-existing conflict and nothing copied, got %+v", stats)
+	}
+
+	// Undoing the replacement puts the original back
+	result, err := UndoImportSession(library, replacing.ID, false)
+	if err != nil {
+		t.Fatalf("UndoImportSession failed: %v", err)
+	}
+	if len(result.Reinstated) != 1 {
+		t.Errorf("Expected one reinstated file, got %+v", result)
+	}
+	if hash, _ := fileHash(dest); hash != firstHash {
+		t.Errorf("Expected the original file back at %s", dest)
+	}
+	if _, err := os.Stat(filepath.Join(library, supersededDir)); !os.IsNotExist(err) {
+		t.Errorf("Expected the emptied superseded folder removed, got %v", err)
+	}
+}
+
+func TestProcessFile_OnConflictKeepBestDifferentShots(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	date := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	// Two different pictures of the same size, both named IMG_0001.jpg (a reset counter)
+	var sources []string
+	for pattern, name := range []string{"first", "second"} {
+		src := filepath.Join(tempDir, name, "IMG_0001.jpg")
+		os.MkdirAll(filepath.Dir(src), 0755)
+		writeTestImage(t, src, 400, 300, 90, pattern)
+		os.Chtimes(src, date, date)
+		sources = append(sources, src)
+	}
+	// The second one even looks better by size alone
+	writeTestImage(t, sources[1], 400, 300, 100, 1)
+	os.Chtimes(sources[1], date, date)
+
+	cfg := &Config{
+		User:       "user",
+		Library:    library,
+		VideoLib:   library,
+		ImageExt:   []string{".jpg"},
+		VideoExt:   []string{".mp4"},
+		Timezone:   "UTC",
+		Verify:     VerifyDest,
+		OnConflict: ConflictKeepBest,
+	}
+	// The first one is in the library from an earlier import
+	if err := ProcessFile(sources[0], cfg, nil, cfg.User, false, nil, true); err != nil {
+		t.Fatalf("ProcessFile(%s) failed: %v", sources[0], err)
+	}
+	session, err := NewImportSession(library, library, cfg.User, tempDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	if err := ProcessFile(sources[1], cfg, nil, cfg.User, false, session, true); err != nil {
+		t.Fatalf("ProcessFile(%s) failed: %v", sources[1], err)
+	}
+	session.Close()
+
+	dest := filepath.Join(library, "user", "noexif", "2023-05", "IMG_0001.jpg")
+	firstHash, _ := fileHash(sources[0])
+	if hash, _ := fileHash(dest); hash != firstHash {
+		t.Errorf("Expected the first picture left at %s", dest)
+	}
+	if _, err := os.Stat(filepath.Join(library, supersededDir)); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing superseded, got %v", err)
+	}
+	if stats := session.GetStats(); stats.CopiedTimestamped != 1 || stats.Replaced != 0 || stats.SkippedConflict != 0 {
+		t.Errorf("Expected both pictures kept, got %+v", stats)
+	}
+
+	events, err := ReadManifest(filepath.Join(session.SessionDir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	for _, e := range events {
+		if e.Event == "conflict" && (e.Resolution != ResolutionSuffixed || e.Quality != qualityDifferent) {
+			t.Errorf("Expected the different shot suffixed, got %+v", e)
+		}
+	}
+}
+
+func TestProcessFile_OnConflictReplaceKeepsSessionFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	date := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	// Two sources of one import that map to the same destination
+	var sources []string
+	for _, name := range []string{"card1", "card2"} {
+		src := filepath.Join(tempDir, name, "a.jpg")
+		os.MkdirAll(filepath.Dir(src), 0755)
+		os.WriteFile(src, []byte("photo from "+name), 0644)
+		os.Chtimes(src, date, date)
+		sources = append(sources, src)
+	}
+
+	cfg := &Config{
+		User:       "user",
+		Library:    library,
+		VideoLib:   library,
+		ImageExt:   []string{".jpg"},
+		VideoExt:   []string{".mp4"},
+		Timezone:   "UTC",
+		Verify:     VerifyDest,
+		OnConflict: ConflictReplace,
+	}
+	session, err := NewImportSession(library, library, cfg.User, tempDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	for _, src := range sources {
+		if err := ProcessFile(src, cfg, nil, cfg.User, false, session, true); err != nil {
+			t.Fatalf("ProcessFile(%s) failed: %v", src, err)
+		}
+	}
+	session.Close()
+
+	dest := filepath.Join(library, "user", "noexif", "2023-05", "a.jpg")
+	firstHash, _ := fileHash(sources[0])
+	if hash, _ := fileHash(dest); hash != firstHash {
+		t.Errorf("Expected the first source left at %s", dest)
+	}
+	if stats := session.GetStats(); stats.Copied != 1 || stats.CopiedTimestamped != 1 || stats.Replaced != 0 {
+		t.Errorf("Expected the second source suffixed, got %+v", stats)
+	}
+	if _, err := os.Stat(filepath.Join(library, supersededDir)); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing superseded, got %v", err)
+	}
+}
+
+func TestPlan_OnConflictReplace(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	inputDir := filepath.Join(tempDir, "input")
+	os.MkdirAll(inputDir, 0755)
+	date := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	dest := filepath.Join(library, "user", "noexif", "2023-05", "a.jpg")
+	os.MkdirAll(filepath.Dir(dest), 0755)
+	os.WriteFile(dest, []byte("existing"), 0644)
+	src := filepath.Join(inputDir, "a.jpg")
+	os.WriteFile(src, []byte("incoming"), 0644)
+	os.Chtimes(src, date, date)
+
+	cfg := &Config{
+		User:       "user",
+		Library:    library,
+		VideoLib:   library,
+		ImageExt:   []string{".jpg"},
+		VideoExt:   []string{".mp4"},
+		Timezone:   "UTC",
+		Verify:     VerifyDest,
+		OnConflict: ConflictReplace,
+	}
+	plan, err := BuildImportPlan(cfg, NewImportRun(&ScanResult{Files: []string{src}, InputDir: inputDir}), "user")
+	if err != nil {
+		t.Fatalf("BuildImportPlan failed: %v", err)
+	}
+	e := plan.Entries[0]
+	if e.Action != PlanReplace || e.Conflict != dest || e.ExistingHash == "" || e.Policy != ConflictReplace {
+		t.Fatalf("Expected a planned replacement of %s, got %+v", dest, e)
+	}
+
+	// A library file changed since planning is not replaced
+	os.WriteFile(dest, []byte("edited"), 0644)
+	cfg, run := plan.Run(cfg)
+	if err := ProcessFile(run.Files[0], cfg, run, "user", false, nil, true); err == nil || !strings.Contains(err.Error(), "changed since planning") {
+		t.Errorf("Expected the changed library file refused, got %v", err)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "edited" {
+		t.Errorf("Expected the library file untouched, got %q", data)
+	}
+}
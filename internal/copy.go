@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"image"
 	_ "image/gif"
 	_ "image/jpeg"
@@ -15,6 +16,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	exiftool "github.com/barasher/go-exiftool"
@@ -23,7 +25,43 @@ import (
 
 // Global errors
 var (
-	ErrNoExifDate = errors.New("no EXIF or media creation date found")
+	ErrNoExifDate         = errors.New("no EXIF or media creation date found")
+	ErrReflinkUnsupported = errors.New("reflink not supported")
+)
+
+// Transfer methods recorded per file in the manifest
+const (
+	TransferCopy     = "copy"
+	TransferHardlink = "hardlink"
+	TransferReflink  = "reflink"
+	TransferRename   = "rename" // Same-filesystem move: linked in, source unlinked when the import ends
+)
+
+// Verification modes for Config.Verify
+const (
+	VerifyDest = "dest" // Re-read the destination and compare with the hash streamed during copy
+	VerifyFull = "full" // Also re-read the source, catching flaky reads from failing media
+	VerifyNone = "none" // Trust the hash streamed during copy
+)
+
+// Reflink modes for Config.Reflink
+const (
+	ReflinkNever  = "never"  // Plain copy
+	ReflinkAlways = "always" // Reflink or fail
+	ReflinkAuto   = "auto"   // Reflink when supported, otherwise copy
+)
+
+// Modification time modes for Config.Mtime
+const (
+	MtimeSource  = "source"  // Library files keep the source's modification time
+	MtimeCapture = "capture" // Library files get the detected capture date
+)
+
+// Date write-back modes for Config.WriteDates
+const (
+	WriteDatesOff  = ""     // Library files are left as imported
+	WriteDatesFile = "file" // Into the library file with ExifTool, or an XMP sidecar where it can't be changed
+	WriteDatesXMP  = "xmp"  // Always into an XMP sidecar next to the library file
 )
 
 // DateConfidence represents how reliable a date detection is
@@ -31,9 +69,26 @@ type DateConfidence int
 
 const (
 	HIGH     DateConfidence = iota // EXIF metadata
-	MEDIUM                         // Filename parsing
-	LOW                            // File creation time
-	VERY_LOW                       // File modification time
+	MEDIUM                         // Google Takeout JSON sidecar
+	LOW                            // Filename parsing or file modification time
+	VERY_LOW                       // No usable date
+)
+
+// captureDate is a detected file date with where it came from
+type captureDate struct {
+	Time       time.Time
+	Confidence DateConfidence
+	Source     string       // One of the DateSource constants
+	Original   time.Time    // EXIF date before a clock offset correction (zero if none)
+	Offset     *ClockOffset // Clock offset rule applied, if any
+}
+
+// Date sources, recorded as date_source in the manifest
+const (
+	DateSourceExif     = "exif"
+	DateSourceTakeout  = "takeout"
+	DateSourceFilename = "filename"
+	DateSourceModTime  = "mtime"
 )
 
 // QualityResult represents the result of quality comparison
@@ -73,9 +128,9 @@ var filenamePatterns = []*regexp.Regexp{
 	regexp.MustCompile(`(?i)telegram[_-](\d{4})[_-](\d{2})[_-](\d{2})`),                                  // Telegram date only
 }
 
-// fileHash computes SHA256 hash of a file content
+// fileHash computes SHA256 hash of a file content (files inside archives included)
 func fileHash(path string) (string, error) {
-	f, err := os.Open(path)
+	f, err := openSource(path)
 	if err != nil {
 		return "", err
 	}
@@ -121,6 +176,32 @@ func timestampSuffixCopyPath(dest string) string {
 	return safeCopyPath(target)
 }
 
+// stripedLock serializes work on string keys across import workers. Keys are
+// striped over a fixed set of mutexes so memory stays bounded on huge imports.
+type stripedLock [64]sync.Mutex
+
+// lock locks the stripe for key and returns the matching unlock func
+func (l *stripedLock) lock(key string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	mu := &l[h.Sum32()%uint32(len(l))]
+	mu.Lock()
+	return mu.Unlock
+}
+
+var (
+	// hashLocks is always taken before destLocks so workers cannot deadlock
+	hashLocks stripedLock
+	destLocks stripedLock
+)
+
+// lockDestination locks the stripe for dest and returns the matching unlock func.
+// Holding it across stat → duplicate check → copy guarantees two workers racing
+// to the same generateDestinationPath result resolve the collision one at a time.
+func lockDestination(dest string) func() {
+	return destLocks.lock(dest)
+}
+
 // TestHardlinkSupport tests if hardlinks can be created from srcDir to destDir.
 // Creates a temporary file in srcDir, tries to hardlink it to destDir, then cleans up.
 // Returns nil if hardlinks work, or an error explaining why they don't.
@@ -156,15 +237,113 @@ func TestHardlinkSupport(srcDir, destDir string) error {
 	return nil
 }
 
+// TestReflinkSupport tests if copy-on-write clones can be made from srcDir to destDir.
+// Creates a temporary file in srcDir, tries to reflink it into destDir, then cleans up.
+// Returns nil if reflinks work, or an error wrapping ErrReflinkUnsupported if they don't.
+func TestReflinkSupport(srcDir, destDir string) error {
+	tmpSrc, err := os.CreateTemp(srcDir, ".reflink-test-*")
+	if err != nil {
+		return fmt.Errorf("cannot create test file in source: %w", err)
+	}
+	tmpSrcPath := tmpSrc.Name()
+	defer os.Remove(tmpSrcPath)
+
+	// Some filesystems refuse to clone empty files, so give it content
+	_, err = tmpSrc.WriteString("anduril reflink test")
+	tmpSrc.Close()
+	if err != nil {
+		return fmt.Errorf("cannot write test file in source: %w", err)
+	}
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("cannot create destination directory: %w", err)
+	}
+
+	tmpDestPath := filepath.Join(destDir, ".reflink-test-"+filepath.Base(tmpSrcPath))
+	if err := reflinkFileAtomic(tmpSrcPath, tmpDestPath); err != nil {
+		return fmt.Errorf("reflink from %s to %s failed: %w", srcDir, destDir, err)
+	}
+	os.Remove(tmpDestPath)
+
+	return nil
+}
+
 // linkFile creates a hardlink from src to dest.
 // Does NOT fall back to copy - caller should handle errors appropriately.
 func linkFile(src, dest string) error {
 	return os.Link(src, dest)
 }
 
-// copyFileAtomic copies a file atomically (copy temp → rename)
-func copyFileAtomic(src, dest string) error {
+// copyFileAtomic copies a file atomically (copy temp → rename) with its times, mode and
+// user xattrs, and returns the SHA256 of the source bytes, hashed while streaming so
+// the source is read only once
+func copyFileAtomic(src, dest string) (string, error) {
+	return copyFileKnownHash(src, dest, "")
+}
+
+// copyFileKnownHash is copyFileAtomic for a source already hashed as knownHash (for the
+// hash index or a plan): the bytes are not hashed again and knownHash is returned, so the
+// destination check in verifyCopy is what catches a source that changed since
+func copyFileKnownHash(src, dest, knownHash string) (string, error) {
 	tmp := dest + ".tmp"
+	hash, err := copyToTemp(src, tmp, knownHash)
+	if err != nil {
+		return "", err
+	}
+	return hash, renameTempFile(tmp, dest)
+}
+
+// copyToTemp writes src to the new file tmp, synced and closed, and returns knownHash or
+// the hash streamed during the copy. tmp is removed on any failure.
+func copyToTemp(src, tmp, knownHash string) (string, error) {
+	in, err := openSource(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+
+	var r io.Reader = in
+	h := sha256.New()
+	if knownHash == "" {
+		r = io.TeeReader(in, h)
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := closeTempFile(out, tmp); err != nil {
+		return "", err
+	}
+	// Times are set once nothing more is written
+	if err := copyMetadata(src, tmp); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if knownHash != "" {
+		return knownHash, nil
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// reflinkFileAtomic clones a file atomically (clone temp → rename). The clone shares
+// data blocks with src until either file is modified, so it costs no extra space.
+func reflinkFileAtomic(src, dest string) error {
+	tmp := dest + ".tmp"
+	if err := reflinkToTemp(src, tmp); err != nil {
+		return err
+	}
+	return renameTempFile(tmp, dest)
+}
+
+// reflinkToTemp clones src as the new file tmp, synced and closed. tmp is removed on
+// any failure.
+func reflinkToTemp(src, tmp string) error {
 	in, err := os.Open(src)
 	if err != nil {
 		return err
@@ -176,12 +355,32 @@ func copyFileAtomic(src, dest string) error {
 		return err
 	}
 
-	if _, err := io.Copy(out, in); err != nil {
+	if err := cloneFile(out.Fd(), in.Fd()); err != nil {
 		out.Close()
 		os.Remove(tmp)
 		return err
 	}
+	if err := closeTempFile(out, tmp); err != nil {
+		return err
+	}
+	if err := copyMetadata(src, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
 
+// commitTempFile syncs and closes a fully written temp file, renames it over dest
+// and syncs the parent directory. The temp file is removed on any failure.
+func commitTempFile(out *os.File, tmp, dest string) error {
+	if err := closeTempFile(out, tmp); err != nil {
+		return err
+	}
+	return renameTempFile(tmp, dest)
+}
+
+// closeTempFile syncs and closes a fully written temp file, removing it on failure
+func closeTempFile(out *os.File, tmp string) error {
 	// Ensure bytes hit disk before rename
 	if err := out.Sync(); err != nil {
 		out.Close()
@@ -193,7 +392,12 @@ func copyFileAtomic(src, dest string) error {
 		os.Remove(tmp)
 		return err
 	}
+	return nil
+}
 
+// renameTempFile renames a closed temp file over dest and syncs the parent directory.
+// The temp file is removed if the rename fails.
+func renameTempFile(tmp, dest string) error {
 	if err := os.Rename(tmp, dest); err != nil {
 		os.Remove(tmp)
 		return err
@@ -209,13 +413,120 @@ func copyFileAtomic(src, dest string) error {
 	return dir.Sync()
 }
 
-// getFileModTime returns a file's modification time
-func getFileModTime(path string) (time.Time, error) {
-	fileInfo, err := os.Stat(path)
+// linkTempFile puts a closed temp file at dest without replacing anything there: tmp is
+// linked at dest and then removed, so a dest created by another process fails with
+// os.ErrExist. Where hardlinks are not possible it is renamed once dest is seen free.
+func linkTempFile(tmp, dest string) error {
+	err := moveIntoLibrary(tmp, dest)
+	if errors.Is(err, errLinkUnsupported) {
+		if _, statErr := os.Lstat(dest); statErr == nil {
+			os.Remove(tmp)
+			return fmt.Errorf("%s: %w", dest, os.ErrExist)
+		}
+		return renameTempFile(tmp, dest)
+	}
+	os.Remove(tmp)
+	return err
+}
+
+// cloneOrCopy places src at dest with a reflink when reflinkMode allows it, falling back
+// to a byte copy in auto mode. An existing dest fails with os.ErrExist unless replace is
+// set. Returns the transfer method that was used and the source hash: srcHash when the
+// caller already knows it, otherwise the one streamed during a copy; reflinks never read
+// the data, so their hash is then empty.
+func cloneOrCopy(src, dest, reflinkMode, srcHash string, replace bool) (string, string, error) {
+	tmp := dest + ".tmp"
+	commit := linkTempFile
+	if replace {
+		commit = renameTempFile
+	}
+	if IsArchiveEntry(src) {
+		// Compressed data has no extents to share
+		reflinkMode = ReflinkNever
+	}
+	switch reflinkMode {
+	case ReflinkAlways:
+		if err := reflinkToTemp(src, tmp); err != nil {
+			return TransferReflink, "", err
+		}
+		return TransferReflink, "", commit(tmp, dest)
+	case ReflinkAuto:
+		err := reflinkToTemp(src, tmp)
+		if err == nil {
+			return TransferReflink, "", commit(tmp, dest)
+		}
+		if !errors.Is(err, ErrReflinkUnsupported) {
+			return TransferReflink, "", err
+		}
+	}
+	hash, err := copyToTemp(src, tmp, srcHash)
+	if err != nil {
+		return TransferCopy, "", err
+	}
+	return TransferCopy, hash, commit(tmp, dest)
+}
+
+// verifyCopy checks a freshly written dest against hash, the source hash taken during
+// the copy, and its metadata against the source's. The destination is re-read unless
+// mode is VerifyNone; VerifyFull also re-reads the source.
+func verifyCopy(src, dest, hash, mode string) error {
+	if mode != VerifyNone {
+		destHash, err := fileHash(dest)
+		if err != nil {
+			return fmt.Errorf("failed to hash destination %s: %w", dest, err)
+		}
+		if destHash != hash {
+			return fmt.Errorf("hash verification failed after copy %s -> %s", src, dest)
+		}
+		if err := verifyMetadata(src, dest); err != nil {
+			return err
+		}
+	}
+
+	if mode == VerifyFull {
+		srcHash, err := fileHash(src)
+		if err != nil {
+			return fmt.Errorf("failed to hash source %s: %w", src, err)
+		}
+		if srcHash != hash {
+			return fmt.Errorf("source %s read differently on re-read, copy not trusted", src)
+		}
+	}
+
+	return nil
+}
+
+// errLinkUnsupported means src cannot be linked at dest, typically because they are on
+// different filesystems; the caller falls back to copying
+var errLinkUnsupported = errors.New("hardlink not possible")
+
+// moveIntoLibrary links src at dest, the first half of a same-filesystem move; the source
+// name is removed later with the other queued removals. Linking never replaces an
+// existing dest: that fails with an os.ErrExist error.
+func moveIntoLibrary(src, dest string) error {
+	if err := os.Link(src, dest); err != nil {
+		if errors.Is(err, syscall.EXDEV) || errors.Is(err, syscall.EPERM) ||
+			errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.EMLINK) {
+			return fmt.Errorf("%w: %v", errLinkUnsupported, err)
+		}
+		return err
+	}
+
+	// Sync parent directory to persist the link
+	dir, err := os.Open(filepath.Dir(dest))
 	if err != nil {
-		return time.Time{}, err
+		return nil
 	}
-	return fileInfo.ModTime(), nil
+	defer dir.Close()
+	_ = dir.Sync()
+
+	return nil
+}
+
+// getFileModTime returns a file's modification time
+func getFileModTime(path string) (time.Time, error) {
+	_, modTime, err := statSource(path)
+	return modTime, err
 }
 
 // parseDateFromFilename tries to extract date from filename using common patterns
@@ -302,32 +613,51 @@ func parseDateFromFilename(filename string) (time.Time, error) {
 
 // getBestFileDate tries multiple methods to get the most accurate file date
 func getBestFileDate(filePath string, cfg *Config) (time.Time, DateConfidence, error) {
+	date, err := detectFileDate(filePath, cfg, nil)
+	return date.Time, date.Confidence, err
+}
+
+// detectFileDate is getBestFileDate that also returns which source the date came from.
+// Dates are returned in the library timezone (see inLibraryZone), and EXIF dates are
+// corrected by the clock offset configured for the camera. formats may hold the
+// formats the scan detected.
+func detectFileDate(filePath string, cfg *Config, formats *FormatCache) (captureDate, error) {
 	fileType := determineFileType(filePath, cfg)
+	loc := cfg.location()
 
 	// Method 1: Try EXIF/metadata (HIGH confidence)
 	if fileType == TypeImage || fileType == TypeVideo {
-		captureTime, err := GetCaptureTimestamp(filePath, cfg.UseExifTool)
+		captureTime, instant, err := captureTimestamp(filePath, cfg.UseExifTool, formats)
 		if err == nil {
-			return captureTime, HIGH, nil
+			date := captureDate{Time: inLibraryZone(captureTime, instant, loc), Confidence: HIGH, Source: DateSourceExif}
+			if corrected, rule := correctCameraClock(filePath, date.Time, cfg); rule != nil {
+				date.Original, date.Time, date.Offset = date.Time, corrected, rule
+			}
+			return date, nil
 		}
 	}
 
-	// Method 2: Parse filename (LOW confidence) - unreliable for messaging apps
+	// Method 2: Google Takeout JSON sidecar (MEDIUM confidence)
+	if takenTime, err := getTakeoutDate(filePath); err == nil {
+		return captureDate{Time: takenTime.In(loc), Confidence: MEDIUM, Source: DateSourceTakeout}, nil
+	}
+
+	// Method 3: Parse filename (LOW confidence) - unreliable for messaging apps
 	if fileDate, err := parseDateFromFilename(filePath); err == nil {
-		return fileDate, LOW, nil
+		return captureDate{Time: wallClockIn(fileDate, loc), Confidence: LOW, Source: DateSourceFilename}, nil
 	}
 
-	// Method 3: File modification time (LOW confidence)
+	// Method 4: File modification time (LOW confidence)
 	if modTime, err := getFileModTime(filePath); err == nil {
-		return modTime, LOW, nil
+		return captureDate{Time: modTime.In(loc), Confidence: LOW, Source: DateSourceModTime}, nil
 	}
 
-	return time.Time{}, VERY_LOW, fmt.Errorf("could not determine file date for %s", filePath)
+	return captureDate{Confidence: VERY_LOW}, fmt.Errorf("could not determine file date for %s", filePath)
 }
 
 // getImageResolution returns the width and height of an image file
 func getImageResolution(path string) (int, int, error) {
-	file, err := os.Open(path)
+	file, err := openSource(path)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -343,11 +673,8 @@ func getImageResolution(path string) (int, int, error) {
 
 // getFileSize returns the size of a file in bytes
 func getFileSize(path string) (int64, error) {
-	info, err := os.Stat(path)
-	if err != nil {
-		return 0, err
-	}
-	return info.Size(), nil
+	size, _, err := statSource(path)
+	return size, err
 }
 
 // compareImageQuality compares quality between two images
@@ -443,13 +770,8 @@ func extractMetadata(paths ...string) ([]exiftool.FileMetadata, error) {
 
 // getVideoMetadata extracts basic video metadata using exiftool
 func getVideoMetadata(path string) (width, height int, duration float64, err error) {
-	// Quick check if file is actually a video by extension
-	ext := strings.ToLower(filepath.Ext(path))
-	videoExts := map[string]bool{
-		".mp4": true, ".mov": true, ".avi": true, ".mkv": true,
-		".webm": true, ".flv": true, ".wmv": true, ".m4v": true,
-	}
-	if !videoExts[ext] {
+	// Quick check if file is actually a video
+	if format := formatByExtension(contentExtension(path, nil)); format == nil || format.Type != TypeVideo {
 		return 0, 0, 0, fmt.Errorf("not a video file: %s", path)
 	}
 
@@ -652,72 +974,51 @@ const (
 	TypeOther
 )
 
-// determineFileType checks what type of file we're dealing with
+// determineFileType checks what type of file we're dealing with, by extension and,
+// when the extension is unknown, by content (see mediaType)
 func determineFileType(filePath string, cfg *Config) FileType {
-	ext := strings.ToLower(filepath.Ext(filePath))
-
-	for _, e := range cfg.ImageExt {
-		if ext == e {
-			return TypeImage
-		}
-	}
-
-	for _, e := range cfg.VideoExt {
-		if ext == e {
-			return TypeVideo
-		}
-	}
-
-	return TypeOther
+	return mediaType(filePath, cfg, nil)
 }
 
-// generateDestinationPath creates the target path based on file type and date confidence
-func generateDestinationPath(src string, fileDate time.Time, confidence DateConfidence, fileType FileType, cfg *Config, user string) (string, error) {
-	destBase := filepath.Base(src)
+// generateDestinationPath creates the target path from the configured layout template
+// for the file type and date confidence, renaming the file when a naming template is set.
+// srcHash is only needed for {hash} naming tokens; pass "" to have it computed on demand.
+func generateDestinationPath(src string, fileDate time.Time, confidence DateConfidence, fileType FileType, cfg *Config, user, srcHash string) (string, error) {
 	highConfidenceDate := confidence <= MEDIUM
 
-	var destDir string
-	switch {
-	case fileType == TypeVideo && highConfidenceDate:
-		destDir = filepath.Join(cfg.VideoLib, user,
-			fmt.Sprintf("%04d", fileDate.Year()),
-			fmt.Sprintf("%02d", fileDate.Month()),
-			fmt.Sprintf("%02d", fileDate.Day()))
-
-	case fileType == TypeVideo && !highConfidenceDate:
-		destDir = filepath.Join(cfg.VideoLib, user, "noexif",
-			fmt.Sprintf("%04d-%02d", fileDate.Year(), fileDate.Month()))
-
-	case fileType == TypeImage && highConfidenceDate:
-		destDir = filepath.Join(cfg.Library, user,
-			fmt.Sprintf("%04d", fileDate.Year()),
-			fmt.Sprintf("%02d", fileDate.Month()),
-			fmt.Sprintf("%02d", fileDate.Day()))
-
-	case fileType == TypeImage && !highConfidenceDate:
-		destDir = filepath.Join(cfg.Library, user, "noexif",
-			fmt.Sprintf("%04d-%02d", fileDate.Year(), fileDate.Month()))
-
+	var root string
+	switch fileType {
+	case TypeVideo:
+		root = cfg.VideoLib
+	case TypeImage:
+		root = cfg.Library
 	default:
 		return "", fmt.Errorf("non-media file passed to generateDestinationPath: %s", src)
 	}
 
-	return filepath.Join(destDir, destBase), nil
+	tmpl := cfg.Layout.template(fileType, highConfidenceDate)
+	return renderLayout(tmpl, root, src, fileDate, fileType, cfg, user, srcHash)
 }
 
 // handleDuplicateFile manages duplicate file resolution using strict hash comparison
-// Returns finalPath for new timestamped copies, shouldSkip when a duplicate is found,
-// and existingPath pointing to the file that matched the incoming hash.
-func handleDuplicateFile(src, destPath string, fileType FileType, isSilent bool) (finalPath string, shouldSkip bool, existingPath string, err error) {
+// srcHash is the already computed source hash; pass "" to have it computed here.
+// Returns finalPath for new timestamped copies (destPath itself when the existing file
+// is to be replaced), shouldSkip when a duplicate is found or the conflict policy keeps
+// the existing file, and existingPath pointing to the file that matched the incoming
+// hash. Different content yields the conflict decided by policy (see resolveConflict);
+// under ConflictFail it comes with an error.
+func handleDuplicateFile(src, srcHash, destPath string, fileType FileType, policy string, cfg *Config, isSilent bool) (finalPath string, shouldSkip bool, existingPath string, conflict *fileConflict, err error) {
 	// Check if files are identical
-	srcHash, err := fileHash(src)
-	if err != nil {
-		return "", false, "", fmt.Errorf("failed to hash src file %s: %w", src, err)
+	if srcHash == "" {
+		srcHash, err = fileHash(src)
+		if err != nil {
+			return "", false, "", nil, fmt.Errorf("failed to hash src file %s: %w", src, err)
+		}
 	}
 
 	destHash, err := fileHash(destPath)
 	if err != nil {
-		return "", false, "", fmt.Errorf("failed to hash dest file %s: %w", destPath, err)
+		return "", false, "", nil, fmt.Errorf("failed to hash dest file %s: %w", destPath, err)
 	}
 
 	// If content is identical, skip
@@ -725,7 +1026,7 @@ func handleDuplicateFile(src, destPath string, fileType FileType, isSilent bool)
 		if !isSilent {
 			fmt.Printf("Skipping duplicate file (identical content): %s\n", src)
 		}
-		return "", true, destPath, nil
+		return "", true, destPath, nil, nil
 	}
 
 	// Different content: if a timestamp-suffixed copy with the same hash already exists, skip.
@@ -744,30 +1045,48 @@ func handleDuplicateFile(src, destPath string, fileType FileType, isSilent bool)
 			if !isSilent {
 				fmt.Printf("Skipping duplicate file (matching timestamp copy exists): %s\n", src)
 			}
-			return "", true, candidate, nil
+			return "", true, candidate, nil, nil
 		}
 	}
 
+	conflict = resolveConflict(src, destPath, destHash, fileType, policy, cfg)
+	switch conflict.Resolution {
+	case ResolutionFailed:
+		return "", false, "", conflict, fmt.Errorf("destination %s already holds different content (on-conflict fail)", destPath)
+	case ResolutionSkipped:
+		if !isSilent {
+			fmt.Printf("Existing file has different content, keeping it (%s): %s → %s\n", conflict.reason(), src, destPath)
+		}
+		return "", true, "", conflict, nil
+	case ResolutionReplaced:
+		if !isSilent {
+			fmt.Printf("Existing file has different content, replacing it (%s): %s → %s\n", conflict.reason(), src, destPath)
+		}
+		return destPath, false, "", conflict, nil
+	}
+
 	// Keep both by placing the incoming file under a timestamp-suffixed name
 	finalPath = timestampSuffixCopyPath(destPath)
 	if !isSilent {
 		fmt.Printf("Existing file has different content, saving with timestamp suffix: %s → %s\n", src, finalPath)
 	}
-	return finalPath, false, "", nil
+	return finalPath, false, "", conflict, nil
 }
 
-// getCaptureTimestampNative uses goexif to get date for supported image files
-func getCaptureTimestampNative(filePath string) (time.Time, error) {
-	f, err := os.Open(filePath)
+// getCaptureTimestampNative uses goexif to get date for supported image files.
+// The date is an instant when the file records its UTC offset, otherwise a wall clock time.
+func getCaptureTimestampNative(filePath string) (time.Time, bool, error) {
+	f, err := openSource(filePath)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("opening file %s: %w", filePath, err)
+		return time.Time{}, false, fmt.Errorf("opening file %s: %w", filePath, err)
 	}
 	defer f.Close()
 
 	x, err := exif.Decode(f)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("decoding EXIF from %s: %w", filePath, err)
+		return time.Time{}, false, fmt.Errorf("decoding EXIF from %s: %w", filePath, err)
 	}
+	loadExtraExifTags(x)
 
 	// Try multiple EXIF date fields
 	for _, field := range []exif.FieldName{
@@ -789,28 +1108,32 @@ func getCaptureTimestampNative(filePath string) (time.Time, error) {
 		timeStr = strings.Trim(timeStr, "\"")
 		t, err := time.Parse("2006:01:02 15:04:05", timeStr)
 		if err == nil {
-			return t, nil
+			t, instant := exifDateWithOffset(x, field, t)
+			return t, instant, nil
 		}
 	}
 
-	return time.Time{}, ErrNoExifDate
+	return time.Time{}, false, ErrNoExifDate
 }
 
-// getCaptureTimestampExifTool uses exiftool to get date for any media file
-func getCaptureTimestampExifTool(filePath string) (time.Time, error) {
+// getCaptureTimestampExifTool uses exiftool to get date for any media file. The date is
+// an instant when it carries a UTC offset (or OffsetTime tags) or is a QuickTime UTC
+// date, otherwise a wall clock time. ext is the extension the file's content calls for.
+func getCaptureTimestampExifTool(filePath, ext string) (time.Time, bool, error) {
 	// Extract file metadata
 	fileInfos, err := extractMetadata(filePath)
 	if err != nil {
-		return time.Time{}, err
+		return time.Time{}, false, err
 	}
 	if len(fileInfos) != 1 {
-		return time.Time{}, fmt.Errorf("unexpected file info count: %d", len(fileInfos))
+		return time.Time{}, false, fmt.Errorf("unexpected file info count: %d", len(fileInfos))
 	}
 
 	fi := fileInfos[0]
 	if fi.Err != nil {
-		return time.Time{}, fmt.Errorf("exif extraction error: %w", fi.Err)
+		return time.Time{}, false, fmt.Errorf("exif extraction error: %w", fi.Err)
 	}
+	quickTime := quickTimeExtensions[ext]
 
 	// Tags to check in priority order
 	tags := []string{
@@ -828,26 +1151,61 @@ func getCaptureTimestampExifTool(filePath string) (time.Time, error) {
 			// Clean and parse the timestamp
 			cleanVal := strings.Trim(val, "\"")
 
+			// Formats with a zone give an instant
+			for _, format := range []string{
+				"2006:01:02 15:04:05Z07:00",     // With timezone
+				"2006:01:02 15:04:05.999Z07:00", // With milliseconds and timezone
+				"2006-01-02 15:04:05Z07:00",     // Hyphen with timezone
+			} {
+				if t, err := time.Parse(format, cleanVal); err == nil {
+					return t, true, nil
+				}
+			}
+
 			// Try various date formats
 			formats := []string{
-				"2006:01:02 15:04:05",       // Most common format
-				"2006:01:02 15:04:05-07:00", // With timezone
-				"2006:01:02 15:04:05.999",   // With milliseconds
-				"2006-01-02 15:04:05",       // Hyphen format
-				"2006-01-02 15:04:05-07:00", // Hyphen with timezone
-				"2006:01:02",                // Date only
+				"2006:01:02 15:04:05",     // Most common format
+				"2006:01:02 15:04:05.999", // With milliseconds
+				"2006-01-02 15:04:05",     // Hyphen format
+				"2006:01:02",              // Date only
 			}
 
 			for _, format := range formats {
 				t, err := time.Parse(format, cleanVal)
-				if err == nil {
-					return t, nil
+				if err != nil {
+					continue
+				}
+				if quickTime && quickTimeUTCTags[tag] {
+					return t, true, nil // Parsed as UTC, which QuickTime dates are
+				}
+				if offset, ok := exifToolOffset(fi, tag); ok {
+					return wallClockIn(t, offset), true, nil
 				}
+				return t, false, nil
 			}
 		}
 	}
 
-	return time.Time{}, ErrNoExifDate
+	return time.Time{}, false, ErrNoExifDate
+}
+
+// exifToolOffset returns the EXIF offset recorded for an ExifTool date tag
+func exifToolOffset(fi exiftool.FileMetadata, tag string) (*time.Location, bool) {
+	offsetTags := []string{"OffsetTime"}
+	switch tag {
+	case "DateTimeOriginal":
+		offsetTags = []string{"OffsetTimeOriginal", "OffsetTime"}
+	case "CreateDate":
+		offsetTags = []string{"OffsetTimeDigitized", "OffsetTime"}
+	}
+	for _, offsetTag := range offsetTags {
+		if value, err := fi.GetString(offsetTag); err == nil {
+			if zone, ok := parseUTCOffset(value); ok {
+				return zone, true
+			}
+		}
+	}
+	return nil, false
 }
 
 // BatchExtractMetadata extracts metadata for multiple files in one ExifTool call
@@ -907,46 +1265,257 @@ func BatchExtractMetadata(filePaths []string) (map[string]time.Time, error) {
 
 // GetCaptureTimestamp returns the media creation timestamp from a file
 func GetCaptureTimestamp(filePath string, useExifTool bool) (time.Time, error) {
-	ext := strings.ToLower(filepath.Ext(filePath))
+	t, _, err := captureTimestamp(filePath, useExifTool, nil)
+	return t, err
+}
+
+// captureTimestamp is GetCaptureTimestamp that also reports whether the date is an
+// instant (UTC offset known) rather than a wall clock time. formats may hold the
+// formats the scan detected.
+func captureTimestamp(filePath string, useExifTool bool, formats *FormatCache) (time.Time, bool, error) {
+	// A HEIC named .jpg must not be handed to the JPEG reader
+	ext := contentExtension(filePath, formats)
+
+	// ExifTool needs a real file; archive entries only get the native reader
+	if IsArchiveEntry(filePath) {
+		if !nativeImageExts[ext] {
+			return time.Time{}, false, ErrNoExifDate
+		}
+		return getCaptureTimestampNative(filePath)
+	}
 
 	// For videos or when exiftool is requested, use exiftool directly
 	if useExifTool || !nativeImageExts[ext] {
-		return getCaptureTimestampExifTool(filePath)
+		return getCaptureTimestampExifTool(filePath, ext)
 	}
 
 	// First try native for supported images
-	t, err := getCaptureTimestampNative(filePath)
+	t, instant, err := getCaptureTimestampNative(filePath)
 	if err == nil {
-		return t, nil
+		return t, instant, nil
 	}
 
 	// Fallback to exiftool if native fails
-	return getCaptureTimestampExifTool(filePath)
+	return getCaptureTimestampExifTool(filePath, ext)
+}
+
+// recordInIndex adds a library file to the run's hash index when one is open.
+// Index failures only warn: the file itself is already safely in the library.
+func recordInIndex(run *ImportRun, hash, path string) {
+	if run.Index == nil || hash == "" {
+		return
+	}
+	size, err := getFileSize(path)
+	if err == nil {
+		err = run.Index.Add(hash, path, size)
+	}
+	if err != nil {
+		fmt.Printf("Warning: failed to update hash index for %s: %v\n", path, err)
+	}
+}
+
+// recordChangedInIndex records path, imported from src with content hash srcHash and
+// changed since to content hash libHash, under srcHash in the run's hash index
+func recordChangedInIndex(run *ImportRun, src, srcHash, path, libHash string) {
+	if run.Index == nil || srcHash == "" {
+		return
+	}
+	srcSize, err := getFileSize(src)
+	var size int64
+	if err == nil {
+		size, err = getFileSize(path)
+	}
+	if err == nil {
+		err = run.Index.AddChanged(srcHash, srcSize, path, libHash, size)
+	}
+	if err != nil {
+		fmt.Printf("Warning: failed to update hash index for %s: %v\n", path, err)
+	}
+}
+
+// queueSourceRemoval queues src, with content hash, for deletion in --move mode; the
+// library copy at libraryPath is checked against hash before src is removed. Moves never
+// change the library file (dates go to an XMP sidecar), so the hashes match.
+// Failures only warn: the source is simply kept.
+func queueSourceRemoval(cfg *Config, session *ImportSession, src, libraryPath, hash string) {
+	if !cfg.MoveFiles || session == nil || hash == "" || IsArchiveEntry(src) {
+		return
+	}
+
+	if err := session.QueueSourceRemoval(src, libraryPath, hash); err != nil {
+		fmt.Printf("Warning: keeping source %s: %v\n", src, err)
+	}
+}
+
+// importDetails is what a copied event records about how a file was placed
+type importDetails struct {
+	Date        captureDate
+	Owner       userAttribution
+	Format      string // Format found by content when the extension disagreed
+	DateWritten string // Where the date was written back: WriteDatesFile or WriteDatesXMP
+	SrcHash     string // Source content hash when writing the date changed the library file
+	Group       string // ID of the RAW+JPEG or Live Photo group the file belongs to
+}
+
+// logImported creates the session browse hardlink and logs a copied or copied_timestamped
+// event recording the transfer method and how the file was placed
+func logImported(session *ImportSession, src, destPath, origDestPath, hash, method string, details importDetails) {
+	if session == nil {
+		return
+	}
+
+	size, _ := getFileSize(destPath)
+	browsePath := ""
+	browseFilename, err := session.CreateHardlink(destPath)
+	if err != nil {
+		fmt.Printf("Warning: failed to create import browser link: %v\n", err)
+	} else {
+		browsePath = browseFilename
+	}
+	// Always log, regardless of hardlink success
+	// Check if this was a timestamped copy (collision resolution)
+	if destPath != origDestPath {
+		session.logCopy("copied_timestamped", src, destPath, hash, size, browsePath, method, details)
+	} else {
+		session.logCopy("copied", src, destPath, hash, size, browsePath, method, details)
+	}
 }
 
 // ProcessFile processes media files and organizes them in the library
+// run parameter is optional - pass nil to import src without a scan or library indexes
 // session parameter is optional - pass nil to skip session tracking
-func ProcessFile(src string, cfg *Config, user string, dryRun bool, session *ImportSession, silent ...bool) error {
+// Safe to call from multiple goroutines sharing the same run and session
+func ProcessFile(src string, cfg *Config, run *ImportRun, user string, dryRun bool, session *ImportSession, silent ...bool) error {
 	isSilent := len(silent) > 0 && silent[0]
-	// Determine file type
-	fileType := determineFileType(src, cfg)
+	if run == nil {
+		run = &ImportRun{}
+	}
+	// Determine file type, by content when recognised
+	media := identifyMedia(src, cfg, run.Formats)
+	fileType := media.Type
 	if fileType == TypeOther {
 		return nil // Skip non-media files
 	}
 
-	// Get best available date with confidence level
-	fileDate, confidence, err := getBestFileDate(src, cfg)
+	// Sidecars follow the primary to wherever it ends up in the library, and the source
+	// cache records where it went under a fingerprint taken before it is read. Deferred
+	// before any lock is taken so it runs after they are all released.
+	placedAt, placedHash := "", ""
+	var fingerprint *sourceFingerprint
+	var sidecarFps map[string]sourceFingerprint
+	if run.Sources != nil && !dryRun {
+		fp, err := fingerprintSource(src)
+		if err == nil {
+			sidecarFps, err = fingerprintSidecars(run.Sidecars[src])
+		}
+		if err == nil {
+			fingerprint = &fp
+		}
+	}
+	defer func() {
+		if group := run.Groups[src]; group != nil && group.Lead == src {
+			group.setLeadDest(placedAt)
+		}
+		if placedAt != "" {
+			// A sidecar that failed is retried by the next import of the folder
+			sidecarsPlaced := placeSidecars(src, placedAt, cfg, run, session, dryRun, isSilent)
+			if fingerprint != nil && placedHash != "" && sidecarsPlaced {
+				if err := run.Sources.Record(src, *fingerprint, sidecarFps, placedHash, placedAt); err != nil {
+					fmt.Printf("Warning: failed to record %s in the source cache: %v\n", src, err)
+				}
+			}
+		}
+	}()
+
+	// An applied plan has already decided what happens to this file
+	if planned := run.Plan[src]; planned != nil && !dryRun {
+		var err error
+		placedAt, placedHash, err = applyPlannedFile(src, planned, fileType, cfg, run, session, isSilent)
+		return err
+	}
+
+	// Get best available date with confidence level (the best among a RAW+JPEG or Live Photo group)
+	date, err := assetDate(src, cfg, run)
 	if err != nil {
 		return fmt.Errorf("failed to get file date for %s: %w", src, err)
 	}
 
+	fileDate, confidence := date.Time, date.Confidence
+	if !isSilent && date.Offset != nil {
+		fmt.Printf("Clock offset %s: %s → %s\n", date.Offset, date.Original.Format("2006-01-02 15:04:05"), fileDate.Format("2006-01-02 15:04:05"))
+	}
+
+	// User rules may file this asset under someone other than the import's user
+	owner := assetUser(src, cfg, run, user)
+	details := importDetails{Date: date, Owner: owner}
+	if group := run.Groups[src]; group != nil {
+		details.Group = group.ID
+	}
+
+	if media.FixedExt != "" {
+		details.Format = media.Format.Name
+		if !isSilent {
+			if cfg.Naming.FixExtension {
+				fmt.Printf("Extension mismatch: %s is %s, importing as %s\n", src, media.Format.Name, media.FixedExt)
+			} else {
+				fmt.Printf("Warning: extension mismatch: %s is %s (use --fix-extensions to import it as %s)\n", src, media.Format.Name, media.FixedExt)
+			}
+		}
+	}
+
 	// Log confidence level for debugging
 	if !isSilent && confidence >= LOW {
 		fmt.Printf("Warning: low confidence date for %s (using %s)\n", src, fileDate.Format("2006-01-02"))
 	}
 
+	// Hash up front only when the index or a {hash} naming token needs it;
+	// otherwise the hash is streamed during the copy
+	var srcHash string
+	if run.Index != nil || cfg.Naming.usesHash() {
+		srcHash, err = hashSource(src, run.Sources)
+		if err != nil {
+			return fmt.Errorf("failed to hash source %s: %w", src, err)
+		}
+	}
+
+	// Consult the library hash index so content already stored anywhere is skipped,
+	// even when it arrives with a different filename or detected date
+	if run.Index != nil {
+
+		// Same content under different names must not be copied twice by parallel workers
+		unlockHash := hashLocks.lock(srcHash)
+		defer unlockHash()
+
+		if existingPath, ok := run.Index.Lookup(srcHash); ok {
+			if !isSilent {
+				if dryRun {
+					fmt.Printf("[dry-run] %s already in library as %s\n", src, existingPath)
+				} else {
+					fmt.Printf("Skipping duplicate file (already in library): %s → %s\n", src, existingPath)
+				}
+			}
+			if session != nil {
+				session.LogSkippedDuplicate(src, existingPath, srcHash)
+			}
+			queueSourceRemoval(cfg, session, src, existingPath, srcHash)
+			placedAt, placedHash = existingPath, srcHash
+			return nil
+		}
+	}
+
+	// Recompressed, resized or re-saved copies of images already in the library
+	var dhash uint64
+	hasDHash := false
+	if run.DHashes != nil && fileType == TypeImage && cfg.NearDuplicates.Policy != NearDuplicatesOff {
+		var skip bool
+		dhash, hasDHash, skip = checkNearDuplicate(src, srcHash, cfg, run.DHashes, session, dryRun, isSilent)
+		if skip {
+			return nil
+		}
+	}
+
 	// Generate destination path
-	destPath, err := generateDestinationPath(src, fileDate, confidence, fileType, cfg, user)
+	destPath, err := assetDestinationPath(src, fileDate, confidence, fileType, cfg, run, owner.User, srcHash, session)
 	if err != nil {
 		return err
 	}
@@ -954,159 +1523,286 @@ func ProcessFile(src string, cfg *Config, user string, dryRun bool, session *Imp
 
 	if dryRun {
 		if !isSilent {
-			fmt.Printf("[dry-run] %s → %s (confidence: %v)\n", src, destPath, confidence)
+			if owner.Rule != "" {
+				fmt.Printf("[dry-run] %s → %s (confidence: %v, user rule: %s)\n", src, destPath, confidence, owner.Rule)
+			} else {
+				fmt.Printf("[dry-run] %s → %s (confidence: %v)\n", src, destPath, confidence)
+			}
 		}
+		placedAt = destPath
 		return nil
 	}
 
+	placedAt, placedHash, err = placeFile(src, destPath, origDestPath, srcHash, fileType, cfg, run, session, details, nil, isSilent)
+	if err == nil && hasDHash && placedAt != "" {
+		if err := run.DHashes.Add(placedAt, dhash); err != nil {
+			fmt.Printf("Warning: failed to update perceptual index for %s: %v\n", placedAt, err)
+		}
+	}
+	return err
+}
+
+// placeFile puts src into the library at destPath (origDestPath before any timestamp
+// suffix), resolving an existing destination, and logs it. Returns where the content
+// ended up, which may be an existing identical file, and its hash; both are empty when
+// the conflict policy kept a different library file. With a plan entry, the destination
+// must still be free, or hold the content the plan replaces: the plan's decision is
+// never revisited.
+func placeFile(src, destPath, origDestPath, srcHash string, fileType FileType, cfg *Config, run *ImportRun, session *ImportSession, details importDetails, planned *PlanEntry, isSilent bool) (placedAt, placedHash string, err error) {
+	logAssetGroup(src, run, session)
+
+	// Timestamp-suffixed names derive from origDestPath, so one lock covers them too
+	unlock := lockDestination(origDestPath)
+	defer unlock()
+
 	// Create destination directory
 	destDir := filepath.Dir(destPath)
 	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory %s: %w", destDir, err)
+		return "", "", fmt.Errorf("failed to create directory %s: %w", destDir, err)
 	}
 
 	// Handle duplicates if file exists
 	destExists := false
-	if _, err := os.Stat(destPath); err == nil {
+	var conflict *fileConflict
+	if _, err := os.Stat(destPath); err == nil && planned != nil {
+		if planned.Action != PlanReplace || session.placedFile(destPath) {
+			return "", "", fmt.Errorf("destination %s appeared since planning", destPath)
+		}
+		if hash, err := fileHash(destPath); err != nil || hash != planned.ExistingHash {
+			return "", "", fmt.Errorf("%s changed since planning its replacement", destPath)
+		}
 		destExists = true
-		finalPath, shouldSkip, existingPath, err := handleDuplicateFile(src, destPath, fileType, isSilent)
+		conflict = &fileConflict{Dest: destPath, ExistingHash: planned.ExistingHash, Policy: planned.Policy, Resolution: ResolutionReplaced, Quality: planned.Quality}
+	} else if err == nil {
+		destExists = true
+		// Hash once here; the copy below reuses it instead of reading the source again
+		if srcHash == "" {
+			srcHash, err = fileHash(src)
+			if err != nil {
+				return "", "", fmt.Errorf("failed to hash source %s: %w", src, err)
+			}
+		}
+		// A file this session placed is another source colliding with this one, never
+		// something to replace or rank against: both are kept, as a plan would
+		policy := cfg.OnConflict
+		if session.placedFile(destPath) {
+			policy = ConflictSuffix
+		}
+		finalPath, shouldSkip, existingPath, c, err := handleDuplicateFile(src, srcHash, destPath, fileType, policy, cfg, isSilent)
+		conflict = c
 		if err != nil {
-			return err
+			if conflict != nil {
+				settleConflict(src, srcHash, conflict, cfg, run, session, err)
+			}
+			return "", "", err
+		}
+		if shouldSkip && conflict != nil {
+			// Different content the policy keeps: nothing imported, the source stays
+			settleConflict(src, srcHash, conflict, cfg, run, session, nil)
+			return "", "", nil
 		}
 		if shouldSkip {
+			// existingPath tells the user which file matched the incoming hash
+			if existingPath == "" {
+				existingPath = destPath
+			}
+			// The matching file predates the index; record it so later imports find it
+			recordInIndex(run, srcHash, existingPath)
 			// Log skip to session if tracking
 			if session != nil {
-				hash, _ := fileHash(src)
-				// existingPath tells the user which file matched the incoming hash
-				if existingPath == "" {
-					existingPath = destPath
-				}
-				session.LogSkippedDuplicate(src, existingPath, hash)
+				session.LogSkippedDuplicate(src, existingPath, srcHash)
 			}
-			return nil
+			queueSourceRemoval(cfg, session, src, existingPath, srcHash)
+			return existingPath, srcHash, nil
 		}
 		if finalPath != "" {
 			destPath = finalPath
 		}
 	} else if !errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("failed to stat %s: %w", destPath, err)
+		return "", "", fmt.Errorf("failed to stat %s: %w", destPath, err)
+	}
+
+	// The replaced library file is kept before anything is written over it, and put
+	// back if the replacement fails
+	if conflict != nil {
+		if conflict.Resolution == ResolutionReplaced {
+			superseded, err := supersedeFile(destPath, conflict.ExistingHash, fileType, cfg)
+			if err != nil {
+				return "", "", err
+			}
+			conflict.Superseded = superseded
+		}
+		defer func() { settleConflict(src, srcHash, conflict, cfg, run, session, err) }()
 	}
 
 	// Replacement means we want the new file at the original destination name
 	isUpgradeReplace := destExists && destPath == origDestPath
 
-	// Perform file operation (hardlink or atomic copy)
-	if cfg.UseHardlinks {
+	// Perform file operation (hardlink or atomic copy); archive entries are always copied
+	if cfg.UseHardlinks && !IsArchiveEntry(src) {
 		if isUpgradeReplace {
 			// Hardlinks cannot overwrite; fall back to atomic copy with verification
-			if err := copyFileAtomic(src, destPath); err != nil {
-				return fmt.Errorf("failed to replace file %s with upgraded copy: %w", destPath, err)
+			copyHash, err := copyFileAtomic(src, destPath)
+			if err != nil {
+				return "", "", fmt.Errorf("failed to replace file %s with upgraded copy: %w", destPath, err)
 			}
 
 			// Verify integrity with SHA256 comparison
-			srcHash, err := fileHash(src)
-			if err != nil {
-				return fmt.Errorf("failed to hash source %s: %w", src, err)
+			if err := verifyCopy(src, destPath, copyHash, cfg.Verify); err != nil {
+				_ = os.Remove(destPath)
+				return "", "", err
 			}
-
-			destHash, err := fileHash(destPath)
-			if err != nil {
-				return fmt.Errorf("failed to hash destination %s: %w", destPath, err)
+			if err := applyMtimeMode(destPath, cfg, details.Date); err != nil {
+				_ = os.Remove(destPath)
+				return "", "", err
 			}
-
-			if srcHash != destHash {
+			libHash, err := writeDateBack(src, destPath, copyHash, fileType, cfg, run, session, &details, cfg.MoveFiles, isSilent)
+			if err != nil {
 				_ = os.Remove(destPath)
-				return fmt.Errorf("hash verification failed after replacement %s -> %s", src, destPath)
+				return "", "", err
 			}
 
 			if !isSilent {
-				fmt.Printf("Replaced %s → %s (higher quality, hardlink fallback to copy)\n", src, destPath)
+				fmt.Printf("Replaced %s → %s (hardlink fallback to copy)\n", src, destPath)
 			}
-			return nil
+			logImported(session, src, destPath, origDestPath, libHash, TransferCopy, details)
+			return destPath, copyHash, nil
 		}
 
 		if err := linkFile(src, destPath); err != nil {
-			return fmt.Errorf("failed to link file %s to %s: %w", src, destPath, err)
+			return "", "", fmt.Errorf("failed to link file %s to %s: %w", src, destPath, err)
 		}
 		// Hardlinks share the same inode - no verification needed
 		if !isSilent {
 			fmt.Printf("Linked %s → %s (shared inode)\n", src, destPath)
 		}
 
+		if srcHash == "" && (session != nil || run.Index != nil || run.Sources != nil) {
+			srcHash, _ = fileHash(src)
+		}
+		// Changing the file would change the source too: dates go to a sidecar
+		if _, err := writeDateBack(src, destPath, srcHash, fileType, cfg, run, session, &details, true, isSilent); err != nil {
+			return "", "", err
+		}
+
 		// Log to session and create browse hardlink
-		if session != nil {
-			hash, _ := fileHash(src)
-			size, _ := getFileSize(destPath)
-			browsePath := ""
-			browseFilename, err := session.CreateHardlink(destPath)
-			if err != nil {
-				fmt.Printf("Warning: failed to create import browser link: %v\n", err)
-			} else {
-				browsePath = browseFilename
+		logImported(session, src, destPath, origDestPath, srcHash, TransferHardlink, details)
+
+		return destPath, srcHash, nil
+	}
+
+	// Same-filesystem moves link the source in: no data is copied, and the source name
+	// is only unlinked once the whole import finished without aborting
+	if cfg.MoveFiles && !IsArchiveEntry(src) && !isUpgradeReplace {
+		moveAttempts := 0
+		for {
+			moveAttempts++
+			err = moveIntoLibrary(src, destPath)
+			if errors.Is(err, os.ErrExist) && moveAttempts == 1 && planned == nil {
+				// Created since the duplicate check: never replace it
+				destPath = timestampSuffixCopyPath(origDestPath)
+				if !isSilent {
+					fmt.Printf("Destination exists, retrying with %s\n", destPath)
+				}
+				continue
 			}
-			// Always log, regardless of hardlink success
-			session.LogCopied(src, destPath, hash, size, browsePath)
+			break
+		}
+		switch {
+		case err == nil:
+			if srcHash == "" {
+				srcHash, err = fileHash(destPath)
+				if err != nil {
+					_ = os.Remove(destPath)
+					return "", "", fmt.Errorf("failed to hash destination %s: %w", destPath, err)
+				}
+			}
+			if !isSilent {
+				fmt.Printf("Moved %s → %s\n", src, destPath)
+			}
+			// The source shares the inode until it is unlinked, so it sees the new time too
+			if err := applyMtimeMode(destPath, cfg, details.Date); err != nil {
+				_ = os.Remove(destPath)
+				return "", "", err
+			}
+			// Changing the file would change the source too: dates go to a sidecar
+			if _, err := writeDateBack(src, destPath, srcHash, fileType, cfg, run, session, &details, true, isSilent); err != nil {
+				_ = os.Remove(destPath)
+				return "", "", err
+			}
+			logImported(session, src, destPath, origDestPath, srcHash, TransferRename, details)
+			queueSourceRemoval(cfg, session, src, destPath, srcHash)
+			return destPath, srcHash, nil
+		case !errors.Is(err, errLinkUnsupported):
+			return "", "", fmt.Errorf("failed to move file %s to %s: %w", src, destPath, err)
 		}
-
-		return nil
 	}
 
-	// Atomic copy with integrity verification
+	// Atomic copy (or reflink clone) with integrity verification.
+	// The source hash is streamed during the copy, so only the destination is re-read.
+	method := TransferCopy
+	copyHash := ""
 	copyAttempts := 0
 	for {
 		copyAttempts++
-		if err := copyFileAtomic(src, destPath); err != nil {
-			if errors.Is(err, os.ErrExist) && copyAttempts == 1 {
+		// A source hashed for the index or the duplicate check is not hashed again
+		method, copyHash, err = cloneOrCopy(src, destPath, cfg.Reflink, srcHash, isUpgradeReplace)
+		if err != nil {
+			if errors.Is(err, os.ErrExist) && copyAttempts == 1 && planned == nil {
+				// Created since the duplicate check: never replace it
 				destPath = timestampSuffixCopyPath(origDestPath)
 				if !isSilent {
 					fmt.Printf("Destination exists, retrying with %s\n", destPath)
 				}
 				continue
 			}
-			return fmt.Errorf("failed to copy file %s to %s: %w", src, destPath, err)
+			return "", "", fmt.Errorf("failed to copy file %s to %s: %w", src, destPath, err)
 		}
 		break
 	}
 
-	// Verify integrity with SHA256 comparison
-	srcHash, err := fileHash(src)
-	if err != nil {
-		return fmt.Errorf("failed to hash source %s: %w", src, err)
-	}
-
-	destHash, err := fileHash(destPath)
-	if err != nil {
-		return fmt.Errorf("failed to hash destination %s: %w", destPath, err)
+	switch {
+	case copyHash == "" && srcHash == "":
+		// Reflinks share extents without streaming the data
+		srcHash, err = fileHash(src)
+		if err != nil {
+			_ = os.Remove(destPath)
+			return "", "", fmt.Errorf("failed to hash source %s: %w", src, err)
+		}
+	case copyHash != "":
+		srcHash = copyHash
 	}
 
-	if srcHash != destHash {
+	// Verify integrity with SHA256 comparison
+	if err := verifyCopy(src, destPath, srcHash, cfg.Verify); err != nil {
 		// Remove bad copy so it is not trusted later
 		_ = os.Remove(destPath)
-		return fmt.Errorf("hash verification failed after copy %s -> %s", src, destPath)
+		return "", "", err
 	}
-
-	if !isSilent {
-		fmt.Printf("Copied %s → %s\n", src, destPath)
+	if err := applyMtimeMode(destPath, cfg, details.Date); err != nil {
+		_ = os.Remove(destPath)
+		return "", "", err
 	}
 
-	// Log to session and create browse hardlink
-	if session != nil {
-		size, _ := getFileSize(destPath)
-		browsePath := ""
-		browseFilename, err := session.CreateHardlink(destPath)
-		if err != nil {
-			fmt.Printf("Warning: failed to create import browser link: %v\n", err)
-		} else {
-			browsePath = browseFilename
-		}
-		// Always log, regardless of hardlink success
-		// Check if this was a timestamped copy (collision resolution)
-		if destPath != origDestPath {
-			session.LogCopiedTimestamped(src, destPath, srcHash, size, browsePath)
+	if !isSilent {
+		if method == TransferReflink {
+			fmt.Printf("Cloned %s → %s (reflink)\n", src, destPath)
 		} else {
-			session.LogCopied(src, destPath, srcHash, size, browsePath)
+			fmt.Printf("Copied %s → %s\n", src, destPath)
 		}
 	}
+	// A moved source is deleted: written into, the copy would be the only original left
+	libHash, err := writeDateBack(src, destPath, srcHash, fileType, cfg, run, session, &details, cfg.MoveFiles, isSilent)
+	if err != nil {
+		_ = os.Remove(destPath)
+		return "", "", err
+	}
 
-	return nil
+	// Log to session and create browse hardlink
+	logImported(session, src, destPath, origDestPath, libHash, method, details)
+
+	// Copy verified above; the source is deleted only if the whole import succeeds
+	queueSourceRemoval(cfg, session, src, destPath, srcHash)
+
+	return destPath, srcHash, nil
 }
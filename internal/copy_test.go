@@ -2,6 +2,7 @@ package internal
 
 import (
 	"bytes"
+	"errors"
 	"image"
 	"image/color"
 	"image/jpeg"
@@ -356,7 +357,7 @@ func TestHandleDuplicateFile_TimestampResolution(t *testing.T) {
 	}
 
 	t.Run("different hash image", func(t *testing.T) {
-		final, skip, existingPath, err := handleDuplicateFile(src, existing, TypeImage, true)
+		final, skip, existingPath, _, err := handleDuplicateFile(src, "", existing, TypeImage, ConflictSuffix, &Config{}, true)
 		if err != nil {
 			t.Fatalf("handleDuplicateFile returned error: %v", err)
 		}
@@ -374,7 +375,7 @@ func TestHandleDuplicateFile_TimestampResolution(t *testing.T) {
 	})
 
 	t.Run("different hash video", func(t *testing.T) {
-		final, skip, existingPath, err := handleDuplicateFile(src, existing, TypeVideo, true)
+		final, skip, existingPath, _, err := handleDuplicateFile(src, "", existing, TypeVideo, ConflictSuffix, &Config{}, true)
 		if err != nil {
 			t.Fatalf("handleDuplicateFile returned error: %v", err)
 		}
@@ -392,7 +393,7 @@ func TestHandleDuplicateFile_TimestampResolution(t *testing.T) {
 	})
 
 	t.Run("same hash skips", func(t *testing.T) {
-		final, skip, existingPath, err := handleDuplicateFile(existing, existing, TypeImage, true)
+		final, skip, existingPath, _, err := handleDuplicateFile(existing, "", existing, TypeImage, ConflictSuffix, &Config{}, true)
 		if err != nil {
 			t.Fatalf("handleDuplicateFile returned error: %v", err)
 		}
@@ -415,7 +416,7 @@ func TestHandleDuplicateFile_TimestampResolution(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		final, skip, existingPath, err := handleDuplicateFile(srcPref, existing, TypeImage, true)
+		final, skip, existingPath, _, err := handleDuplicateFile(srcPref, "", existing, TypeImage, ConflictSuffix, &Config{}, true)
 		if err != nil {
 			t.Fatalf("handleDuplicateFile returned error: %v", err)
 		}
@@ -456,7 +457,7 @@ func TestProcessFile_HardlinkIdenticalSkips(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err := ProcessFile(srcPath, cfg, cfg.User, false, nil); err != nil {
+	if err := ProcessFile(srcPath, cfg, nil, cfg.User, false, nil); err != nil {
 		t.Fatalf("ProcessFile failed: %v", err)
 	}
 
@@ -477,7 +478,7 @@ func expectedDestPath(t *testing.T, src string, cfg *Config, user string) string
 	if err != nil {
 		t.Fatalf("getBestFileDate: %v", err)
 	}
-	dest, err := generateDestinationPath(src, date, conf, fileType, cfg, user)
+	dest, err := generateDestinationPath(src, date, conf, fileType, cfg, user, "")
 	if err != nil {
 		t.Fatalf("generateDestinationPath: %v", err)
 	}
@@ -501,7 +502,7 @@ func TestProcessFile_HardlinkNewPathCreatesLink(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err := ProcessFile(srcPath, cfg, cfg.User, false, nil); err != nil {
+	if err := ProcessFile(srcPath, cfg, nil, cfg.User, false, nil); err != nil {
 		t.Fatalf("ProcessFile failed: %v", err)
 	}
 
@@ -518,3 +519,98 @@ func TestProcessFile_HardlinkNewPathCreatesLink(t *testing.T) {
 		t.Fatalf("expected hardlink between %s and %s", srcPath, destPath)
 	}
 }
+
+func TestCopyFileAtomic_StreamsSourceHash(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src.mp4")
+	dest := filepath.Join(tempDir, "dest.mp4")
+	if err := os.WriteFile(src, []byte("large video bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := copyFileAtomic(src, dest)
+	if err != nil {
+		t.Fatalf("copyFileAtomic failed: %v", err)
+	}
+	want, _ := fileHash(src)
+	if hash != want {
+		t.Errorf("Streamed hash %s does not match file hash %s", hash, want)
+	}
+
+	if err := verifyCopy(src, dest, hash, VerifyDest); err != nil {
+		t.Errorf("verifyCopy rejected a good copy: %v", err)
+	}
+
+	// A corrupted destination fails unless verification is disabled
+	if err := os.WriteFile(dest, []byte("corrupted"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyCopy(src, dest, hash, VerifyDest); err == nil {
+		t.Errorf("verifyCopy accepted a corrupted destination")
+	}
+	if err := verifyCopy(src, dest, hash, VerifyNone); err != nil {
+		t.Errorf("VerifyNone should not re-read: %v", err)
+	}
+
+	// Full verification also catches a source that reads differently
+	if _, err := copyFileAtomic(src, dest); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, []byte("changed bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyCopy(src, dest, hash, VerifyDest); err != nil {
+		t.Errorf("VerifyDest should only compare the destination: %v", err)
+	}
+	if err := verifyCopy(src, dest, hash, VerifyFull); err == nil {
+		t.Errorf("VerifyFull accepted a source that changed")
+	}
+}
+
+func TestCopyFileKnownHash_DestinationCheckCatchesChangedSource(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src.jpg")
+	dest := filepath.Join(tempDir, "dest.jpg")
+	os.WriteFile(src, []byte("hashed for the index"), 0644)
+	known, _ := fileHash(src)
+
+	// The source changes between the index lookup and the copy
+	os.WriteFile(src, []byte("edited before the copy"), 0644)
+	hash, err := copyFileKnownHash(src, dest, known)
+	if err != nil {
+		t.Fatalf("copyFileKnownHash failed: %v", err)
+	}
+	if hash != known {
+		t.Errorf("Expected the known hash returned without re-hashing, got %s", hash)
+	}
+	if err := verifyCopy(src, dest, hash, VerifyDest); err == nil {
+		t.Errorf("verifyCopy accepted a copy that does not match the hash looked up")
+	}
+}
+
+func TestCloneOrCopy_NeverReplacesExistingDestination(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src.jpg")
+	dest := filepath.Join(tempDir, "dest.jpg")
+	os.WriteFile(src, []byte("new photo"), 0644)
+	// Created by another process after the duplicate check
+	os.WriteFile(dest, []byte("someone else's photo"), 0644)
+
+	if _, _, err := cloneOrCopy(src, dest, ReflinkNever, "", false); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("Expected os.ErrExist for an existing destination, got %v", err)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "someone else's photo" {
+		t.Errorf("Existing destination was overwritten: %q", data)
+	}
+	if _, err := os.Stat(dest + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Temp file left behind: %v", err)
+	}
+
+	// An upgrade replaces on purpose
+	if _, _, err := cloneOrCopy(src, dest, ReflinkNever, "", true); err != nil {
+		t.Fatalf("Replacing copy failed: %v", err)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "new photo" {
+		t.Errorf("Expected the destination replaced, got %q", data)
+	}
+}
@@ -0,0 +1,228 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	exiftool "github.com/barasher/go-exiftool"
+)
+
+// Dates found outside a file (a Google Takeout JSON sidecar or the file name) can be
+// written back with --write-dates, so other software sorts the library copy the same
+// way anduril did. Writing into the file changes its content: the manifest records the
+// new library hash, and the hash index knows the file under its new hash and, noting
+// the change, under the source hash, so the unchanged source is still recognised as
+// imported.
+
+// writesDate reports whether date should be written back to the library copy
+func writesDate(cfg *Config, date captureDate) bool {
+	if cfg.WriteDates == WriteDatesOff || date.Time.IsZero() {
+		return false
+	}
+	return date.Source == DateSourceTakeout || date.Source == DateSourceFilename
+}
+
+// writeCaptureDate writes date into dest, the library file just placed from src, or into
+// an XMP sidecar next to it when cfg.WriteDates is "xmp", when dest is shared (a hardlink
+// to the source, or the only original once a moved source is deleted) or when ExifTool
+// can't write it. Returns where the date went
+// (WriteDatesFile, WriteDatesXMP or "" when nothing was written) and the new hash of
+// dest when its content changed. Only a changed file that can't be read back is an
+// error; anything else is reported and leaves the import as it was.
+func writeCaptureDate(src, dest string, fileType FileType, cfg *Config, run *ImportRun, session *ImportSession, date captureDate, shared, isSilent bool) (string, string, error) {
+	if !writesDate(cfg, date) {
+		return "", "", nil
+	}
+
+	if cfg.WriteDates == WriteDatesFile {
+		reason := "hardlinked to the source"
+		if cfg.MoveFiles {
+			reason = "the source is moved"
+		}
+		if !shared {
+			err := writeDateIntoFile(dest, fileType, date)
+			if err == nil {
+				hash, err := fileHash(dest)
+				if err != nil {
+					return "", "", fmt.Errorf("failed to hash %s after writing its date: %w", dest, err)
+				}
+				if !isSilent {
+					fmt.Printf("Wrote date %s into %s\n", date.Time.Format("2006-01-02 15:04:05"), dest)
+				}
+				return WriteDatesFile, hash, nil
+			}
+			reason = err.Error()
+		}
+		if !isSilent {
+			fmt.Printf("Cannot write date into %s (%s), using an XMP sidecar\n", dest, reason)
+		}
+	}
+
+	xmpPath, err := writeDateSidecar(src, dest, run, session, date)
+	if err != nil {
+		fmt.Printf("Warning: date not written for %s: %v\n", dest, err)
+		return "", "", nil
+	}
+	if !isSilent {
+		fmt.Printf("Wrote date %s into %s\n", date.Time.Format("2006-01-02 15:04:05"), xmpPath)
+	}
+	return WriteDatesXMP, "", nil
+}
+
+// writeDateIntoFile sets DateTimeOriginal (images) or QuickTime CreateDate (videos) with
+// ExifTool, keeping the file's times, mode and user xattrs. ExifTool replaces the file
+// through a temp file of its own, so a failed write leaves it untouched.
+func writeDateIntoFile(path string, fileType FileType, date captureDate) error {
+	fm := exiftool.EmptyFileMetadata()
+	fm.File = path
+	if fileType == TypeVideo {
+		// With a zone in the value ExifTool stores it as UTC, as QuickTime requires
+		fm.SetString("QuickTime:CreateDate", date.Time.Format("2006:01:02 15:04:05-07:00"))
+	} else {
+		fm.SetString("DateTimeOriginal", date.Time.Format("2006:01:02 15:04:05"))
+		// Takeout dates are instants; file name dates only assume the library timezone
+		if date.Source == DateSourceTakeout {
+			fm.SetString("OffsetTimeOriginal", date.Time.Format("-07:00"))
+		}
+	}
+
+	restore, err := keepFileMetadata(path)
+	if err != nil {
+		return err
+	}
+
+	exifToolMu.Lock()
+	et, err := getOrCreateExifToolLocked()
+	if err == nil {
+		batch := []exiftool.FileMetadata{fm}
+		et.WriteMetadata(batch)
+		err = batch[0].Err
+	}
+	exifToolMu.Unlock()
+	if err != nil {
+		_ = restore()
+		return err
+	}
+	return restore()
+}
+
+// keepFileMetadata records the times, mode and user xattrs of path and returns a
+// function that puts them back after the file was rewritten. A copy of a read-only
+// source is made owner-writable until then, as ExifTool won't write it otherwise.
+func keepFileMetadata(path string) (func() error, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	attrs, _ := userXattrs(path)
+	if info.Mode().Perm()&0200 == 0 {
+		if err := os.Chmod(path, info.Mode().Perm()|0200); err != nil {
+			return nil, fmt.Errorf("failed to make %s writable: %w", path, err)
+		}
+	}
+
+	return func() error {
+		if err := os.Chmod(path, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to set mode of %s: %w", path, err)
+		}
+		for name, value := range attrs {
+			if err := setUserXattr(path, name, value); err != nil && !errors.Is(err, errXattrUnsupported) {
+				return fmt.Errorf("failed to restore extended attributes of %s: %w", path, err)
+			}
+		}
+		if err := os.Chtimes(path, fileAccessTime(info), info.ModTime()); err != nil {
+			return fmt.Errorf("failed to set times of %s: %w", path, err)
+		}
+		return nil
+	}, nil
+}
+
+// dateSidecarPath names the XMP after the library file's full name (IMG_1.jpg.xmp),
+// the form that belongs to that file alone
+func dateSidecarPath(dest string) string {
+	return dest + ".xmp"
+}
+
+// writeDateSidecar writes an XMP sidecar carrying date next to dest and logs it, so undo
+// removes it with the import. An XMP imported with src, or one already in the library,
+// is never replaced: it may hold edits.
+func writeDateSidecar(src, dest string, run *ImportRun, session *ImportSession, date captureDate) (string, error) {
+	for _, sidecar := range run.Sidecars[src] {
+		if strings.EqualFold(filepath.Ext(sidecar), ".xmp") {
+			return "", fmt.Errorf("keeping the imported XMP sidecar %s", sidecar)
+		}
+	}
+
+	xmpPath := dateSidecarPath(dest)
+	unlock := lockDestination(xmpPath)
+	defer unlock()
+
+	f, err := os.OpenFile(xmpPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return "", fmt.Errorf("keeping the existing XMP sidecar %s", xmpPath)
+	}
+	if err != nil {
+		return "", err
+	}
+	data := dateXMP(date.Time)
+	if _, err := f.WriteString(data); err != nil {
+		f.Close()
+		os.Remove(xmpPath)
+		return "", fmt.Errorf("failed to write %s: %w", xmpPath, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(xmpPath)
+		return "", fmt.Errorf("failed to write %s: %w", xmpPath, err)
+	}
+
+	if session != nil {
+		hash, _ := fileHash(xmpPath)
+		session.LogDateSidecar(src, xmpPath, dest, hash, int64(len(data)))
+	}
+	return xmpPath, nil
+}
+
+// dateXMP is an XMP packet with the capture date in the properties photo managers read:
+// exif:DateTimeOriginal (darktable, digiKam), photoshop:DateCreated (Lightroom) and
+// xmp:CreateDate
+func dateXMP(t time.Time) string {
+	date := t.Format("2006-01-02T15:04:05-07:00")
+	return `<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:exif="http://ns.adobe.com/exif/1.0/"
+    xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/"
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+   exif:DateTimeOriginal="` + date + `"
+   photoshop:DateCreated="` + date + `"
+   xmp:CreateDate="` + date + `"/>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>
+`
+}
+
+// writeDateBack runs writeCaptureDate for destPath, placed from src with content hash
+// srcHash, notes the outcome in details and records destPath in the hash index: a
+// changed file under its new hash as well as srcHash. Returns the hash the manifest
+// records for destPath.
+func writeDateBack(src, destPath, srcHash string, fileType FileType, cfg *Config, run *ImportRun, session *ImportSession, details *importDetails, shared, isSilent bool) (string, error) {
+	written, libHash, err := writeCaptureDate(src, destPath, fileType, cfg, run, session, details.Date, shared, isSilent)
+	if err != nil {
+		return "", err
+	}
+	details.DateWritten = written
+	if libHash == "" {
+		recordInIndex(run, srcHash, destPath)
+		return srcHash, nil
+	}
+	recordInIndex(run, libHash, destPath)
+	recordChangedInIndex(run, src, srcHash, destPath, libHash)
+	details.SrcHash = srcHash
+	return libHash, nil
+}
@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessFile_WriteDatesSidecar(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	inputDir := filepath.Join(tempDir, "input")
+	os.MkdirAll(inputDir, 0755)
+
+	// No EXIF date: the date comes from the file name. ExifTool can't write into
+	// this content even where it is installed, so file mode falls back to XMP.
+	src := filepath.Join(inputDir, "20230501_100000.jpg")
+	os.WriteFile(src, []byte("no exif here"), 0644)
+	srcHash, _ := fileHash(src)
+
+	cfg := &Config{
+		User:       "user",
+		Library:    library,
+		VideoLib:   library,
+		ImageExt:   []string{".jpg"},
+		VideoExt:   []string{".mp4"},
+		Timezone:   "UTC",
+		Verify:     VerifyDest,
+		WriteDates: WriteDatesFile,
+	}
+	session, err := NewImportSession(library, library, cfg.User, inputDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	if err := ProcessFile(src, cfg, nil, cfg.User, false, session, true); err != nil {
+		t.Fatalf("ProcessFile failed: %v", err)
+	}
+	session.Close()
+
+	events, err := ReadManifest(filepath.Join(session.SessionDir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	var copied, sidecar *ManifestEvent
+	for i := range events {
+		switch events[i].Event {
+		case "copied":
+			copied = &events[i]
+		case "date_sidecar":
+			sidecar = &events[i]
+		}
+	}
+	if copied == nil || sidecar == nil {
+		t.Fatalf("Expected copied and date_sidecar events, got %+v", events)
+	}
+	if copied.DateWritten != WriteDatesXMP || copied.Hash != srcHash || copied.SrcHash != "" {
+		t.Errorf("Expected an unchanged library file with its date in XMP, got %+v", copied)
+	}
+	if sidecar.Dest != copied.Dest+".xmp" || sidecar.Parent != copied.Dest {
+		t.Errorf("Expected the XMP named after the library file, got %+v", sidecar)
+	}
+	data, err := os.ReadFile(sidecar.Dest)
+	if err != nil {
+		t.Fatalf("Expected XMP sidecar written: %v", err)
+	}
+	if !strings.Contains(string(data), `exif:DateTimeOriginal="2023-05-01T10:00:00+00:00"`) {
+		t.Errorf("Expected the file name date in the XMP, got:\n%s", data)
+	}
+
+	// Undo takes the generated sidecar with the import
+	if _, err := UndoImportSession(library, session.ID, false); err != nil {
+		t.Fatalf("UndoImportSession failed: %v", err)
+	}
+	if _, err := os.Stat(sidecar.Dest); !os.IsNotExist(err) {
+		t.Errorf("Expected undo to remove %s, got %v", sidecar.Dest, err)
+	}
+}
+
+func TestWriteDateSidecar_KeepsExistingXMP(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src", "IMG_1.jpg")
+	dest := filepath.Join(tempDir, "library", "IMG_1.jpg")
+	os.MkdirAll(filepath.Dir(src), 0755)
+	os.MkdirAll(filepath.Dir(dest), 0755)
+	date := captureDate{Time: time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC), Source: DateSourceTakeout}
+
+	// An XMP imported with the file carries its own metadata
+	cfg := &Config{WriteDates: WriteDatesXMP}
+	run := &ImportRun{ScanResult: ScanResult{Sidecars: map[string][]string{src: {filepath.Join(tempDir, "src", "IMG_1.XMP")}}}}
+	if _, err := writeDateSidecar(src, dest, run, nil, date); err == nil {
+		t.Error("Expected no XMP written over an imported one")
+	}
+
+	// Nor is one already in the library replaced
+	run.Sidecars = nil
+	os.WriteFile(dateSidecarPath(dest), []byte("edits"), 0644)
+	if _, err := writeDateSidecar(src, dest, run, nil, date); err == nil {
+		t.Error("Expected an existing XMP to be kept")
+	}
+	if data, _ := os.ReadFile(dateSidecarPath(dest)); string(data) != "edits" {
+		t.Errorf("Expected existing XMP untouched, got %q", data)
+	}
+
+	// EXIF and modification time dates are never written back
+	if writesDate(cfg, captureDate{Time: date.Time, Source: DateSourceExif}) || writesDate(cfg, captureDate{Time: date.Time, Source: DateSourceModTime}) {
+		t.Error("Expected only Takeout and file name dates written back")
+	}
+}
+
+func TestKeepFileMetadata_ReadOnlyCopyWritableUntilRestored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "IMG_1.jpg")
+	os.WriteFile(path, []byte("photo"), 0444)
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	os.Chtimes(path, mtime, mtime)
+
+	restore, err := keepFileMetadata(path)
+	if err != nil {
+		t.Fatalf("keepFileMetadata failed: %v", err)
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm()&0200 == 0 {
+		t.Errorf("Expected the copy owner-writable for the date write, got %v", info.Mode().Perm())
+	}
+
+	if err := restore(); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	info, _ = os.Stat(path)
+	if info.Mode().Perm() != 0444 {
+		t.Errorf("Expected mode 0444 restored, got %v", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("Expected mtime %v restored, got %v", mtime, info.ModTime())
+	}
+}
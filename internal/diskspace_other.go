@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd)
+
+package internal
+
+// diskSpace is only implemented where statfs is; elsewhere free space is unknown
+func diskSpace(path string) (free uint64, device uint64, err error) {
+	return 0, 0, errFreeSpaceUnknown
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin || freebsd
+
+package internal
+
+import "syscall"
+
+// diskSpace returns the bytes available to unprivileged users on the filesystem
+// holding path, and an ID telling filesystems apart
+func diskSpace(path string) (free uint64, device uint64, err error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return 0, 0, err
+	}
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return uint64(fs.Bavail) * uint64(fs.Bsize), uint64(st.Dev), nil
+}
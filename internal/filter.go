@@ -0,0 +1,208 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ScanConfig controls which files an import picks up. Patterns are globs matched
+// case-insensitively: one without "/" matches any file or folder name on the way
+// (e.g. "@eaDir", "*.tmp"); one with "/" matches a path relative to the import
+// folder, and everything below a matching folder (e.g. "DCIM/.thumbnails").
+type ScanConfig struct {
+	Include         []string `mapstructure:"include"`          // When set, only matching files are imported
+	Exclude         []string `mapstructure:"exclude"`          // Files and folders to skip
+	DefaultExcludes bool     `mapstructure:"default_excludes"` // Also skip NAS thumbnails, trash folders and app caches
+	MinSize         int64    `mapstructure:"min_size"`         // Skip media files smaller than this many bytes
+	MinDimension    int      `mapstructure:"min_dimension"`    // Skip images narrower or shorter than this many pixels
+	LogExcluded     bool     `mapstructure:"log_excluded"`     // Write an "excluded" manifest event per skipped file
+}
+
+// ExcludedFile is a media file left out by the scan rules
+type ExcludedFile struct {
+	Path   string
+	Reason string
+}
+
+// defaultScanExcludes are thumbnails, trash and caches that look like media but never
+// are originals, limited to names that can't be a real photo folder. Analytics skips
+// them too, along with its defaultSkipPatterns.
+var defaultScanExcludes = []string{
+	"@eaDir",                    // Synology thumbnails
+	"#recycle",                  // Synology recycle bin
+	"#snapshot",                 // Synology snapshots
+	".Trashes",                  // macOS removable media trash
+	".Trash",                    // macOS user trash
+	".Trash-*",                  // Linux removable media trash
+	"$RECYCLE.BIN",              // Windows recycle bin
+	"System Volume Information", // Windows volume metadata
+	".Spotlight-V100",
+	".fseventsd",
+	".thumbnails",
+	".thumbs",
+	".cache",
+	"*.lrdata", // Lightroom previews and smart previews
+	"Thumbs.db",
+	".DS_Store",
+	"._*", // macOS resource forks on non-HFS volumes
+	".git",
+	"node_modules",
+}
+
+// Validate checks that all patterns are well-formed globs
+func (s ScanConfig) Validate() error {
+	for _, list := range []struct {
+		key      string
+		patterns []string
+	}{
+		{"scan.include", s.Include},
+		{"scan.exclude", s.Exclude},
+	} {
+		for _, pattern := range list.patterns {
+			if _, err := path.Match(strings.ToLower(filepath.ToSlash(pattern)), ""); err != nil {
+				return fmt.Errorf("invalid %s pattern %q: %w", list.key, pattern, err)
+			}
+		}
+	}
+	if s.MinSize < 0 || s.MinDimension < 0 {
+		return fmt.Errorf("scan.min_size and scan.min_dimension must not be negative")
+	}
+	return nil
+}
+
+// excludePatterns returns the configured excludes plus the built-in ones when enabled
+func (s ScanConfig) excludePatterns() []string {
+	if !s.DefaultExcludes {
+		return s.Exclude
+	}
+	return append(append([]string{}, s.Exclude...), defaultScanExcludes...)
+}
+
+// matchScanPattern reports whether pattern matches rel, a slash-separated path
+// relative to the import folder
+func matchScanPattern(pattern, rel string) bool {
+	pattern = strings.TrimPrefix(strings.ToLower(filepath.ToSlash(pattern)), "/")
+	segments := strings.Split(strings.ToLower(rel), "/")
+
+	if !strings.Contains(pattern, "/") {
+		for _, segment := range segments {
+			if ok, _ := path.Match(pattern, segment); ok {
+				return true
+			}
+		}
+		return false
+	}
+	for i := 1; i <= len(segments); i++ {
+		if ok, _ := path.Match(pattern, strings.Join(segments[:i], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// excludedByPattern returns the exclude pattern matching rel, if any
+func (s ScanConfig) excludedByPattern(rel string) (string, bool) {
+	for _, pattern := range s.excludePatterns() {
+		if matchScanPattern(pattern, rel) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// excludedFolder reports whether the folder dir (not the import folder itself) is
+// excluded, so a scan can skip everything below it without reading it
+func (s ScanConfig) excludedFolder(inputDir, dir string) (ExcludedFile, bool) {
+	rel := scanRelPath(inputDir, dir)
+	if rel == "." {
+		return ExcludedFile{}, false
+	}
+	pattern, ok := s.excludedByPattern(rel)
+	if !ok {
+		return ExcludedFile{}, false
+	}
+	return ExcludedFile{Path: dir, Reason: fmt.Sprintf("folder matches exclude pattern %q", pattern)}, true
+}
+
+// excludeReason returns why the media file src (at rel in the import folder) is
+// left out, or "" to import it
+func (s ScanConfig) excludeReason(src, rel string, cfg *Config) string {
+	if pattern, ok := s.excludedByPattern(rel); ok {
+		return fmt.Sprintf("matches exclude pattern %q", pattern)
+	}
+
+	if len(s.Include) > 0 {
+		included := false
+		for _, pattern := range s.Include {
+			if matchScanPattern(pattern, rel) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return "matches no include pattern"
+		}
+	}
+
+	if s.MinSize > 0 {
+		if size, err := getFileSize(src); err == nil && size < s.MinSize {
+			return fmt.Sprintf("smaller than %d bytes (%d)", s.MinSize, size)
+		}
+	}
+
+	// Formats Go can't read (HEIC, RAW) have unknown dimensions and are kept
+	if s.MinDimension > 0 && determineFileType(src, cfg) == TypeImage {
+		if w, h, err := imageDimensions(src); err == nil && (w < s.MinDimension || h < s.MinDimension) {
+			return fmt.Sprintf("%dx%d is below %d pixels", w, h, s.MinDimension)
+		}
+	}
+
+	return ""
+}
+
+// dimensionHeaderLimit bounds what the minimum dimension check reads of a file; image
+// headers declare the dimensions well within it
+const dimensionHeaderLimit = 1 << 20
+
+// imageDimensions returns the width and height the header of image src declares. The
+// pixels are never decoded, and at most dimensionHeaderLimit bytes are read, so the
+// check costs every scanned image a header read only.
+func imageDimensions(src string) (int, int, error) {
+	f, err := openSource(src)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(io.LimitReader(f, dimensionHeaderLimit))
+	if err != nil {
+		return 0, 0, err
+	}
+	return config.Width, config.Height, nil
+}
+
+// scanRelPath returns src relative to the import folder, slash-separated. Archive
+// entries continue below the archive as if it were a folder.
+func scanRelPath(inputDir, src string) string {
+	entry := ""
+	if archivePath, name, ok := splitArchivePath(src); ok {
+		src, entry = archivePath, name
+	}
+
+	rel, err := filepath.Rel(inputDir, src)
+	if err != nil {
+		rel = src
+	}
+	rel = filepath.ToSlash(rel)
+	if entry == "" {
+		return rel
+	}
+	if rel == "." {
+		return entry
+	}
+	return rel + "/" + entry
+}
@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMatchScanPattern(t *testing.T) {
+	tests := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{"@eaDir", "2020/@eaDir/IMG_1.jpg/SYNOPHOTO_THUMB_XL.jpg", true},
+		{"@eadir", "2020/@eaDir/x.jpg", true}, // case-insensitive
+		{"*.lrdata", "Catalog Previews.lrdata/a/b.jpg", true},
+		{"._*", "trip/._IMG_1.jpg", true},
+		{"._*", "trip/IMG_1.jpg", false},
+		{"DCIM/.thumbnails", "DCIM/.thumbnails/1.jpg", true},
+		{"DCIM/.thumbnails", "Backup/DCIM/.thumbnails/1.jpg", false}, // anchored at the import folder
+		{"*/Screenshots", "2021/Screenshots/s.png", true},
+		{"Screenshots", "Screenshots.jpg", false},
+		{"photos.zip/Takeout/@eaDir", "photos.zip/Takeout/@eaDir/x.jpg", true},
+	}
+	for _, tt := range tests {
+		if got := matchScanPattern(tt.pattern, tt.rel); got != tt.want {
+			t.Errorf("matchScanPattern(%q, %q) = %v, want %v", tt.pattern, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestScanConfigValidate(t *testing.T) {
+	if err := (ScanConfig{Exclude: []string{"[unclosed"}}).Validate(); err == nil {
+		t.Error("Expected malformed glob to be rejected")
+	}
+	if err := (ScanConfig{Include: []string{"*.jpg"}, Exclude: []string{"@eaDir"}}).Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func writeTestPNG(t *testing.T, path string, w, h int) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScanMediaFiles_ExcludeRules(t *testing.T) {
+	inputDir := t.TempDir()
+	for _, dir := range []string{"2020/@eaDir/IMG_1.jpg", "2020/.thumbnails", "Screenshots", "2020/sub"} {
+		os.MkdirAll(filepath.Join(inputDir, dir), 0755)
+	}
+	write := func(rel, content string) {
+		os.WriteFile(filepath.Join(inputDir, rel), []byte(content), 0644)
+	}
+	write("2020/IMG_1.jpg", "a real photo, long enough")
+	write("2020/IMG_1.xmp", "<xmp/>")
+	write("2020/@eaDir/IMG_1.jpg/SYNOPHOTO_THUMB_XL.jpg", "synology thumbnail data")
+	write("2020/@eaDir/IMG_1.jpg/SYNOPHOTO_THUMB_XL.xmp", "<xmp/>")
+	write("2020/.thumbnails/t.jpg", "android thumbnail data")
+	write("Screenshots/s1.jpg", "screenshot data here")
+	write("2020/tiny.jpg", "x")
+	writeTestPNG(t, filepath.Join(inputDir, "2020", "sub", "icon.png"), 16, 16)
+	writeTestPNG(t, filepath.Join(inputDir, "2020", "sub", "photo.png"), 64, 48)
+
+	cfg := &Config{
+		ImageExt:   []string{".jpg", ".png"},
+		VideoExt:   []string{".mp4"},
+		SidecarExt: []string{".xmp"},
+		Scan: ScanConfig{
+			Exclude:         []string{"Screenshots"},
+			DefaultExcludes: true,
+			MinSize:         5,
+			MinDimension:    32,
+		},
+	}
+
+	scan, err := ScanMediaFiles(inputDir, cfg)
+	if err != nil {
+		t.Fatalf("ScanMediaFiles failed: %v", err)
+	}
+	var got []string
+	for _, f := range scan.Files {
+		rel, _ := filepath.Rel(inputDir, f)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	sort.Strings(got)
+	if want := "2020/IMG_1.jpg,2020/sub/photo.png"; strings.Join(got, ",") != want {
+		t.Errorf("Expected %s, got %v", want, got)
+	}
+	if len(scan.Excluded) != 5 {
+		t.Errorf("Expected 5 excluded files, got %v", scan.Excluded)
+	}
+	if sidecars := scan.Sidecars[filepath.Join(inputDir, "2020", "IMG_1.jpg")]; len(sidecars) != 1 {
+		t.Errorf("Expected only the real sidecar, got %v", sidecars)
+	}
+
+	// Include narrows the scan to matching files
+	cfg.Scan = ScanConfig{Include: []string{"*.png"}}
+	scan, err = ScanMediaFiles(inputDir, cfg)
+	if err != nil {
+		t.Fatalf("ScanMediaFiles failed: %v", err)
+	}
+	if len(scan.Files) != 2 || len(scan.Excluded) != 5 {
+		t.Errorf("Expected 2 PNGs and 5 excluded, got %v / %v", scan.Files, scan.Excluded)
+	}
+}
+
+func TestImageDimensions_ReadsOnlyTheHeader(t *testing.T) {
+	dir := t.TempDir()
+	full := filepath.Join(dir, "full.png")
+	writeTestPNG(t, full, 640, 480)
+
+	// Cut off the pixel data: only the header is left, which is all the check reads
+	data, _ := os.ReadFile(full)
+	truncated := filepath.Join(dir, "truncated.png")
+	os.WriteFile(truncated, data[:40], 0644)
+	if _, _, err := image.Decode(bytes.NewReader(data[:40])); err == nil {
+		t.Fatal("Expected the truncated PNG not to decode")
+	}
+
+	w, h, err := imageDimensions(truncated)
+	if err != nil || w != 640 || h != 480 {
+		t.Errorf("Expected 640x480 from the header, got %dx%d (%v)", w, h, err)
+	}
+}
+
+func TestLogExcluded(t *testing.T) {
+	library := t.TempDir()
+	session, err := NewImportSession(library, library, "user", "/input")
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	excluded := []ExcludedFile{{Path: "/input/@eaDir/a.jpg", Reason: `matches exclude pattern "@eaDir"`}}
+	if err := session.LogExcluded(excluded, true); err != nil {
+		t.Fatalf("LogExcluded failed: %v", err)
+	}
+	session.LogSessionEnd(session.GetStats())
+	session.Close()
+
+	events, err := ReadManifest(filepath.Join(session.SessionDir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	var sawEvent, sawCount bool
+	for _, event := range events {
+		if event.Event == "excluded" && event.Src == excluded[0].Path && event.Reason != "" {
+			sawEvent = true
+		}
+		if event.Event == "session_end" && event.Excluded == 1 {
+			sawCount = true
+		}
+	}
+	if !sawEvent || !sawCount {
+		t.Errorf("Expected an excluded event and a session_end count, got %+v", events)
+	}
+}
@@ -0,0 +1,316 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// mediaFormat is a file format anduril imports: the extensions it goes by (the
+// usual one first) and how to recognise its content. Extensions are only a hint;
+// a HEIC saved as .jpg or an extensionless WhatsApp export is still found by its
+// first bytes.
+type mediaFormat struct {
+	Name       string
+	Type       FileType
+	Extensions []string
+	match      func(header []byte) bool
+}
+
+// sniffLen is how much of a file is read to recognise its format
+const sniffLen = 64
+
+// mediaFormats is the registry, most specific signatures first (CR2 before TIFF)
+var mediaFormats = []*mediaFormat{
+	{"jpeg", TypeImage, []string{".jpg", ".jpeg", ".jpe", ".jfif"}, prefix("\xFF\xD8\xFF")},
+	{"png", TypeImage, []string{".png"}, prefix("\x89PNG\r\n\x1a\n")},
+	{"gif", TypeImage, []string{".gif"}, func(h []byte) bool { return hasPrefix(h, "GIF87a") || hasPrefix(h, "GIF89a") }},
+	{"webp", TypeImage, []string{".webp"}, riff("WEBP")},
+	{"bmp", TypeImage, []string{".bmp"}, prefix("BM")},
+	{"heic", TypeImage, []string{".heic", ".heif", ".hif"}, ftyp("heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1")},
+	{"avif", TypeImage, []string{".avif"}, ftyp("avif", "avis")},
+	{"cr3", TypeImage, []string{".cr3"}, ftyp("crx ")},
+	{"cr2", TypeImage, []string{".cr2"}, func(h []byte) bool { return hasPrefix(h, "II*\x00") && len(h) > 10 && string(h[8:10]) == "CR" }},
+	{"raf", TypeImage, []string{".raf"}, prefix("FUJIFILMCCD-RAW")},
+	{"orf", TypeImage, []string{".orf"}, func(h []byte) bool { return hasPrefix(h, "IIRO") || hasPrefix(h, "IIRS") || hasPrefix(h, "MMOR") }},
+	{"rw2", TypeImage, []string{".rw2"}, prefix("IIU\x00")},
+	// DNG, NEF, ARW, PEF and SRW are TIFF containers that can't be told apart by their header
+	{"tiff", TypeImage, []string{".tiff", ".tif", ".dng", ".nef", ".nrw", ".arw", ".pef", ".srw", ".raw"}, func(h []byte) bool {
+		return hasPrefix(h, "II*\x00") || hasPrefix(h, "MM\x00*")
+	}},
+	{"mov", TypeVideo, []string{".mov", ".qt"}, func(h []byte) bool {
+		if brand, ok := ftypBrand(h); ok {
+			return brand == "qt  "
+		}
+		// Older QuickTime files start with an atom other than ftyp
+		if len(h) < 8 {
+			return false
+		}
+		switch string(h[4:8]) {
+		case "moov", "mdat", "wide", "pnot":
+			return true
+		}
+		return false
+	}},
+	{"3gp", TypeVideo, []string{".3gp", ".3g2"}, func(h []byte) bool {
+		brand, ok := ftypBrand(h)
+		return ok && (strings.HasPrefix(brand, "3gp") || strings.HasPrefix(brand, "3g2"))
+	}},
+	{"m4v", TypeVideo, []string{".m4v", ".mp4"}, ftyp("M4V ", "M4VH", "M4VP")},
+	// Any other ISO base media brand (isom, mp41, mp42, avc1, ...) is an MP4
+	{"mp4", TypeVideo, []string{".mp4", ".m4v"}, func(h []byte) bool { _, ok := ftypBrand(h); return ok }},
+	{"avi", TypeVideo, []string{".avi"}, riff("AVI ")},
+	{"matroska", TypeVideo, []string{".mkv", ".webm"}, prefix("\x1A\x45\xDF\xA3")},
+	{"flv", TypeVideo, []string{".flv"}, prefix("FLV\x01")},
+	{"asf", TypeVideo, []string{".wmv", ".asf"}, prefix("\x30\x26\xB2\x75\x8E\x66\xCF\x11")},
+	{"mpeg", TypeVideo, []string{".mpg", ".mpeg"}, func(h []byte) bool { return hasPrefix(h, "\x00\x00\x01\xBA") || hasPrefix(h, "\x00\x00\x01\xB3") }},
+}
+
+func hasPrefix(h []byte, p string) bool {
+	return bytes.HasPrefix(h, []byte(p))
+}
+
+func prefix(p string) func([]byte) bool {
+	return func(h []byte) bool { return hasPrefix(h, p) }
+}
+
+// riff matches a RIFF container of the given form type
+func riff(form string) func([]byte) bool {
+	return func(h []byte) bool { return hasPrefix(h, "RIFF") && len(h) >= 12 && string(h[8:12]) == form }
+}
+
+// ftypBrand returns the major brand of an ISO base media file (MP4, MOV, HEIC, ...)
+func ftypBrand(h []byte) (string, bool) {
+	if len(h) < 12 || string(h[4:8]) != "ftyp" {
+		return "", false
+	}
+	return string(h[8:12]), true
+}
+
+// ftyp matches ISO base media files with one of the given major brands
+func ftyp(brands ...string) func([]byte) bool {
+	return func(h []byte) bool {
+		brand, ok := ftypBrand(h)
+		if !ok {
+			return false
+		}
+		for _, b := range brands {
+			if brand == b {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// hasExtension reports whether ext (lowercase) is one the format goes by
+func (f *mediaFormat) hasExtension(ext string) bool {
+	for _, e := range f.Extensions {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// enabled reports whether cfg imports the format: one of its extensions is configured
+// for its file type
+func (f *mediaFormat) enabled(cfg *Config) bool {
+	configured := cfg.ImageExt
+	if f.Type == TypeVideo {
+		configured = cfg.VideoExt
+	}
+	for _, e := range configured {
+		if f.hasExtension(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatExtensions lists the extensions of all registered formats of type t
+func formatExtensions(t FileType) []string {
+	var exts []string
+	for _, f := range mediaFormats {
+		if f.Type == t {
+			exts = append(exts, f.Extensions...)
+		}
+	}
+	return exts
+}
+
+// formatByExtension returns the first registered format going by ext, if any
+func formatByExtension(ext string) *mediaFormat {
+	ext = strings.ToLower(ext)
+	for _, f := range mediaFormats {
+		if f.hasExtension(ext) {
+			return f
+		}
+	}
+	return nil
+}
+
+// sniffFormat recognises a format from the first bytes of a file
+func sniffFormat(header []byte) *mediaFormat {
+	for _, f := range mediaFormats {
+		if f.match(header) {
+			return f
+		}
+	}
+	return nil
+}
+
+// FormatCache remembers the formats detected during one run, keyed by path, size and
+// modification time. A nil cache detects without remembering.
+type FormatCache struct {
+	formats sync.Map
+}
+
+// NewFormatCache returns an empty cache for a run
+func NewFormatCache() *FormatCache {
+	return &FormatCache{}
+}
+
+// detectFormat reads the start of src and returns its format, or nil when the content
+// is unrecognised or unreadable. Entries of compressed tars are not read: each read
+// would restart decompression, so they keep the type their extension gives.
+func detectFormat(src string, cache *FormatCache) *mediaFormat {
+	if IsArchiveEntry(src) && NeedsSequentialRead([]string{src}) {
+		return nil
+	}
+	var key string
+	if cache != nil {
+		size, modTime, err := statSource(src)
+		if err != nil {
+			return nil
+		}
+		key = fmt.Sprintf("%s\x00%d\x00%d", src, size, modTime.UnixNano())
+		if cached, ok := cache.formats.Load(key); ok {
+			return cached.(*mediaFormat)
+		}
+	}
+
+	var format *mediaFormat
+	if f, err := openSource(src); err == nil {
+		header := make([]byte, sniffLen)
+		n, _ := io.ReadFull(f, header)
+		f.Close()
+		format = sniffFormat(header[:n])
+	}
+	if cache != nil {
+		cache.formats.Store(key, format)
+	}
+	return format
+}
+
+// mayBeMedia reports whether a file with extension ext is worth sniffing: it has no
+// extension, or one that isn't a known document, code, archive or similar format
+func mayBeMedia(ext string) bool {
+	switch categorizeExtension(ext) {
+	case "Images", "Videos", "Other":
+		return true
+	}
+	return false
+}
+
+// mediaIdentity is what anduril makes of a file: its type, the format its content
+// shows (nil when unrecognised) and, when that format doesn't go by the file's
+// extension, the extension it should have
+type mediaIdentity struct {
+	Type     FileType
+	Format   *mediaFormat
+	FixedExt string
+}
+
+// identifyMedia reconciles a file's extension with its content. Recognised content
+// decides the type, as long as the configured extensions include its format;
+// unrecognised or disabled formats fall back to the extension. It reads every file,
+// so scans use mediaType instead; formats may hold what the scan already detected.
+func identifyMedia(src string, cfg *Config, formats *FormatCache) mediaIdentity {
+	if isSidecar(src, cfg) {
+		return mediaIdentity{Type: TypeOther}
+	}
+	ext := strings.ToLower(filepath.Ext(src))
+	byExt := typeByExtension(ext, cfg)
+	if byExt == TypeOther && !mayBeMedia(ext) {
+		return mediaIdentity{Type: TypeOther}
+	}
+
+	format := detectFormat(src, formats)
+	if format == nil || !format.enabled(cfg) {
+		return mediaIdentity{Type: byExt}
+	}
+	id := mediaIdentity{Type: format.Type, Format: format}
+	if !format.hasExtension(ext) {
+		id.FixedExt = format.Extensions[0]
+	}
+	return id
+}
+
+// mediaType returns the type of src without reading it when a configured extension
+// gives one, and sniffs only files whose extension is unknown. Files the source cache
+// skips are never opened; identifyMedia settles a misnamed file when it is imported.
+// Detected formats are remembered in formats, which may be nil.
+func mediaType(src string, cfg *Config, formats *FormatCache) FileType {
+	if isSidecar(src, cfg) {
+		return TypeOther
+	}
+	ext := strings.ToLower(filepath.Ext(src))
+	if byExt := typeByExtension(ext, cfg); byExt != TypeOther || !mayBeMedia(ext) {
+		return byExt
+	}
+	if format := detectFormat(src, formats); format != nil && format.enabled(cfg) {
+		return format.Type
+	}
+	return TypeOther
+}
+
+// typeByExtension returns the file type the configured extension lists give ext
+func typeByExtension(ext string, cfg *Config) FileType {
+	for _, e := range cfg.ImageExt {
+		if ext == e {
+			return TypeImage
+		}
+	}
+	for _, e := range cfg.VideoExt {
+		if ext == e {
+			return TypeVideo
+		}
+	}
+	return TypeOther
+}
+
+// contentExtension returns the lowercase extension of src, or the one its content
+// calls for when the two disagree. Metadata readers use it to pick a parser; formats
+// may hold what the scan already detected.
+func contentExtension(src string, formats *FormatCache) string {
+	ext := strings.ToLower(filepath.Ext(src))
+	if format := detectFormat(src, formats); format != nil && !format.hasExtension(ext) {
+		return format.Extensions[0]
+	}
+	return ext
+}
+
+// mediaNameParts splits the file name of src into a stem and the extension to give
+// it in the library: the content's when naming.fix_extension is on and the two
+// disagree. An unrecognised suffix (".123" on an extensionless export) stays in the stem.
+func mediaNameParts(src string, cfg *Config) (string, string) {
+	name := filepath.Base(src)
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	if !cfg.Naming.FixExtension {
+		return stem, ext
+	}
+
+	id := identifyMedia(src, cfg, nil)
+	if id.FixedExt == "" {
+		return stem, ext
+	}
+	if lower := strings.ToLower(ext); lower != "" && formatByExtension(lower) == nil && categorizeExtension(lower) == "Other" {
+		stem = name
+	}
+	return stem, id.FixedExt
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Minimal file headers for the formats under test
+var (
+	jpegHeader = []byte("\xFF\xD8\xFF\xE0\x00\x10JFIF\x00")
+	heicHeader = []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic")
+	mp4Header  = []byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00isomiso2")
+	movHeader  = []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x02\x00qt  ")
+)
+
+func TestSniffFormat(t *testing.T) {
+	tests := []struct {
+		header []byte
+		want   string
+	}{
+		{jpegHeader, "jpeg"},
+		{heicHeader, "heic"},
+		{mp4Header, "mp4"},
+		{movHeader, "mov"},
+		{[]byte("\x00\x00\x00\x08wide\x00\x00\x00\x00mdat"), "mov"},
+		{[]byte("\x89PNG\r\n\x1a\n\x00\x00"), "png"},
+		{[]byte("II*\x00\x10\x00\x00\x00CR\x02\x00"), "cr2"},
+		{[]byte("II*\x00\x08\x00\x00\x00\x00\x00\x00"), "tiff"},
+		{[]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), "webp"},
+		{[]byte("RIFF\x00\x00\x00\x00AVI LIST"), "avi"},
+		{[]byte("\x1A\x45\xDF\xA3\x01\x00"), "matroska"},
+	}
+	for _, tt := range tests {
+		got := sniffFormat(tt.header)
+		if got == nil || got.Name != tt.want {
+			t.Errorf("sniffFormat(%q) = %v, want %s", tt.header, got, tt.want)
+		}
+	}
+	if got := sniffFormat([]byte("just some text")); got != nil {
+		t.Errorf("Expected text to be unrecognised, got %s", got.Name)
+	}
+}
+
+func TestIdentifyMedia(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content []byte) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	cfg := &Config{
+		ImageExt:   []string{".jpg", ".heic"},
+		VideoExt:   []string{".mp4", ".mov"},
+		SidecarExt: []string{".xmp"},
+	}
+
+	tests := []struct {
+		path     string
+		wantType FileType
+		fixedExt string
+	}{
+		{write("IMG_1.jpg", heicHeader), TypeImage, ".heic"},
+		{write("VID_1.mov", mp4Header), TypeVideo, ".mp4"},
+		{write("IMG-20230101-WA0001", jpegHeader), TypeImage, ".jpg"},
+		{write("IMG_2.JPG", jpegHeader), TypeImage, ""},
+		{write("fake.jpg", []byte("not really a jpeg")), TypeImage, ""},               // unknown content keeps the extension
+		{write("notes.txt", jpegHeader), TypeOther, ""},                               // documents are never sniffed
+		{write("sticker.jpg", []byte("RIFF\x00\x00\x00\x00WEBPVP8 ")), TypeImage, ""}, // disabled formats keep the extension
+		{write("sticker", []byte("RIFF\x00\x00\x00\x00WEBPVP8 ")), TypeOther, ""},
+		{write("IMG_1.xmp", jpegHeader), TypeOther, ""},
+	}
+	for _, tt := range tests {
+		id := identifyMedia(tt.path, cfg, nil)
+		if id.Type != tt.wantType || id.FixedExt != tt.fixedExt {
+			t.Errorf("identifyMedia(%s) = type %v, fixed %q; want %v, %q", filepath.Base(tt.path), id.Type, id.FixedExt, tt.wantType, tt.fixedExt)
+		}
+	}
+
+	// Scans go by a configured extension without reading the file
+	clip := write("clip.jpg", mp4Header)
+	if got := mediaType(clip, cfg, nil); got != TypeImage {
+		t.Errorf("Expected the scan to go by the .jpg extension, got %v", got)
+	}
+	if got := identifyMedia(clip, cfg, nil); got.Type != TypeVideo || got.FixedExt != ".mp4" {
+		t.Errorf("Expected the import to find an MP4, got %+v", got)
+	}
+	if got := mediaType(filepath.Join(dir, "IMG-20230101-WA0001"), cfg, nil); got != TypeImage {
+		t.Errorf("Expected an extensionless JPEG sniffed, got %v", got)
+	}
+
+	if got := contentExtension(filepath.Join(dir, "IMG_1.jpg"), nil); got != ".heic" {
+		t.Errorf("Expected .heic content extension, got %s", got)
+	}
+	if !isMediaFile(filepath.Join(dir, "IMG-20230101-WA0001"), cfg) || isMediaFile(filepath.Join(dir, "notes.txt"), cfg) {
+		t.Error("Expected the watcher to recognise media by content")
+	}
+	if categorizeFile(filepath.Join(dir, "IMG-20230101-WA0001"), cfg) != "Images" {
+		t.Error("Expected analytics to categorize an extensionless JPEG as an image")
+	}
+}
+
+func TestProcessFile_FixExtension(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	inputDir := filepath.Join(tempDir, "input")
+	os.MkdirAll(inputDir, 0755)
+
+	os.WriteFile(filepath.Join(inputDir, "IMG_1.jpg"), heicHeader, 0644)
+	os.WriteFile(filepath.Join(inputDir, "export.123"), jpegHeader, 0644)
+	os.WriteFile(filepath.Join(inputDir, "readme.txt"), jpegHeader, 0644)
+
+	cfg := &Config{
+		User:     "user",
+		Library:  library,
+		VideoLib: library,
+		ImageExt: []string{".jpg", ".heic"},
+		VideoExt: []string{".mp4"},
+		Naming:   NamingConfig{FixExtension: true},
+	}
+	scan, err := ScanMediaFiles(inputDir, cfg)
+	if err != nil {
+		t.Fatalf("ScanMediaFiles failed: %v", err)
+	}
+	run := NewImportRun(scan)
+	files := run.Files
+	if len(files) != 2 {
+		t.Fatalf("Expected the HEIC and the extensionless JPEG, got %v", files)
+	}
+
+	session, err := NewImportSession(library, library, cfg.User, inputDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	for _, f := range files {
+		if err := ProcessFile(f, cfg, run, cfg.User, false, session, true); err != nil {
+			t.Fatalf("ProcessFile(%s) failed: %v", f, err)
+		}
+	}
+	session.Close()
+
+	events, err := ReadManifest(filepath.Join(session.SessionDir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	want := map[string]string{"IMG_1.jpg": "IMG_1.heic", "export.123": "export.123.jpg"}
+	for _, event := range events {
+		if event.Event != "copied" {
+			continue
+		}
+		name := filepath.Base(event.Src)
+		if filepath.Base(event.Dest) != want[name] || event.OriginalName != name || event.Format == "" {
+			t.Errorf("Expected %s imported as %s with its format logged, got %+v", name, want[name], event)
+		}
+		delete(want, name)
+	}
+	if len(want) != 0 {
+		t.Errorf("Missing copied events for %v", want)
+	}
+}
@@ -0,0 +1,314 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Asset group kinds
+const (
+	GroupRawJPEG   = "raw+jpeg"   // RAW file with an in-camera JPEG
+	GroupLivePhoto = "live_photo" // Still image with its motion clip (HEIC/JPEG + MOV)
+)
+
+// rawExtensions are camera RAW formats that pair with a processed image of the same stem
+var rawExtensions = map[string]bool{
+	".raw": true, ".cr2": true, ".cr3": true, ".nef": true, ".arw": true,
+	".raf": true, ".dng": true, ".orf": true, ".rw2": true, ".pef": true,
+}
+
+// livePhotoStillExtensions and livePhotoVideoExtensions are the halves of a Live Photo
+var (
+	livePhotoStillExtensions = map[string]bool{".heic": true, ".heif": true, ".jpg": true, ".jpeg": true}
+	livePhotoVideoExtensions = map[string]bool{".mov": true, ".mp4": true}
+)
+
+// AssetGroup is a set of files that form one photo: RAW+JPEG or a Live Photo. Members
+// share the best date found among them and are placed next to the lead's library file
+// under its final name (timestamp suffix included), each keeping its own extension.
+type AssetGroup struct {
+	ID      string
+	Kind    string
+	Lead    string   // Member whose layout and name the others follow (the first still image)
+	Members []string // Sorted source paths
+
+	dateOnce sync.Once
+	date     captureDate
+	dateErr  error
+
+	leadMu   sync.Mutex
+	leadDest string // Where the lead is in the library, once placed
+}
+
+// newAssetGroup builds a group from its members, choosing the first still image as lead
+func newAssetGroup(kind string, members []string, cfg *Config) *AssetGroup {
+	sort.Strings(members)
+
+	lead := members[0]
+	for _, m := range members {
+		if determineFileType(m, cfg) == TypeImage && !rawExtensions[strings.ToLower(filepath.Ext(m))] {
+			lead = m
+			break
+		}
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(members, "\x00")))
+	return &AssetGroup{
+		ID:      fmt.Sprintf("%x", sum[:6]),
+		Kind:    kind,
+		Lead:    lead,
+		Members: members,
+	}
+}
+
+// bestDate returns the most confident date among all members, computed once per group.
+// Ties keep the lead's date.
+func (g *AssetGroup) bestDate(cfg *Config, formats *FormatCache) (captureDate, error) {
+	g.dateOnce.Do(func() {
+		found := false
+		candidates := []string{g.Lead}
+		for _, m := range g.Members {
+			if m != g.Lead {
+				candidates = append(candidates, m)
+			}
+		}
+		for _, member := range candidates {
+			date, err := detectFileDate(member, cfg, formats)
+			if err != nil {
+				continue
+			}
+			if !found || date.Confidence < g.date.Confidence {
+				g.date, found = date, true
+			}
+		}
+		if !found {
+			g.dateErr = fmt.Errorf("could not determine a date for any member of %s", g.Lead)
+		}
+	})
+	return g.date, g.dateErr
+}
+
+// groupByStem pairs files by case-insensitive stem within each directory.
+// A RAW pairs with a non-RAW image; a Live Photo still pairs with a clip.
+// Returns the groups and the still images and clips that found no stem partner.
+func groupByStem(primaries []string, cfg *Config) ([]*AssetGroup, []string, []string) {
+	byStem := make(map[string][]string)
+	var keys []string
+	for _, primary := range primaries {
+		name := strings.ToLower(filepath.Base(primary))
+		key := filepath.Join(filepath.Dir(primary), strings.TrimSuffix(name, filepath.Ext(name)))
+		if _, ok := byStem[key]; !ok {
+			keys = append(keys, key)
+		}
+		byStem[key] = append(byStem[key], primary)
+	}
+
+	var groups []*AssetGroup
+	var stills, clips []string
+	for _, key := range keys {
+		var raws, images, videos []string
+		for _, member := range byStem[key] {
+			ext := strings.ToLower(filepath.Ext(member))
+			switch {
+			case rawExtensions[ext]:
+				raws = append(raws, member)
+			case determineFileType(member, cfg) == TypeVideo:
+				videos = append(videos, member)
+			default:
+				images = append(images, member)
+			}
+		}
+
+		switch {
+		case len(raws) > 0 && len(images) > 0:
+			groups = append(groups, newAssetGroup(GroupRawJPEG, append(raws, images...), cfg))
+		case len(images) == 1 && len(videos) == 1 &&
+			livePhotoStillExtensions[strings.ToLower(filepath.Ext(images[0]))] &&
+			livePhotoVideoExtensions[strings.ToLower(filepath.Ext(videos[0]))]:
+			groups = append(groups, newAssetGroup(GroupLivePhoto, append(images, videos...), cfg))
+		default:
+			for _, img := range images {
+				if livePhotoStillExtensions[strings.ToLower(filepath.Ext(img))] {
+					stills = append(stills, img)
+				}
+			}
+			for _, v := range videos {
+				if strings.ToLower(filepath.Ext(v)) == ".mov" {
+					clips = append(clips, v)
+				}
+			}
+		}
+	}
+	return groups, stills, clips
+}
+
+// groupByContentIdentifier pairs Live Photo halves with different names (edited or
+// exported copies) through the ContentIdentifier Apple writes into both files. Only
+// directories that hold an unpaired clip are read, and nothing is paired when
+// ExifTool is unavailable or for files inside archives.
+func groupByContentIdentifier(stills, clips []string, cfg *Config) []*AssetGroup {
+	clipDirs := make(map[string]bool)
+	for _, clip := range clips {
+		clipDirs[filepath.Dir(clip)] = true
+	}
+
+	var candidates []string
+	for _, still := range stills {
+		if clipDirs[filepath.Dir(still)] && !IsArchiveEntry(still) {
+			candidates = append(candidates, still)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	for _, clip := range clips {
+		if !IsArchiveEntry(clip) {
+			candidates = append(candidates, clip)
+		}
+	}
+
+	fileInfos, err := extractMetadata(candidates...)
+	if err != nil {
+		return nil
+	}
+
+	stillByID := make(map[string]string)
+	clipByID := make(map[string]string)
+	for _, fi := range fileInfos {
+		if fi.Err != nil {
+			continue
+		}
+		id, err := fi.GetString("ContentIdentifier")
+		if err != nil || id == "" {
+			continue
+		}
+		if determineFileType(fi.File, cfg) == TypeVideo {
+			clipByID[id] = fi.File
+		} else {
+			stillByID[id] = fi.File
+		}
+	}
+
+	var groups []*AssetGroup
+	for id, still := range stillByID {
+		if clip, ok := clipByID[id]; ok {
+			groups = append(groups, newAssetGroup(GroupLivePhoto, []string{still, clip}, cfg))
+		}
+	}
+	return groups
+}
+
+// groupAssets detects RAW+JPEG and Live Photo groups among scanned files and returns
+// them keyed by member path
+func groupAssets(primaries []string, cfg *Config) map[string]*AssetGroup {
+	groups, stills, clips := groupByStem(primaries, cfg)
+	if len(clips) > 0 {
+		groups = append(groups, groupByContentIdentifier(stills, clips, cfg)...)
+	}
+
+	result := make(map[string]*AssetGroup)
+	for _, group := range groups {
+		for _, member := range group.Members {
+			result[member] = group
+		}
+	}
+	return result
+}
+
+// assetDate returns the date and its source for src, shared across its group when it has one
+func assetDate(src string, cfg *Config, run *ImportRun) (captureDate, error) {
+	if group := run.Groups[src]; group != nil {
+		return group.bestDate(cfg, run.Formats)
+	}
+	return detectFileDate(src, cfg, run.Formats)
+}
+
+// setLeadDest records where the lead ended up in the library (an empty dest when it
+// was not placed), for the members processed after it
+func (g *AssetGroup) setLeadDest(dest string) {
+	g.leadMu.Lock()
+	defer g.leadMu.Unlock()
+	g.leadDest = dest
+}
+
+// placedLeadDest returns where the lead was placed, or "" before that
+func (g *AssetGroup) placedLeadDest() string {
+	g.leadMu.Lock()
+	defer g.leadMu.Unlock()
+	return g.leadDest
+}
+
+// GroupBatches splits files into the units an import processes in order on one worker:
+// each asset group's members in the run, lead first, at the place of the first of them,
+// and every other file on its own. groups are the run's, keyed by member path. Members
+// placed after their lead follow it to its final library path.
+func GroupBatches(files []string, groups map[string]*AssetGroup) [][]string {
+	var batches [][]string
+	at := make(map[*AssetGroup]int)
+	for _, f := range files {
+		group := groups[f]
+		if group == nil {
+			batches = append(batches, []string{f})
+			continue
+		}
+		i, ok := at[group]
+		if !ok {
+			i = len(batches)
+			at[group] = i
+			batches = append(batches, nil)
+		}
+		if f == group.Lead {
+			batches[i] = append([]string{f}, batches[i]...)
+		} else {
+			batches[i] = append(batches[i], f)
+		}
+	}
+	return batches
+}
+
+// assetDestinationPath returns the destination for src. Group members other than the
+// lead follow the lead's library file: where it was placed in this run or the session
+// being resumed, otherwise where it would go.
+func assetDestinationPath(src string, fileDate time.Time, confidence DateConfidence, fileType FileType, cfg *Config, run *ImportRun, user, srcHash string, session *ImportSession) (string, error) {
+	group := run.Groups[src]
+	if group == nil || group.Lead == src {
+		return generateDestinationPath(src, fileDate, confidence, fileType, cfg, user, srcHash)
+	}
+
+	leadDest := group.placedLeadDest()
+	if leadDest == "" && session != nil {
+		leadDest = session.groupLeadDest(group.ID)
+	}
+	if leadDest == "" {
+		var err error
+		leadDest, err = generateDestinationPath(group.Lead, fileDate, confidence, determineFileType(group.Lead, cfg), cfg, user, "")
+		if err != nil {
+			return "", err
+		}
+	}
+	return memberDestination(src, leadDest, cfg), nil
+}
+
+// memberDestination places group member src next to the lead's library file leadDest,
+// under its name with the member's own extension
+func memberDestination(src, leadDest string, cfg *Config) string {
+	leadBase := filepath.Base(leadDest)
+	stem := strings.TrimSuffix(leadBase, filepath.Ext(leadBase))
+	_, ext := mediaNameParts(src, cfg)
+	return filepath.Join(filepath.Dir(leadDest), stem+cfg.Naming.extension(ext))
+}
+
+// logAssetGroup records src's group in the session manifest the first time one of
+// its members is processed; the session remembers groups logged before a resume
+func logAssetGroup(src string, run *ImportRun, session *ImportSession) {
+	group := run.Groups[src]
+	if group == nil || session == nil {
+		return
+	}
+	session.LogAssetGroup(group)
+}
@@ -0,0 +1,256 @@
+package internal
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeExifJPEG writes a minimal JPEG whose only content is an EXIF DateTimeOriginal
+func writeExifJPEG(t *testing.T, path string, date time.Time) {
+	t.Helper()
+	writeExifJPEGWithOffset(t, path, date, "")
+}
+
+// writeExifJPEGWithOffset writes a minimal JPEG with an EXIF DateTimeOriginal and,
+// unless offset is empty, an OffsetTimeOriginal such as "+02:00"
+func writeExifJPEGWithOffset(t *testing.T, path string, date time.Time, offset string) {
+	t.Helper()
+
+	le := binary.LittleEndian
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+
+	// IFD0 with a single ExifIFD pointer (offset 26)
+	ifd0 := make([]byte, 18)
+	le.PutUint16(ifd0[0:], 1)
+	le.PutUint16(ifd0[2:], 0x8769)
+	le.PutUint16(ifd0[4:], 4)
+	le.PutUint32(ifd0[6:], 1)
+	le.PutUint32(ifd0[10:], 26)
+
+	// ExifIFD with DateTimeOriginal (and OffsetTimeOriginal) stored right after it
+	entries := 1
+	if offset != "" {
+		entries = 2
+	}
+	exifIFD := make([]byte, 2+12*entries+4)
+	dataStart := uint32(26 + len(exifIFD))
+	le.PutUint16(exifIFD[0:], uint16(entries))
+	le.PutUint16(exifIFD[2:], 0x9003)
+	le.PutUint16(exifIFD[4:], 2)
+	le.PutUint32(exifIFD[6:], 20)
+	le.PutUint32(exifIFD[10:], dataStart)
+	if offset != "" {
+		le.PutUint16(exifIFD[14:], 0x9011)
+		le.PutUint16(exifIFD[16:], 2)
+		le.PutUint32(exifIFD[18:], uint32(len(offset)+1))
+		le.PutUint32(exifIFD[22:], dataStart+20)
+	}
+
+	tiff = append(tiff, ifd0...)
+	tiff = append(tiff, exifIFD...)
+	tiff = append(tiff, []byte(date.Format("2006:01:02 15:04:05")+"\x00")...)
+	if offset != "" {
+		tiff = append(tiff, []byte(offset+"\x00")...)
+	}
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(data[4:], uint16(len(payload)+2))
+	data = append(data, payload...)
+	data = append(data, 0xFF, 0xD9)
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGroupByStem(t *testing.T) {
+	cfg := &Config{
+		ImageExt: []string{".jpg", ".heic", ".cr2", ".png"},
+		VideoExt: []string{".mov", ".mp4"},
+	}
+	primaries := []string{
+		"/in/IMG_1.CR2", "/in/IMG_1.JPG", // RAW+JPEG
+		"/in/IMG_2.HEIC", "/in/img_2.mov", // Live Photo
+		"/in/IMG_3.JPG", "/in/IMG_3.PNG", // Not a pair
+		"/in/IMG_4.HEIC", "/in/CLIP_4.MOV", // Different names: ContentIdentifier candidates
+	}
+
+	groups, stills, clips := groupByStem(primaries, cfg)
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 groups, got %d", len(groups))
+	}
+	if groups[0].Kind != GroupRawJPEG || groups[0].Lead != "/in/IMG_1.JPG" {
+		t.Errorf("Unexpected RAW+JPEG group: %+v", groups[0])
+	}
+	if groups[1].Kind != GroupLivePhoto || groups[1].Lead != "/in/IMG_2.HEIC" || len(groups[1].Members) != 2 {
+		t.Errorf("Unexpected Live Photo group: %+v", groups[1])
+	}
+	if len(stills) != 2 || len(clips) != 1 || clips[0] != "/in/CLIP_4.MOV" {
+		t.Errorf("Unexpected unpaired stills %v and clips %v", stills, clips)
+	}
+}
+
+func TestProcessFile_LivePhotoSharesDateAndFolder(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "images")
+	videos := filepath.Join(tempDir, "videos")
+	inputDir := filepath.Join(tempDir, "input")
+	os.MkdirAll(inputDir, 0755)
+
+	cfg := &Config{
+		User:     "user",
+		Library:  library,
+		VideoLib: videos,
+		ImageExt: []string{".jpg"},
+		VideoExt: []string{".mov"},
+	}
+
+	still := filepath.Join(inputDir, "IMG_7.JPG")
+	clip := filepath.Join(inputDir, "IMG_7.MOV")
+	writeExifJPEG(t, still, time.Date(2024, 3, 15, 14, 30, 22, 0, time.UTC))
+	os.WriteFile(clip, []byte("motion"), 0644)
+	mtime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	os.Chtimes(clip, mtime, mtime)
+
+	scan, err := ScanMediaFiles(inputDir, cfg)
+	if err != nil {
+		t.Fatalf("ScanMediaFiles failed: %v", err)
+	}
+	run := NewImportRun(scan)
+	files := run.Files
+	if run.Groups[clip] == nil || run.Groups[clip] != run.Groups[still] {
+		t.Fatalf("Expected still and clip to be grouped")
+	}
+
+	session, err := NewImportSession(library, videos, cfg.User, inputDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	// Clip first: it must still use the still's EXIF date
+	for _, src := range []string{clip, still} {
+		if err := ProcessFile(src, cfg, run, cfg.User, false, session, true); err != nil {
+			t.Fatalf("ProcessFile(%s) failed: %v", src, err)
+		}
+	}
+	session.Close()
+
+	destDir := filepath.Join(library, "user", "2024", "03", "15")
+	for _, name := range []string{"IMG_7.JPG", "IMG_7.MOV"} {
+		if _, err := os.Stat(filepath.Join(destDir, name)); err != nil {
+			t.Errorf("Expected %s in %s: %v", name, destDir, err)
+		}
+	}
+
+	events, err := ReadManifest(filepath.Join(session.SessionDir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	groupEvents := 0
+	for _, event := range events {
+		if event.Event == "asset_group" {
+			groupEvents++
+			if event.GroupKind != GroupLivePhoto || len(event.Members) != 2 || event.Members[0] != still {
+				t.Errorf("Unexpected asset_group event: %+v", event)
+			}
+		}
+	}
+	if groupEvents != 1 || len(files) != 2 {
+		t.Errorf("Expected one asset_group event for 2 files, got %d", groupEvents)
+	}
+}
+
+func TestProcessFile_GroupMembersFollowSuffixedLead(t *testing.T) {
+	originalNow := timeNow
+	defer func() { timeNow = originalNow }()
+	timeNow = func() time.Time { return time.Unix(1700000000, 0) }
+
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	inputDir := filepath.Join(tempDir, "input")
+	os.MkdirAll(inputDir, 0755)
+
+	cfg := &Config{
+		User:     "user",
+		Library:  library,
+		VideoLib: library,
+		ImageExt: []string{".jpg", ".cr2"},
+		VideoExt: []string{".mov"},
+	}
+
+	mtime := time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC)
+	raw := filepath.Join(inputDir, "IMG_1.CR2")
+	jpeg := filepath.Join(inputDir, "IMG_1.JPG")
+	os.WriteFile(raw, []byte("raw data"), 0644)
+	os.WriteFile(jpeg, []byte("jpeg data"), 0644)
+	os.Chtimes(raw, mtime, mtime)
+	os.Chtimes(jpeg, mtime, mtime)
+
+	// A different photo already has the JPEG's name, but not the RAW's
+	destDir := filepath.Join(library, "user", "noexif", "2024-02")
+	os.MkdirAll(destDir, 0755)
+	os.WriteFile(filepath.Join(destDir, "IMG_1.JPG"), []byte("another camera"), 0644)
+
+	scan, err := ScanMediaFiles(inputDir, cfg)
+	if err != nil {
+		t.Fatalf("ScanMediaFiles failed: %v", err)
+	}
+	run := NewImportRun(scan)
+	files := run.Files
+	if len(files) != 2 || files[0] != jpeg {
+		t.Fatalf("Expected the JPEG lead ordered first, got %v", files)
+	}
+
+	session, err := NewImportSession(library, library, cfg.User, inputDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	session.LogSessionStart(len(files))
+	for _, src := range files {
+		if err := ProcessFile(src, cfg, run, cfg.User, false, session, true); err != nil {
+			t.Fatalf("ProcessFile(%s) failed: %v", src, err)
+		}
+	}
+	session.Close()
+
+	for _, name := range []string{"IMG_1_1700000000.JPG", "IMG_1_1700000000.CR2"} {
+		if _, err := os.Stat(filepath.Join(destDir, name)); err != nil {
+			t.Errorf("Expected %s next to the suffixed lead: %v", name, err)
+		}
+	}
+
+	// Resuming neither logs the group again nor loses where its lead went
+	resumed, err := ResumeImportSession(library, session.ID)
+	if err != nil {
+		t.Fatalf("ResumeImportSession failed: %v", err)
+	}
+	group := run.Groups[jpeg]
+	logAssetGroup(jpeg, run, resumed)
+	if got := resumed.groupLeadDest(group.ID); got != filepath.Join(destDir, "IMG_1_1700000000.JPG") {
+		t.Errorf("Expected the lead's library path restored, got %q", got)
+	}
+	resumed.Close()
+
+	events, err := ReadManifest(filepath.Join(session.SessionDir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	groupEvents, copied := 0, 0
+	for _, event := range events {
+		switch event.Event {
+		case "asset_group":
+			groupEvents++
+		case "copied", "copied_timestamped":
+			copied++
+			if event.Group != group.ID {
+				t.Errorf("Expected group %s on %+v", group.ID, event)
+			}
+		}
+	}
+	if groupEvents != 1 || copied != 2 {
+		t.Errorf("Expected 1 asset_group and 2 copied events, got %d and %d", groupEvents, copied)
+	}
+}
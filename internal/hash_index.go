@@ -0,0 +1,352 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// HashIndex is a persistent content-hash catalog for a library. It lets ProcessFile
+// skip content that is already stored anywhere in the library, regardless of the
+// filename or detected date it arrives with.
+//
+// The index lives in <library>/.anduril/hashindex.jsonl as append-only JSON lines;
+// later lines win, so updates never rewrite the file during an import. Superseded
+// and stale lines are compacted away when a writable index is closed.
+type HashIndex struct {
+	path    string
+	file    *os.File // nil when read-only
+	entries map[string]hashIndexEntry
+	sizes   map[int64]bool // Sizes of indexed files, including since-dropped ones
+	lines   int            // Lines in the file, compacted on Close when more than entries
+	mu      sync.Mutex
+}
+
+type hashIndexEntry struct {
+	Hash    string `json:"hash"`
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime,omitempty"` // Unix nanoseconds; 0 in indexes written before it was recorded
+
+	// Set when the file at Path no longer holds the content Hash names, because a date
+	// was written into it after the import: its current hash and size
+	Content     string `json:"content,omitempty"`
+	ContentSize int64  `json:"content_size,omitempty"`
+}
+
+// file returns the hash and size the file at Path is expected to have
+func (e hashIndexEntry) file() (string, int64) {
+	if e.Content != "" {
+		return e.Content, e.ContentSize
+	}
+	return e.Hash, e.Size
+}
+
+// HashIndexPath returns the index location for a library root
+func HashIndexPath(libraryPath string) string {
+	return filepath.Join(libraryPath, ".anduril", "hashindex.jsonl")
+}
+
+// OpenHashIndex loads the library's hash index. With readOnly set nothing is created
+// or written (used for dry runs); lookups still work against the existing file.
+func OpenHashIndex(libraryPath string, readOnly bool) (*HashIndex, error) {
+	idx := &HashIndex{
+		path:    HashIndexPath(libraryPath),
+		entries: make(map[string]hashIndexEntry),
+		sizes:   make(map[int64]bool),
+	}
+
+	if err := idx.load(); err != nil {
+		return nil, err
+	}
+
+	if readOnly {
+		return idx, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create index directory: %w", err)
+	}
+	f, err := os.OpenFile(idx.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open hash index: %w", err)
+	}
+	idx.file = f
+
+	return idx, nil
+}
+
+// load reads existing entries; a missing index is simply empty
+func (x *HashIndex) load() error {
+	f, err := os.Open(x.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read hash index: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		x.lines++
+		var entry hashIndexEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// Blank or torn final line after a crash; skip it
+			continue
+		}
+		x.entries[entry.Hash] = entry
+		x.sizes[entry.Size] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read hash index: %w", err)
+	}
+
+	return nil
+}
+
+// Len returns the number of indexed hashes
+func (x *HashIndex) Len() int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return len(x.entries)
+}
+
+// Lookup returns the library path holding content with the given hash. An entry whose
+// file still has the indexed size and modification time is trusted; one with the same
+// size but another modification time is re-hashed, since edited or replaced content
+// can keep its size. Entries whose file disappeared or no longer matches are dropped.
+func (x *HashIndex) Lookup(hash string) (string, bool) {
+	x.mu.Lock()
+	entry, ok := x.entries[hash]
+	x.mu.Unlock()
+	if !ok {
+		return "", false
+	}
+
+	want, fileSize := entry.file()
+	info, err := os.Stat(entry.Path)
+	if err == nil && info.Size() == fileSize {
+		if info.ModTime().UnixNano() == entry.ModTime {
+			return entry.Path, true
+		}
+		if current, err := fileHash(entry.Path); err == nil && current == want {
+			// Same content with new times (or an entry from before times were indexed)
+			entry.ModTime = info.ModTime().UnixNano()
+			x.mu.Lock()
+			x.entries[hash] = entry
+			if x.file != nil && writeIndexEntry(x.file, entry) == nil {
+				x.lines++
+			}
+			x.mu.Unlock()
+			return entry.Path, true
+		}
+	}
+
+	x.mu.Lock()
+	if current, ok := x.entries[hash]; ok && current.Path == entry.Path {
+		delete(x.entries, hash)
+	}
+	x.mu.Unlock()
+	return "", false
+}
+
+// HasSize reports whether some indexed file has the given size, so callers can skip
+// hashing content that cannot be in the index. False positives are possible.
+func (x *HashIndex) HasSize(size int64) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.sizes[size]
+}
+
+// Add records that path holds content with the given hash
+func (x *HashIndex) Add(hash, path string, size int64) error {
+	return x.add(hashIndexEntry{Hash: hash, Path: path, Size: size})
+}
+
+// AddChanged records that path holds content imported with the given hash and size
+// that was changed since into content with hash content, so the unchanged original
+// is still recognised as imported
+func (x *HashIndex) AddChanged(hash string, size int64, path, content string, contentSize int64) error {
+	return x.add(hashIndexEntry{Hash: hash, Path: path, Size: size, Content: content, ContentSize: contentSize})
+}
+
+func (x *HashIndex) add(entry hashIndexEntry) error {
+	absPath, err := filepath.Abs(entry.Path)
+	if err != nil {
+		return err
+	}
+	entry.Path = absPath
+	if info, err := os.Stat(absPath); err == nil {
+		entry.ModTime = info.ModTime().UnixNano()
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if existing, ok := x.entries[entry.Hash]; ok && existing == entry {
+		return nil
+	}
+	x.entries[entry.Hash] = entry
+	x.sizes[entry.Size] = true
+
+	if x.file == nil {
+		return nil
+	}
+	if err := writeIndexEntry(x.file, entry); err != nil {
+		return err
+	}
+	x.lines++
+	return nil
+}
+
+// Close closes the index file, first rewriting it without superseded or stale lines
+// when it has any
+func (x *HashIndex) Close() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.file == nil {
+		return nil
+	}
+	err := x.file.Close()
+	x.file = nil
+	if err != nil {
+		return err
+	}
+	if x.lines > len(x.entries) {
+		return x.compactLocked()
+	}
+	return nil
+}
+
+// compactLocked atomically replaces the index file with the current entries
+func (x *HashIndex) compactLocked() error {
+	tmp := x.path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to compact hash index: %w", err)
+	}
+	for _, entry := range x.entries {
+		if err := writeIndexEntry(out, entry); err != nil {
+			out.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := commitTempFile(out, tmp, x.path); err != nil {
+		return fmt.Errorf("failed to compact hash index: %w", err)
+	}
+	x.lines = len(x.entries)
+	return nil
+}
+
+func writeIndexEntry(f *os.File, entry hashIndexEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal index entry: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write hash index: %w", err)
+	}
+	return nil
+}
+
+// RebuildHashIndex hashes every media file in the image and video libraries and
+// atomically replaces the index
+func RebuildHashIndex(cfg *Config) (int, error) {
+	indexPath := HashIndexPath(cfg.Library)
+	if err := os.MkdirAll(filepath.Dir(indexPath), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create index directory: %w", err)
+	}
+
+	tmp := indexPath + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create index: %w", err)
+	}
+
+	count := 0
+	err = walkLibraryMedia(cfg, func(path string, info os.FileInfo) error {
+		hash, err := fileHash(path)
+		if err != nil {
+			fmt.Printf("Warning: failed to hash %s: %v\n", path, err)
+			return nil
+		}
+		if err := writeIndexEntry(out, hashIndexEntry{Hash: hash, Path: path, Size: info.Size(), ModTime: info.ModTime().UnixNano()}); err != nil {
+			return err
+		}
+
+		count++
+		if count%100 == 0 {
+			fmt.Printf("Indexed %d files\n", count)
+		}
+		return nil
+	})
+	if err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return count, err
+	}
+
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return count, err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return count, err
+	}
+	if err := os.Rename(tmp, indexPath); err != nil {
+		os.Remove(tmp)
+		return count, err
+	}
+
+	return count, nil
+}
+
+// walkLibraryMedia calls fn for every media file in the image and video libraries,
+// with its absolute path. The imports/ session folders and the .anduril directory
+// are skipped since they only hold hardlinks and metadata, and superseded/ since its
+// files were replaced in the library.
+func walkLibraryMedia(cfg *Config, fn func(path string, info os.FileInfo) error) error {
+	roots := []string{cfg.Library}
+	if cfg.VideoLib != "" && cfg.VideoLib != cfg.Library {
+		roots = append(roots, cfg.VideoLib)
+	}
+
+	for _, root := range roots {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			return err
+		}
+
+		err = filepath.Walk(absRoot, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) && path == absRoot {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if info.IsDir() {
+				if path != absRoot && filepath.Dir(path) == absRoot && (info.Name() == "imports" || info.Name() == ".anduril" || info.Name() == supersededDir) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if determineFileType(path, cfg) == TypeOther {
+				return nil
+			}
+			return fn(path, info)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to index %s: %w", root, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashIndex_PersistsAndDropsStaleEntries(t *testing.T) {
+	tempDir := t.TempDir()
+
+	libFile := filepath.Join(tempDir, "user", "2024", "01", "01", "a.jpg")
+	os.MkdirAll(filepath.Dir(libFile), 0755)
+	os.WriteFile(libFile, []byte("content"), 0644)
+
+	idx, err := OpenHashIndex(tempDir, false)
+	if err != nil {
+		t.Fatalf("OpenHashIndex failed: %v", err)
+	}
+	if err := idx.Add("h1", libFile, 7); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	idx.Close()
+
+	// Entries survive reopening
+	idx, err = OpenHashIndex(tempDir, true)
+	if err != nil {
+		t.Fatalf("OpenHashIndex failed: %v", err)
+	}
+	if path, ok := idx.Lookup("h1"); !ok || path != libFile {
+		t.Errorf("Expected lookup to return %s, got %s (ok=%v)", libFile, path, ok)
+	}
+
+	// A removed library file no longer counts as present
+	os.Remove(libFile)
+	if _, ok := idx.Lookup("h1"); ok {
+		t.Errorf("Expected stale entry to be ignored")
+	}
+}
+
+func TestHashIndex_RehashesSameSizeChangesAndCompacts(t *testing.T) {
+	tempDir := t.TempDir()
+	libFile := filepath.Join(tempDir, "a.jpg")
+	os.WriteFile(libFile, []byte("content"), 0644)
+	hash, _ := fileHash(libFile)
+
+	idx, err := OpenHashIndex(tempDir, false)
+	if err != nil {
+		t.Fatalf("OpenHashIndex failed: %v", err)
+	}
+	idx.Add(hash, libFile, 7)
+	idx.Add(hash, libFile, 7) // Unchanged: not appended again
+	idx.Add("other", libFile, 7)
+
+	// Same size, new times and new content: no longer a duplicate
+	later := time.Now().Add(time.Hour)
+	os.WriteFile(libFile, []byte("CONTENT"), 0644)
+	os.Chtimes(libFile, later, later)
+	if _, ok := idx.Lookup(hash); ok {
+		t.Error("Expected same-size replaced content not to match")
+	}
+	idx.Close()
+
+	// Only the surviving entry is left in the file
+	data, _ := os.ReadFile(HashIndexPath(tempDir))
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Errorf("Expected the index compacted to 1 line, got %d:\n%s", lines, data)
+	}
+
+	// Touched but unchanged content is re-hashed and still found
+	idx, _ = OpenHashIndex(tempDir, true)
+	newHash, _ := fileHash(libFile)
+	idx.Add(newHash, libFile, 7)
+	os.Chtimes(libFile, later.Add(time.Hour), later.Add(time.Hour))
+	if path, ok := idx.Lookup(newHash); !ok || path != libFile {
+		t.Errorf("Expected touched file still found, got %q", path)
+	}
+}
+
+func TestHashIndex_AddChangedChecksTheFileAsItIsNow(t *testing.T) {
+	tempDir := t.TempDir()
+	libFile := filepath.Join(tempDir, "a.jpg")
+	// The source was "photo"; a date written back made the library copy longer
+	os.WriteFile(libFile, []byte("photo with date"), 0644)
+	libHash, _ := fileHash(libFile)
+
+	idx, err := OpenHashIndex(tempDir, false)
+	if err != nil {
+		t.Fatalf("OpenHashIndex failed: %v", err)
+	}
+	idx.Add(libHash, libFile, 15)
+	if err := idx.AddChanged("srchash", 5, libFile, libHash, 15); err != nil {
+		t.Fatalf("AddChanged failed: %v", err)
+	}
+	if !idx.HasSize(5) {
+		t.Error("Expected the source size indexed")
+	}
+
+	// New times force a re-hash, which must compare against the changed content
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(libFile, later, later)
+	if path, ok := idx.Lookup("srchash"); !ok || path != libFile {
+		t.Errorf("Expected the source hash to find %s, got %q (ok=%v)", libFile, path, ok)
+	}
+	idx.Close()
+
+	// The change survives reopening, and an edit to the library file drops the entry
+	idx, _ = OpenHashIndex(tempDir, true)
+	if _, ok := idx.Lookup("srchash"); !ok {
+		t.Error("Expected the changed entry to survive reopening")
+	}
+	os.WriteFile(libFile, []byte("photo with DATE"), 0644)
+	os.Chtimes(libFile, later.Add(time.Hour), later.Add(time.Hour))
+	if _, ok := idx.Lookup("srchash"); ok {
+		t.Error("Expected an edited library file to drop the entry")
+	}
+}
+
+func TestProcessFile_HashIndexSkipsRenamedDuplicate(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+
+	cfg := &Config{
+		User:     "user",
+		Library:  library,
+		VideoLib: library,
+		ImageExt: []string{".jpg"},
+		VideoExt: []string{".mp4"},
+	}
+
+	idx, err := OpenHashIndex(library, false)
+	if err != nil {
+		t.Fatalf("OpenHashIndex failed: %v", err)
+	}
+	defer idx.Close()
+	run := &ImportRun{Index: idx}
+
+	// Same content, different name and different modification date
+	first := filepath.Join(tempDir, "first.jpg")
+	second := filepath.Join(tempDir, "renamed.jpg")
+	os.WriteFile(first, []byte("same photo"), 0644)
+	os.WriteFile(second, []byte("same photo"), 0644)
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	_ = os.Chtimes(first, t1, t1)
+	_ = os.Chtimes(second, t2, t2)
+
+	session, err := NewImportSession(library, library, cfg.User, tempDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	defer session.Close()
+
+	for _, src := range []string{first, second} {
+		if err := ProcessFile(src, cfg, run, cfg.User, false, session, true); err != nil {
+			t.Fatalf("ProcessFile failed: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(library, "user", "noexif", "2024-06", "renamed.jpg")); !os.IsNotExist(err) {
+		t.Errorf("Renamed duplicate should not have been copied")
+	}
+
+	stats := session.GetStats()
+	if stats.Copied != 1 || stats.SkippedDuplicate != 1 {
+		t.Errorf("Expected 1 copied and 1 skipped, got %+v", stats)
+	}
+}
+
+func TestRebuildHashIndex(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+
+	cfg := &Config{
+		Library:  library,
+		VideoLib: library,
+		ImageExt: []string{".jpg"},
+		VideoExt: []string{".mp4"},
+	}
+
+	files := map[string]string{
+		filepath.Join(library, "user", "2024", "01", "01", "a.jpg"):     "a",
+		filepath.Join(library, "user", "noexif", "2024-02", "b.mp4"):    "b",
+		filepath.Join(library, "user", "2024", "01", "01", "notes.txt"): "skip",
+		filepath.Join(library, "imports", "2024-01-01-000000", "a.jpg"): "a-link",
+	}
+	for path, content := range files {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+
+	count, err := RebuildHashIndex(cfg)
+	if err != nil {
+		t.Fatalf("RebuildHashIndex failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 indexed files, got %d", count)
+	}
+
+	idx, err := OpenHashIndex(library, true)
+	if err != nil {
+		t.Fatalf("OpenHashIndex failed: %v", err)
+	}
+	hash, _ := fileHash(filepath.Join(library, "user", "2024", "01", "01", "a.jpg"))
+	if _, ok := idx.Lookup(hash); !ok {
+		t.Errorf("Expected a.jpg to be indexed")
+	}
+}
@@ -1,26 +1,35 @@
 package internal
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
-// ImportSession manages an import session with manifest logging and hardlink browser
+// ImportSession manages an import session with manifest logging and hardlink browser.
+// All methods are safe for concurrent use by multiple import workers.
 type ImportSession struct {
-	ID               string         // Session ID (timestamp: 2025-01-15-103045)
-	LibraryPath      string         // Library root path (absolute)
-	VideoLibraryPath string         // Video library root path (absolute)
-	SessionDir       string         // Full path to session directory
-	ManifestFile     *os.File       // Open file handle for manifest.jsonl
-	InputDir         string         // Original input directory (relative)
-	InputDirAbs      string         // Original input directory (absolute)
-	User             string         // User name
-	usedFilenames    map[string]int // Track filename usage for collision detection
-	stats            ImportStats    // Session statistics
+	ID               string            // Session ID (timestamp: 2025-01-15-103045)
+	LibraryPath      string            // Library root path (absolute)
+	VideoLibraryPath string            // Video library root path (absolute)
+	SessionDir       string            // Full path to session directory
+	ManifestFile     *os.File          // Open file handle for manifest.jsonl
+	InputDir         string            // Original input directory (relative)
+	InputDirAbs      string            // Original input directory (absolute)
+	User             string            // User name
+	usedFilenames    map[string]int    // Track filename usage for collision detection
+	stats            ImportStats       // Session statistics
+	completed        map[string]bool   // Sources already imported, keyed relative to InputDir (resume only)
+	pendingRemovals  []pendingRemoval  // Verified sources to delete once the import finishes (--move)
+	groupsLogged     map[string]bool   // Asset groups with an asset_group event
+	leadDests        map[string]string // Library path of each group's lead, by group ID (resume only)
+	placed           map[string]bool   // Library files the session created, which it never replaces
+	mu               sync.Mutex        // Guards usedFilenames, stats and manifest writes
 }
 
 // ImportStats tracks statistics for an import session
@@ -29,20 +38,63 @@ type ImportStats struct {
 	Copied            int
 	SkippedDuplicate  int
 	CopiedTimestamped int
+	SourcesRemoved    int
+	Sidecars          int
+	Excluded          int
+	SkippedUnchanged  int
+	DatesWritten      int
+	NearDuplicates    int // Flagged or skipped
+	SkippedNear       int
+	Conflicts         int // Destinations holding other content, however resolved
+	Replaced          int
+	SkippedConflict   int
 	Errors            int
 }
 
+// pendingRemoval is a source whose library copy has been verified, queued for deletion
+type pendingRemoval struct {
+	src     string
+	dest    string
+	hash    string
+	size    int64
+	modTime time.Time
+}
+
 // ManifestEvent represents a single event in the manifest log
 type ManifestEvent struct {
-	Event    string `json:"event"`
-	Ts       string `json:"ts"`
-	Src      string `json:"src,omitempty"`
-	Dest     string `json:"dest,omitempty"`
-	Hash     string `json:"hash,omitempty"`
-	Browse   string `json:"browse,omitempty"`
-	Size     int64  `json:"size,omitempty"`
-	Existing string `json:"existing,omitempty"`
-	Error    string `json:"error,omitempty"`
+	Event        string  `json:"event"`
+	Ts           string  `json:"ts"`
+	Src          string  `json:"src,omitempty"`
+	Dest         string  `json:"dest,omitempty"`
+	Hash         string  `json:"hash,omitempty"`
+	Browse       string  `json:"browse,omitempty"`
+	Size         int64   `json:"size,omitempty"`
+	Method       string  `json:"method,omitempty"`        // Transfer method: copy, hardlink, reflink, rename
+	OriginalName string  `json:"original_name,omitempty"` // Source file name when the library copy was renamed
+	Parent       string  `json:"parent,omitempty"`        // Library file a sidecar belongs to
+	Reason       string  `json:"reason,omitempty"`        // Why the scan excluded a file
+	DateSource   string  `json:"date_source,omitempty"`   // Where the capture date came from: exif, takeout, filename, mtime
+	CaptureDate  string  `json:"capture_date,omitempty"`  // Date used for the destination, in the library timezone
+	OriginalDate string  `json:"original_date,omitempty"` // EXIF date before clock offset correction
+	ClockOffset  string  `json:"clock_offset,omitempty"`  // Clock offset rule applied (Make/Model=offset)
+	UserRule     string  `json:"user_rule,omitempty"`     // User rule that chose the user folder (the user is in User)
+	Format       string  `json:"format,omitempty"`        // Format found by content when the extension disagreed
+	DateWritten  string  `json:"date_written,omitempty"`  // Where the capture date was written back: file or xmp
+	SrcHash      string  `json:"src_hash,omitempty"`      // Source content hash when the library file was changed (Hash is the library file's)
+	Similarity   float64 `json:"similarity,omitempty"`    // Perceptual similarity to Existing, from 0 to 1
+	Action       string  `json:"action,omitempty"`        // What the import did about a near-duplicate (flag or skip), or the conflict policy
+	ExistingHash string  `json:"existing_hash,omitempty"` // Content hash of the library file a conflict was about
+	Resolution   string  `json:"resolution,omitempty"`    // How a conflict was resolved: suffixed, skipped, replaced or failed
+	Quality      string  `json:"quality,omitempty"`       // keep-best: the incoming file against the library file
+	Superseded   string  `json:"superseded,omitempty"`    // Where a replaced library file was kept
+	SrcModTime   string  `json:"src_mtime,omitempty"`     // Source modification time when its removal was queued
+
+	// Asset group fields (RAW+JPEG, Live Photo)
+	Group     string   `json:"group,omitempty"`
+	GroupKind string   `json:"group_kind,omitempty"`
+	Members   []string `json:"members,omitempty"` // Source paths; the first is the lead
+	Existing  string   `json:"existing,omitempty"`
+	Error     string   `json:"error,omitempty"`
 
 	// Error details (for categorized errors)
 	ErrorCategory   string `json:"error_category,omitempty"`
@@ -52,16 +104,34 @@ type ManifestEvent struct {
 	// Session start/end fields
 	User              string `json:"user,omitempty"`
 	InputDir          string `json:"input_dir,omitempty"`
-	InputDirAbs       string `json:"input_dir_abs,omitempty"`       // Absolute path to input directory
-	LibraryPath       string `json:"library_path,omitempty"`        // Absolute path to library root
-	VideoLibraryPath  string `json:"video_library_path,omitempty"`  // Absolute path to video library
-	SessionDir        string `json:"session_dir,omitempty"`         // Absolute path to session directory
+	InputDirAbs       string `json:"input_dir_abs,omitempty"`      // Absolute path to input directory
+	LibraryPath       string `json:"library_path,omitempty"`       // Absolute path to library root
+	VideoLibraryPath  string `json:"video_library_path,omitempty"` // Absolute path to video library
+	SessionDir        string `json:"session_dir,omitempty"`        // Absolute path to session directory
 	TotalFiles        int    `json:"total_files,omitempty"`
+	AlreadyDone       int    `json:"already_done,omitempty"` // Sources skipped on resume
 	TotalScanned      int    `json:"total_scanned,omitempty"`
 	Copied            int    `json:"copied,omitempty"`
 	SkippedDuplicate  int    `json:"skipped_duplicate,omitempty"`
 	CopiedTimestamped int    `json:"copied_timestamped,omitempty"`
+	SourcesRemoved    int    `json:"sources_removed,omitempty"`
+	Sidecars          int    `json:"sidecars,omitempty"`
+	Excluded          int    `json:"excluded,omitempty"`
+	SkippedUnchanged  int    `json:"skipped_unchanged,omitempty"`
+	DatesWritten      int    `json:"dates_written,omitempty"`
+	NearDuplicates    int    `json:"near_duplicates,omitempty"`
+	SkippedNear       int    `json:"skipped_near_duplicate,omitempty"`
+	Conflicts         int    `json:"conflicts,omitempty"`
+	Replaced          int    `json:"replaced,omitempty"`
+	SkippedConflict   int    `json:"skipped_conflict,omitempty"`
 	ErrorCount        int    `json:"errors,omitempty"`
+
+	// Undo fields
+	Removed    int `json:"removed,omitempty"`    // Library files deleted
+	Restored   int `json:"restored,omitempty"`   // Moved-in files returned to their source
+	Reinstated int `json:"reinstated,omitempty"` // Replaced library files moved back from superseded/
+	Modified   int `json:"modified,omitempty"`   // Files kept because they changed since import
+	Missing    int `json:"missing,omitempty"`    // Files already gone
 }
 
 // NewImportSession creates a new import session
@@ -115,13 +185,208 @@ func NewImportSession(libraryPath, videoLibraryPath, user, inputDir string) (*Im
 		User:             user,
 		usedFilenames:    make(map[string]int),
 		stats:            ImportStats{},
+		groupsLogged:     make(map[string]bool),
+		placed:           make(map[string]bool),
+	}
+
+	return session, nil
+}
+
+// ResumeImportSession reopens an existing session so an interrupted import can continue.
+// Sources recorded as copied, copied_timestamped or skipped_duplicate are marked completed,
+// removals queued but not done are queued again, and new events are appended to the same
+// manifest.
+func ResumeImportSession(libraryPath, sessionID string) (*ImportSession, error) {
+	if sessionID == "" || filepath.Base(sessionID) != sessionID {
+		return nil, fmt.Errorf("invalid session id: %q", sessionID)
+	}
+
+	sessionDir := filepath.Join(libraryPath, "imports", sessionID)
+	manifestPath := filepath.Join(sessionDir, "manifest.jsonl")
+
+	events, err := ReadManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &ImportSession{
+		ID:            sessionID,
+		SessionDir:    sessionDir,
+		usedFilenames: make(map[string]int),
+		completed:     make(map[string]bool),
+		groupsLogged:  make(map[string]bool),
+		leadDests:     make(map[string]string),
+		placed:        make(map[string]bool),
+	}
+
+	groupLeads := make(map[string]string) // Lead source path to group ID
+	startFound := false
+	for _, event := range events {
+		if id, ok := groupLeads[event.Src]; ok {
+			switch event.Event {
+			case "copied", "copied_timestamped":
+				session.leadDests[id] = event.Dest
+			case "skipped_duplicate":
+				session.leadDests[id] = event.Existing
+			}
+		}
+
+		switch event.Event {
+		case "session_start":
+			if startFound {
+				continue
+			}
+			startFound = true
+			session.User = event.User
+			session.InputDir = event.InputDir
+			session.InputDirAbs = event.InputDirAbs
+			session.LibraryPath = event.LibraryPath
+			session.VideoLibraryPath = event.VideoLibraryPath
+
+		case "copied", "copied_timestamped":
+			session.placed[event.Dest] = true
+			if event.Event == "copied" {
+				session.stats.Copied++
+			} else {
+				session.stats.CopiedTimestamped++
+			}
+			if event.DateWritten != "" {
+				session.stats.DatesWritten++
+			}
+			// Every copy went through CreateHardlink, so replay its collision counter
+			session.usedFilenames[filepath.Base(event.Dest)]++
+			session.markCompleted(event.Src)
+
+		case "skipped_duplicate":
+			session.stats.SkippedDuplicate++
+			session.markCompleted(event.Src)
+
+		case "near_duplicate":
+			session.stats.NearDuplicates++
+			if event.Action == NearDuplicatesSkip {
+				session.stats.SkippedNear++
+				session.markCompleted(event.Src)
+			}
+
+		case "conflict":
+			session.stats.Conflicts++
+			switch event.Resolution {
+			case ResolutionReplaced:
+				session.stats.Replaced++
+			case ResolutionSkipped:
+				session.stats.SkippedConflict++
+				session.markCompleted(event.Src)
+			}
+
+		case "copied_sidecar":
+			session.stats.Sidecars++
+
+		case "asset_group":
+			session.groupsLogged[event.Group] = true
+			if len(event.Members) > 0 {
+				groupLeads[event.Members[0]] = event.Group
+			}
+
+		case "removal_queued":
+			modTime, err := time.Parse(time.RFC3339Nano, event.SrcModTime)
+			if err != nil {
+				continue
+			}
+			session.pendingRemovals = append(session.pendingRemovals, pendingRemoval{
+				src:     event.Src,
+				dest:    event.Dest,
+				hash:    event.Hash,
+				size:    event.Size,
+				modTime: modTime,
+			})
+
+		case "source_removed":
+			session.stats.SourcesRemoved++
+			session.dropPendingRemoval(event.Src)
+		}
+	}
+
+	if !startFound {
+		return nil, fmt.Errorf("manifest %s has no session_start event", manifestPath)
 	}
 
+	manifestFile, err := os.OpenFile(manifestPath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest file: %w", err)
+	}
+	session.ManifestFile = manifestFile
+
 	return session, nil
 }
 
+// ReadManifest parses all events from a session manifest.jsonl
+func ReadManifest(manifestPath string) ([]ManifestEvent, error) {
+	f, err := os.Open(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer f.Close()
+
+	var events []ManifestEvent
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var event ManifestEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			// A crash can leave a torn final line; everything before it is still valid
+			fmt.Printf("Warning: skipping unreadable manifest line %d: %v\n", lineNum, err)
+			continue
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	return events, nil
+}
+
+// markCompleted records a manifest source as done, keyed relative to the input directory
+func (s *ImportSession) markCompleted(src string) {
+	if src == "" {
+		return
+	}
+	// Sources were recorded as walked from InputDir, so the relative form is stable
+	// even if the original run used a different working directory
+	if rel, err := filepath.Rel(s.InputDir, src); err == nil {
+		s.completed[rel] = true
+		return
+	}
+	s.completed[src] = true
+}
+
+// IsCompleted reports whether a source under InputDirAbs was already imported by this session
+func (s *ImportSession) IsCompleted(src string) bool {
+	if s.completed == nil {
+		return false
+	}
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(s.InputDirAbs, abs)
+	if err != nil {
+		return false
+	}
+	return s.completed[rel]
+}
+
 // LogSessionStart writes the session start event to manifest
 func (s *ImportSession) LogSessionStart(totalFiles int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	event := ManifestEvent{
 		Event:            "session_start",
 		Ts:               time.Now().UTC().Format(time.RFC3339),
@@ -137,57 +402,393 @@ func (s *ImportSession) LogSessionStart(totalFiles int) error {
 	return s.writeEvent(event)
 }
 
+// LogExcluded records the files the scan left out in the session stats and, when
+// logEach is set, writes an "excluded" event for each
+func (s *ImportSession) LogExcluded(files []ExcludedFile, logEach bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.Excluded = len(files)
+	if !logEach {
+		return nil
+	}
+	for _, f := range files {
+		event := ManifestEvent{
+			Event:  "excluded",
+			Ts:     time.Now().UTC().Format(time.RFC3339),
+			Src:    f.Path,
+			Reason: f.Reason,
+		}
+		if err := s.writeEvent(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogSkippedUnchanged records in the session stats how many sources the source
+// cache skipped as already imported and unchanged
+func (s *ImportSession) LogSkippedUnchanged(count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.SkippedUnchanged = count
+}
+
+// LogSessionResume writes a resume marker before processing the remaining files
+func (s *ImportSession) LogSessionResume(totalFiles, alreadyDone int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event := ManifestEvent{
+		Event:       "session_resume",
+		Ts:          time.Now().UTC().Format(time.RFC3339),
+		User:        s.User,
+		InputDirAbs: s.InputDirAbs,
+		TotalFiles:  totalFiles,
+		AlreadyDone: alreadyDone,
+	}
+
+	return s.writeEvent(event)
+}
+
 // LogCopied logs a successful file copy
 func (s *ImportSession) LogCopied(src, dest, hash string, size int64, browsePath string) error {
-	s.stats.Copied++
+	return s.logCopy("copied", src, dest, hash, size, browsePath, "", importDetails{})
+}
+
+// LogCopiedTimestamped logs a file copied with timestamp suffix
+func (s *ImportSession) LogCopiedTimestamped(src, dest, hash string, size int64, browsePath string) error {
+	return s.logCopy("copied_timestamped", src, dest, hash, size, browsePath, "", importDetails{})
+}
+
+// logCopy logs a copied or copied_timestamped event with the transfer method, the date
+// used, the user rule and the detected format
+func (s *ImportSession) logCopy(eventName, src, dest, hash string, size int64, browsePath, method string, details importDetails) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if eventName == "copied_timestamped" {
+		s.stats.CopiedTimestamped++
+	} else {
+		s.stats.Copied++
+	}
+	s.placed[dest] = true
+
+	event := ManifestEvent{
+		Event:       eventName,
+		Ts:          time.Now().UTC().Format(time.RFC3339),
+		Src:         src,
+		Dest:        dest,
+		Hash:        hash,
+		Browse:      browsePath,
+		Size:        size,
+		Method:      method,
+		DateSource:  details.Date.Source,
+		Format:      details.Format,
+		DateWritten: details.DateWritten,
+		SrcHash:     details.SrcHash,
+		Group:       details.Group,
+	}
+	if details.DateWritten != "" {
+		s.stats.DatesWritten++
+	}
+	if !details.Date.Time.IsZero() {
+		event.CaptureDate = details.Date.Time.Format(time.RFC3339)
+	}
+	if details.Date.Offset != nil {
+		event.OriginalDate = details.Date.Original.Format(time.RFC3339)
+		event.ClockOffset = details.Date.Offset.String()
+	}
+	if details.Owner.Rule != "" {
+		event.User = details.Owner.User
+		event.UserRule = details.Owner.Rule
+	}
+	if name := filepath.Base(src); name != filepath.Base(dest) {
+		event.OriginalName = name
+	}
+
+	return s.writeEvent(event)
+}
+
+// LogSkippedDuplicate logs a skipped duplicate file
+func (s *ImportSession) LogSkippedDuplicate(src, existing, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.SkippedDuplicate++
+
+	event := ManifestEvent{
+		Event:    "skipped_duplicate",
+		Ts:       time.Now().UTC().Format(time.RFC3339),
+		Src:      src,
+		Existing: existing,
+		Hash:     hash,
+	}
+
+	return s.writeEvent(event)
+}
+
+// LogNearDuplicate logs an image that resembles the library image existing, and
+// whether it was imported anyway (flag) or skipped
+func (s *ImportSession) LogNearDuplicate(src, existing, hash string, similarity float64, action string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.NearDuplicates++
+	if action == NearDuplicatesSkip {
+		s.stats.SkippedNear++
+	}
+
+	event := ManifestEvent{
+		Event:      "near_duplicate",
+		Ts:         time.Now().UTC().Format(time.RFC3339),
+		Src:        src,
+		Existing:   existing,
+		Hash:       hash,
+		Similarity: similarity,
+		Action:     action,
+	}
+
+	return s.writeEvent(event)
+}
+
+// LogConflict logs what the conflict policy decided for src, whose destination held
+// other content
+func (s *ImportSession) LogConflict(src, hash string, c *fileConflict) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.Conflicts++
+	switch c.Resolution {
+	case ResolutionReplaced:
+		s.stats.Replaced++
+	case ResolutionSkipped:
+		s.stats.SkippedConflict++
+	}
+
+	event := ManifestEvent{
+		Event:        "conflict",
+		Ts:           time.Now().UTC().Format(time.RFC3339),
+		Src:          src,
+		Dest:         c.Dest,
+		Hash:         hash,
+		ExistingHash: c.ExistingHash,
+		Action:       c.Policy,
+		Resolution:   c.Resolution,
+		Quality:      c.Quality,
+		Superseded:   c.Superseded,
+	}
+
+	return s.writeEvent(event)
+}
+
+// LogSidecar logs a sidecar file placed next to its parent's library file
+func (s *ImportSession) LogSidecar(src, dest, parent, hash string, size int64, method string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.Sidecars++
 
 	event := ManifestEvent{
-		Event:  "copied",
+		Event:  "copied_sidecar",
 		Ts:     time.Now().UTC().Format(time.RFC3339),
 		Src:    src,
 		Dest:   dest,
+		Parent: parent,
 		Hash:   hash,
-		Browse: browsePath,
 		Size:   size,
+		Method: method,
 	}
 
 	return s.writeEvent(event)
 }
 
-// LogCopiedTimestamped logs a file copied with timestamp suffix
-func (s *ImportSession) LogCopiedTimestamped(src, dest, hash string, size int64, browsePath string) error {
-	s.stats.CopiedTimestamped++
+// LogSidecarConflict logs a sidecar kept at dest because its usual place, existing,
+// holds other content (existingHash)
+func (s *ImportSession) LogSidecarConflict(src, dest, existing, parent, hash, existingHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	event := ManifestEvent{
-		Event:  "copied_timestamped",
+		Event:        "sidecar_conflict",
+		Ts:           time.Now().UTC().Format(time.RFC3339),
+		Src:          src,
+		Dest:         dest,
+		Existing:     existing,
+		Parent:       parent,
+		Hash:         hash,
+		ExistingHash: existingHash,
+	}
+
+	return s.writeEvent(event)
+}
+
+// LogDateSidecar logs an XMP sidecar written to carry parent's capture date
+func (s *ImportSession) LogDateSidecar(src, dest, parent, hash string, size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event := ManifestEvent{
+		Event:  "date_sidecar",
 		Ts:     time.Now().UTC().Format(time.RFC3339),
 		Src:    src,
 		Dest:   dest,
+		Parent: parent,
 		Hash:   hash,
-		Browse: browsePath,
 		Size:   size,
 	}
 
 	return s.writeEvent(event)
 }
 
-// LogSkippedDuplicate logs a skipped duplicate file
-func (s *ImportSession) LogSkippedDuplicate(src, existing, hash string) error {
-	s.stats.SkippedDuplicate++
+// LogAssetGroup records that several source files form one asset, once per session
+// (resumes included)
+func (s *ImportSession) LogAssetGroup(group *AssetGroup) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.groupsLogged[group.ID] {
+		return nil
+	}
+	s.groupsLogged[group.ID] = true
+
+	members := []string{group.Lead}
+	for _, m := range group.Members {
+		if m != group.Lead {
+			members = append(members, m)
+		}
+	}
 
 	event := ManifestEvent{
-		Event:    "skipped_duplicate",
-		Ts:       time.Now().UTC().Format(time.RFC3339),
-		Src:      src,
-		Existing: existing,
-		Hash:     hash,
+		Event:     "asset_group",
+		Ts:        time.Now().UTC().Format(time.RFC3339),
+		Group:     group.ID,
+		GroupKind: group.Kind,
+		Members:   members,
 	}
 
 	return s.writeEvent(event)
 }
 
+// placedFile reports whether the session created the library file path
+func (s *ImportSession) placedFile(path string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.placed[path]
+}
+
+// groupLeadDest returns where the session being resumed placed the lead of group id
+func (s *ImportSession) groupLeadDest(id string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leadDests[id]
+}
+
+// LogSourceRemoved logs that a source file was deleted (or renamed away) after its
+// library copy at dest was verified
+func (s *ImportSession) LogSourceRemoved(src, dest, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.SourcesRemoved++
+
+	event := ManifestEvent{
+		Event: "source_removed",
+		Ts:    time.Now().UTC().Format(time.RFC3339),
+		Src:   src,
+		Dest:  dest,
+		Hash:  hash,
+	}
+
+	return s.writeEvent(event)
+}
+
+// QueueSourceRemoval records a source whose library copy at dest has been verified.
+// Nothing is deleted until RemoveQueuedSources runs, so an aborted import leaves
+// every source in place. The queue is logged as removal_queued events, so a resumed
+// session finishes the removals of the interrupted one.
+func (s *ImportSession) QueueSourceRemoval(src, dest, hash string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("failed to stat source %s: %w", src, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pendingRemovals = append(s.pendingRemovals, pendingRemoval{
+		src:     src,
+		dest:    dest,
+		hash:    hash,
+		size:    info.Size(),
+		modTime: info.ModTime(),
+	})
+
+	event := ManifestEvent{
+		Event:      "removal_queued",
+		Ts:         time.Now().UTC().Format(time.RFC3339),
+		Src:        src,
+		Dest:       dest,
+		Hash:       hash,
+		Size:       info.Size(),
+		SrcModTime: info.ModTime().UTC().Format(time.RFC3339Nano),
+	}
+
+	return s.writeEvent(event)
+}
+
+// dropPendingRemoval forgets the queued removal of src, once it has been removed
+func (s *ImportSession) dropPendingRemoval(src string) {
+	kept := s.pendingRemovals[:0]
+	for _, p := range s.pendingRemovals {
+		if p.src != src {
+			kept = append(kept, p)
+		}
+	}
+	s.pendingRemovals = kept
+}
+
+// RemoveQueuedSources deletes every queued source and logs a source_removed event for each.
+// Each library copy is hashed first: sources that changed since they were queued, or
+// whose library copy is gone or no longer holds their content, are kept and reported.
+func (s *ImportSession) RemoveQueuedSources() (int, []string) {
+	s.mu.Lock()
+	pending := s.pendingRemovals
+	s.pendingRemovals = nil
+	s.mu.Unlock()
+
+	removed := 0
+	var kept []string
+	for _, p := range pending {
+		info, err := os.Stat(p.src)
+		if err != nil || info.Size() != p.size || !info.ModTime().Equal(p.modTime) {
+			kept = append(kept, p.src)
+			continue
+		}
+		if hash, err := fileHash(p.dest); err != nil || hash != p.hash {
+			// Library copy vanished or changed; never delete the last good copy
+			kept = append(kept, p.src)
+			continue
+		}
+		if err := os.Remove(p.src); err != nil {
+			kept = append(kept, p.src)
+			continue
+		}
+		s.LogSourceRemoved(p.src, p.dest, p.hash)
+		removed++
+	}
+
+	return removed, kept
+}
+
 // LogError logs an error during file processing (legacy - use LogDetailedError for categorized errors)
 func (s *ImportSession) LogError(src string, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.stats.Errors++
 
 	event := ManifestEvent{
@@ -202,6 +803,9 @@ func (s *ImportSession) LogError(src string, err error) error {
 
 // LogDetailedError logs a categorized error with full details
 func (s *ImportSession) LogDetailedError(src string, procErr *ProcessError) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.stats.Errors++
 
 	event := ManifestEvent{
@@ -227,6 +831,9 @@ func (s *ImportSession) LogDetailedError(src string, procErr *ProcessError) erro
 
 // LogSessionEnd writes the session end event to manifest
 func (s *ImportSession) LogSessionEnd(stats ImportStats) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	event := ManifestEvent{
 		Event:             "session_end",
 		Ts:                time.Now().UTC().Format(time.RFC3339),
@@ -234,6 +841,16 @@ func (s *ImportSession) LogSessionEnd(stats ImportStats) error {
 		Copied:            stats.Copied,
 		SkippedDuplicate:  stats.SkippedDuplicate,
 		CopiedTimestamped: stats.CopiedTimestamped,
+		SourcesRemoved:    stats.SourcesRemoved,
+		Sidecars:          stats.Sidecars,
+		Excluded:          stats.Excluded,
+		SkippedUnchanged:  stats.SkippedUnchanged,
+		DatesWritten:      stats.DatesWritten,
+		NearDuplicates:    stats.NearDuplicates,
+		SkippedNear:       stats.SkippedNear,
+		Conflicts:         stats.Conflicts,
+		Replaced:          stats.Replaced,
+		SkippedConflict:   stats.SkippedConflict,
 		ErrorCount:        stats.Errors,
 	}
 
@@ -245,6 +862,9 @@ func (s *ImportSession) LogSessionEnd(stats ImportStats) error {
 func (s *ImportSession) CreateHardlink(libraryFilePath string) (string, error) {
 	basename := filepath.Base(libraryFilePath)
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// Check for collision
 	count, exists := s.usedFilenames[basename]
 	finalBasename := basename
@@ -270,18 +890,23 @@ func (s *ImportSession) CreateHardlink(libraryFilePath string) (string, error) {
 
 // GetStats returns the current session statistics
 func (s *ImportSession) GetStats() ImportStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.stats
 }
 
 // Close closes the manifest file and session
 func (s *ImportSession) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if s.ManifestFile != nil {
 		return s.ManifestFile.Close()
 	}
 	return nil
 }
 
-// writeEvent writes a manifest event as a JSON line
+// writeEvent writes a manifest event as a JSON line (caller must hold s.mu)
 func (s *ImportSession) writeEvent(event ManifestEvent) error {
 	data, err := json.Marshal(event)
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 )
 
@@ -271,3 +272,189 @@ func TestImportSession_CollisionWithExtensions(t *testing.T) {
 		t.Errorf("Expected 'photo_2.jpg', got '%s'", b3)
 	}
 }
+
+func TestImportSession_ConcurrentHardlinksAndLogs(t *testing.T) {
+	tempDir := t.TempDir()
+
+	session, err := NewImportSession(tempDir, "", "testuser", "/input")
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	defer session.Close()
+
+	const workers = 8
+	srcFiles := make([]string, workers)
+	for i := range srcFiles {
+		srcFiles[i] = filepath.Join(tempDir, "lib", string(rune('a'+i)), "photo.jpg")
+		os.MkdirAll(filepath.Dir(srcFiles[i]), 0755)
+		os.WriteFile(srcFiles[i], []byte{byte(i)}, 0644)
+	}
+
+	var wg sync.WaitGroup
+	names := make([]string, workers)
+	for i := range srcFiles {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name, err := session.CreateHardlink(srcFiles[i])
+			if err != nil {
+				t.Errorf("CreateHardlink failed: %v", err)
+				return
+			}
+			names[i] = name
+			session.LogCopied(srcFiles[i], srcFiles[i], "hash", 1, name)
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			t.Errorf("browse name %s handed out twice", name)
+		}
+		seen[name] = true
+	}
+
+	if stats := session.GetStats(); stats.Copied != workers {
+		t.Errorf("Expected %d copied, got %d", workers, stats.Copied)
+	}
+}
+
+func TestResumeImportSession_RestoresProgress(t *testing.T) {
+	tempDir := t.TempDir()
+	inputDir := filepath.Join(tempDir, "input")
+	os.MkdirAll(inputDir, 0755)
+
+	session, err := NewImportSession(tempDir, "", "testuser", inputDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	session.LogSessionStart(4)
+	session.LogCopied(filepath.Join(inputDir, "a.jpg"), "/lib/a.jpg", "h1", 1, "a.jpg")
+	session.LogCopiedTimestamped(filepath.Join(inputDir, "sub", "b.jpg"), "/lib/b_1.jpg", "h2", 1, "b_1.jpg")
+	session.LogSkippedDuplicate(filepath.Join(inputDir, "c.jpg"), "/lib/c.jpg", "h3")
+	session.LogError(filepath.Join(inputDir, "d.jpg"), os.ErrPermission)
+	session.Close()
+
+	resumed, err := ResumeImportSession(tempDir, session.ID)
+	if err != nil {
+		t.Fatalf("ResumeImportSession failed: %v", err)
+	}
+	defer resumed.Close()
+
+	if resumed.User != "testuser" {
+		t.Errorf("Expected user 'testuser', got '%s'", resumed.User)
+	}
+
+	for _, name := range []string{"a.jpg", filepath.Join("sub", "b.jpg"), "c.jpg"} {
+		if !resumed.IsCompleted(filepath.Join(inputDir, name)) {
+			t.Errorf("Expected %s to be completed", name)
+		}
+	}
+	// Errored files must be retried
+	if resumed.IsCompleted(filepath.Join(inputDir, "d.jpg")) {
+		t.Errorf("Expected d.jpg to be retried after an error")
+	}
+
+	stats := resumed.GetStats()
+	if stats.Copied != 1 || stats.CopiedTimestamped != 1 || stats.SkippedDuplicate != 1 || stats.Errors != 0 {
+		t.Errorf("Unexpected restored stats: %+v", stats)
+	}
+
+	// New events are appended to the same manifest
+	if err := resumed.LogSessionResume(1, 3); err != nil {
+		t.Fatalf("LogSessionResume failed: %v", err)
+	}
+	events, err := ReadManifest(filepath.Join(resumed.SessionDir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if len(events) != 6 || events[5].Event != "session_resume" {
+		t.Errorf("Expected session_resume appended as 6th event, got %d events", len(events))
+	}
+}
+
+func TestResumeImportSession_RejectsBadID(t *testing.T) {
+	tempDir := t.TempDir()
+
+	for _, id := range []string{"", "../etc", "missing-session"} {
+		if _, err := ResumeImportSession(tempDir, id); err == nil {
+			t.Errorf("Expected error for session id %q", id)
+		}
+	}
+}
+
+func TestImportSession_RemoveQueuedSourcesKeepsChangedFiles(t *testing.T) {
+	tempDir := t.TempDir()
+
+	session, err := NewImportSession(tempDir, "", "user", tempDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	defer session.Close()
+
+	dest := filepath.Join(tempDir, "dest.jpg")
+	editedDest := filepath.Join(tempDir, "edited-dest.jpg")
+	unchanged := filepath.Join(tempDir, "unchanged.jpg")
+	changed := filepath.Join(tempDir, "changed.jpg")
+	libraryChanged := filepath.Join(tempDir, "library-changed.jpg")
+	for _, p := range []string{dest, editedDest, unchanged, changed, libraryChanged} {
+		os.WriteFile(p, []byte("data"), 0644)
+	}
+	hash, _ := fileHash(dest)
+
+	session.QueueSourceRemoval(unchanged, dest, hash)
+	session.QueueSourceRemoval(changed, dest, hash)
+	session.QueueSourceRemoval(libraryChanged, editedDest, hash)
+	os.WriteFile(changed, []byte("edited after verification"), 0644)
+	os.WriteFile(editedDest, []byte("edited in the library"), 0644)
+
+	removed, kept := session.RemoveQueuedSources()
+	if removed != 1 || len(kept) != 2 || kept[0] != changed || kept[1] != libraryChanged {
+		t.Errorf("Expected unchanged removed, changed and library-changed kept, got removed=%d kept=%v", removed, kept)
+	}
+	for _, p := range []string{changed, libraryChanged} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("%s should be kept: %v", p, err)
+		}
+	}
+}
+
+func TestResumeImportSession_FinishesQueuedRemovals(t *testing.T) {
+	tempDir := t.TempDir()
+	inputDir := filepath.Join(tempDir, "input")
+	os.MkdirAll(inputDir, 0755)
+
+	session, err := NewImportSession(tempDir, "", "user", inputDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	session.LogSessionStart(2)
+	dest := filepath.Join(tempDir, "dest.jpg")
+	os.WriteFile(dest, []byte("data"), 0644)
+	hash, _ := fileHash(dest)
+	done := filepath.Join(inputDir, "done.jpg")
+	pending := filepath.Join(inputDir, "pending.jpg")
+	for _, p := range []string{done, pending} {
+		os.WriteFile(p, []byte("data"), 0644)
+		session.QueueSourceRemoval(p, dest, hash)
+	}
+	// The interrupted run removed one source before it stopped
+	os.Remove(done)
+	session.LogSourceRemoved(done, dest, hash)
+	session.Close()
+
+	resumed, err := ResumeImportSession(tempDir, session.ID)
+	if err != nil {
+		t.Fatalf("ResumeImportSession failed: %v", err)
+	}
+	defer resumed.Close()
+
+	removed, kept := resumed.RemoveQueuedSources()
+	if removed != 1 || len(kept) != 0 {
+		t.Errorf("Expected the one outstanding removal done, got removed=%d kept=%v", removed, kept)
+	}
+	if _, err := os.Stat(pending); !os.IsNotExist(err) {
+		t.Errorf("Expected %s removed on resume", pending)
+	}
+}
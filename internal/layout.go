@@ -0,0 +1,343 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// LayoutConfig holds the destination path templates, relative to the image or video
+// library root. Each template is a slash-separated path of literal text and {tokens}.
+type LayoutConfig struct {
+	Image       string `mapstructure:"image"`        // Images with a trusted date (EXIF or filename)
+	ImageNoExif string `mapstructure:"image_noexif"` // Images dated from file timestamps
+	Video       string `mapstructure:"video"`        // Videos with a trusted date
+	VideoNoExif string `mapstructure:"video_noexif"` // Videos dated from file timestamps
+}
+
+// Default layouts, matching the historical <user>/YYYY/MM/DD and <user>/noexif/YYYY-MM folders
+const (
+	DefaultLayout       = "{user}/{yyyy}/{mm}/{dd}/{name}"
+	DefaultLayoutNoExif = "{user}/noexif/{yyyy}-{mm}/{name}"
+)
+
+// NamingConfig controls how imported files are renamed
+type NamingConfig struct {
+	Template     string `mapstructure:"template"`      // e.g. "{yyyy}{mm}{dd}_{HH}{MM}{SS}_{hash8}.{ext}"; empty keeps the original name
+	Extension    string `mapstructure:"extension"`     // "keep" (default), "lower" or "normalize"
+	FixExtension bool   `mapstructure:"fix_extension"` // Give files whose content disagrees with their extension the right one
+}
+
+// Extension modes for NamingConfig.Extension
+const (
+	ExtensionKeep      = "keep"      // Keep the extension as found
+	ExtensionLower     = "lower"     // Lowercase it (.JPG → .jpg)
+	ExtensionNormalize = "normalize" // Lowercase and use one spelling per format (.JPEG → .jpg)
+)
+
+// normalizedExtensions maps alternate spellings to the canonical extension
+var normalizedExtensions = map[string]string{
+	".jpeg": ".jpg",
+	".jpe":  ".jpg",
+	".tif":  ".tiff",
+}
+
+// templateTokens lists the tokens shared by layout and naming templates.
+// Upper-case {HH}{MM}{SS} are aliases for {hh}{min}{ss}.
+var templateTokens = map[string]bool{
+	"user": true, "yyyy": true, "yy": true, "mm": true, "dd": true,
+	"hh": true, "min": true, "ss": true, "HH": true, "MM": true, "SS": true,
+	"stem": true, "ext": true,
+	"type": true, "camera_make": true, "camera_model": true,
+}
+
+var layoutTokenPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// unsafePathChars are replaced in token values so metadata can never add path segments
+var unsafePathChars = strings.NewReplacer("/", "_", "\\", "_", ":", "_", "\x00", "")
+
+// ValidateLayout checks a layout template: only known tokens, balanced braces, a
+// relative path without "." or ".." segments, and a last segment that contains the
+// file name so different files never share a destination.
+func ValidateLayout(tmpl string) error {
+	if strings.TrimSpace(tmpl) == "" {
+		return errors.New("template is empty")
+	}
+	if strings.HasPrefix(tmpl, "/") || strings.HasPrefix(tmpl, "\\") || filepath.IsAbs(tmpl) {
+		return fmt.Errorf("template %q must be relative to the library root", tmpl)
+	}
+
+	if err := checkTokens(tmpl, func(token string) bool {
+		return templateTokens[token] || token == "name"
+	}); err != nil {
+		return err
+	}
+
+	segments := strings.Split(filepath.ToSlash(tmpl), "/")
+	for _, segment := range segments {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("template %q has an empty, \".\" or \"..\" path segment", tmpl)
+		}
+	}
+
+	last := segments[len(segments)-1]
+	if !strings.Contains(last, "{name}") && !strings.Contains(last, "{stem}") {
+		return fmt.Errorf("template %q must end with a {name} or {stem} file name", tmpl)
+	}
+	if strings.Contains(strings.Join(segments[:len(segments)-1], "/"), "{name}") {
+		return fmt.Errorf("template %q may only use {name} in the file name", tmpl)
+	}
+
+	return nil
+}
+
+// checkTokens rejects unbalanced braces and tokens not accepted by allowed
+func checkTokens(tmpl string, allowed func(string) bool) error {
+	for _, match := range layoutTokenPattern.FindAllStringSubmatch(tmpl, -1) {
+		if !allowed(match[1]) {
+			return fmt.Errorf("template %q uses unknown token {%s}", tmpl, match[1])
+		}
+	}
+	if rest := layoutTokenPattern.ReplaceAllString(tmpl, ""); strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("template %q has unbalanced braces", tmpl)
+	}
+	return nil
+}
+
+// Validate checks all four templates
+func (l LayoutConfig) Validate() error {
+	for _, t := range []struct{ key, tmpl string }{
+		{"layout.image", l.Image},
+		{"layout.image_noexif", l.ImageNoExif},
+		{"layout.video", l.Video},
+		{"layout.video_noexif", l.VideoNoExif},
+	} {
+		if err := ValidateLayout(t.tmpl); err != nil {
+			return fmt.Errorf("invalid %s: %w", t.key, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the naming template and extension mode. A naming template is a
+// single file name that must end with .{ext}, so the file type survives the rename.
+func (n NamingConfig) Validate() error {
+	switch n.Extension {
+	case "", ExtensionKeep, ExtensionLower, ExtensionNormalize:
+	default:
+		return fmt.Errorf("invalid naming.extension %q (use keep, lower or normalize)", n.Extension)
+	}
+
+	if n.Template == "" {
+		return nil
+	}
+	if strings.ContainsAny(n.Template, "/\\") {
+		return fmt.Errorf("invalid naming.template: %q must be a file name, not a path", n.Template)
+	}
+	if err := checkTokens(n.Template, func(token string) bool {
+		return templateTokens[token] || token == "hash" || token == "hash8"
+	}); err != nil {
+		return fmt.Errorf("invalid naming.template: %w", err)
+	}
+	if !strings.HasSuffix(n.Template, ".{ext}") {
+		return fmt.Errorf("invalid naming.template: %q must end with .{ext}", n.Template)
+	}
+	return nil
+}
+
+// usesHash reports whether renaming needs the content hash
+func (n NamingConfig) usesHash() bool {
+	return strings.Contains(n.Template, "{hash")
+}
+
+// extension applies the extension mode to ext (including the dot)
+func (n NamingConfig) extension(ext string) string {
+	switch n.Extension {
+	case ExtensionLower:
+		return strings.ToLower(ext)
+	case ExtensionNormalize:
+		ext = strings.ToLower(ext)
+		if canonical, ok := normalizedExtensions[ext]; ok {
+			return canonical
+		}
+		return ext
+	}
+	return ext
+}
+
+// template picks the layout for a file type and date confidence, falling back to the
+// defaults for templates left empty (e.g. a Config built in code)
+func (l LayoutConfig) template(fileType FileType, highConfidenceDate bool) string {
+	var tmpl string
+	switch {
+	case fileType == TypeVideo && highConfidenceDate:
+		tmpl = l.Video
+	case fileType == TypeVideo:
+		tmpl = l.VideoNoExif
+	case highConfidenceDate:
+		tmpl = l.Image
+	default:
+		tmpl = l.ImageNoExif
+	}
+
+	if tmpl == "" {
+		if highConfidenceDate {
+			return DefaultLayout
+		}
+		return DefaultLayoutNoExif
+	}
+	return tmpl
+}
+
+// renderLayout expands tmpl for src and joins it under root, applying the naming
+// template to {name} first. The result is rejected if it would resolve outside root.
+func renderLayout(tmpl, root, src string, fileDate time.Time, fileType FileType, cfg *Config, user, srcHash string) (string, error) {
+	stem, srcExt := mediaNameParts(src, cfg)
+	ext := cfg.Naming.extension(srcExt)
+
+	values := map[string]string{
+		"user": user,
+		"yyyy": fmt.Sprintf("%04d", fileDate.Year()),
+		"yy":   fmt.Sprintf("%02d", fileDate.Year()%100),
+		"mm":   fmt.Sprintf("%02d", fileDate.Month()),
+		"dd":   fmt.Sprintf("%02d", fileDate.Day()),
+		"hh":   fmt.Sprintf("%02d", fileDate.Hour()),
+		"min":  fmt.Sprintf("%02d", fileDate.Minute()),
+		"ss":   fmt.Sprintf("%02d", fileDate.Second()),
+		"stem": stem,
+		"ext":  strings.TrimPrefix(ext, "."),
+		"type": "images",
+	}
+	values["HH"], values["MM"], values["SS"] = values["hh"], values["min"], values["ss"]
+	if fileType == TypeVideo {
+		values["type"] = "videos"
+	}
+
+	// Camera metadata costs an extra read, so only extract it when used
+	if strings.Contains(tmpl, "{camera_") || strings.Contains(cfg.Naming.Template, "{camera_") {
+		camera := getCameraInfo(src, cfg)
+		values["camera_make"] = camera.Make
+		values["camera_model"] = camera.Model
+	}
+
+	values["name"] = stem + ext
+	if cfg.Naming.Template != "" {
+		if cfg.Naming.usesHash() {
+			if srcHash == "" {
+				var err error
+				if srcHash, err = fileHash(src); err != nil {
+					return "", fmt.Errorf("failed to hash source %s: %w", src, err)
+				}
+			}
+			values["hash"] = srcHash
+			values["hash8"] = srcHash[:8]
+		}
+		renamed, err := expandTemplate(cfg.Naming.Template, values)
+		if err != nil {
+			return "", err
+		}
+		values["name"] = renamed
+	}
+
+	rendered, err := expandTemplate(tmpl, values)
+	if err != nil {
+		return "", err
+	}
+
+	return joinInsideRoot(root, rendered)
+}
+
+// expandTemplate substitutes {token} values into tmpl. Values are sanitized so
+// metadata can never add path segments; empty values become "unknown".
+func expandTemplate(tmpl string, values map[string]string) (string, error) {
+	var expandErr error
+	expanded := layoutTokenPattern.ReplaceAllStringFunc(tmpl, func(token string) string {
+		key := token[1 : len(token)-1]
+		value, ok := values[key]
+		if !ok {
+			expandErr = fmt.Errorf("unknown template token %s", token)
+			return ""
+		}
+		value = strings.TrimSpace(unsafePathChars.Replace(value))
+		if value == "" || value == "." || value == ".." {
+			value = "unknown"
+		}
+		return value
+	})
+	return expanded, expandErr
+}
+
+// joinInsideRoot joins rel under root and fails if the cleaned result escapes root
+func joinInsideRoot(root, rel string) (string, error) {
+	dest := filepath.Join(root, filepath.FromSlash(rel))
+	if !insideRoot(root, dest) {
+		return "", fmt.Errorf("destination %s escapes library root %s", dest, root)
+	}
+	return dest, nil
+}
+
+// insideRoot reports whether path lies below root, not at it
+func insideRoot(root, path string) bool {
+	relToRoot, err := filepath.Rel(filepath.Clean(root), filepath.Clean(path))
+	return err == nil && relToRoot != "." && relToRoot != ".." && !strings.HasPrefix(relToRoot, ".."+string(filepath.Separator)) && !filepath.IsAbs(relToRoot)
+}
+
+// cameraInfo identifies the camera that took a file
+type cameraInfo struct {
+	Make   string
+	Model  string
+	Serial string
+}
+
+// getCameraInfo returns the camera make, model and serial number from EXIF, with empty
+// strings when unavailable. Images are read natively first; ExifTool covers videos and
+// RAW files outside archives.
+func getCameraInfo(path string, cfg *Config) cameraInfo {
+	if !cfg.UseExifTool || IsArchiveEntry(path) {
+		if f, err := openSource(path); err == nil {
+			x, err := exif.Decode(f)
+			f.Close()
+			if err == nil {
+				loadExtraExifTags(x)
+				return cameraInfo{
+					Make:   exifString(x, exif.Make),
+					Model:  exifString(x, exif.Model),
+					Serial: exifString(x, exifBodySerialNumber),
+				}
+			}
+		}
+	}
+	if IsArchiveEntry(path) {
+		return cameraInfo{}
+	}
+
+	fileInfos, err := extractMetadata(path)
+	if err != nil || len(fileInfos) != 1 || fileInfos[0].Err != nil {
+		return cameraInfo{}
+	}
+	cameraMake, _ := fileInfos[0].GetString("Make")
+	cameraModel, _ := fileInfos[0].GetString("Model")
+	serial, _ := fileInfos[0].GetString("SerialNumber")
+	return cameraInfo{
+		Make:   strings.TrimSpace(cameraMake),
+		Model:  strings.TrimSpace(cameraModel),
+		Serial: strings.TrimSpace(serial),
+	}
+}
+
+func exifString(x *exif.Exif, field exif.FieldName) string {
+	tag, err := x.Get(field)
+	if err != nil {
+		return ""
+	}
+	value, err := tag.StringVal()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.Trim(value, "\x00"))
+}
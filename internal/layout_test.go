@@ -0,0 +1,198 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestValidateLayout(t *testing.T) {
+	tests := []struct {
+		tmpl    string
+		wantErr bool
+	}{
+		{DefaultLayout, false},
+		{DefaultLayoutNoExif, false},
+		{"{user}/{yyyy}/{yyyy}-{mm}-{dd}/{name}", false},
+		{"{yyyy}/{mm}/{camera_model}/{name}", false},
+		{"{type}/{yyyy}/{stem}_{hh}{min}{ss}.{ext}", false},
+		{"", true},
+		{"/abs/{name}", true},
+		{"{user}/../{name}", true},
+		{"{user}//{name}", true},
+		{"{user}/{yyyy}", true},
+		{"{name}/{yyyy}/{name}", true},
+		{"{user}/{month}/{name}", true},
+		{"{user}/{yyyy/{name}", true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateLayout(tt.tmpl)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateLayout(%q) error = %v, wantErr %v", tt.tmpl, err, tt.wantErr)
+		}
+	}
+}
+
+func TestGenerateDestinationPath_Layouts(t *testing.T) {
+	library := filepath.Join(t.TempDir(), "library")
+	videos := filepath.Join(t.TempDir(), "videos")
+	date := time.Date(2024, 3, 15, 14, 30, 22, 0, time.UTC)
+
+	cfg := &Config{
+		Library:  library,
+		VideoLib: videos,
+		Layout: LayoutConfig{
+			Image:       "{user}/{yyyy}/{yyyy}-{mm}-{dd}/{name}",
+			ImageNoExif: "unsorted/{yyyy}/{name}",
+			Video:       "{yyyy}/{type}/{stem}_{hh}{min}.{ext}",
+		},
+	}
+
+	tests := []struct {
+		name       string
+		confidence DateConfidence
+		fileType   FileType
+		want       string
+	}{
+		{"image high", HIGH, TypeImage, filepath.Join(library, "anna", "2024", "2024-03-15", "IMG_1.jpg")},
+		{"image noexif", LOW, TypeImage, filepath.Join(library, "unsorted", "2024", "IMG_1.jpg")},
+		{"video high", MEDIUM, TypeVideo, filepath.Join(videos, "2024", "videos", "IMG_1_1430.jpg")},
+		// Empty templates fall back to the defaults
+		{"video noexif", VERY_LOW, TypeVideo, filepath.Join(videos, "anna", "noexif", "2024-03", "IMG_1.jpg")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generateDestinationPath("/src/IMG_1.jpg", date, tt.confidence, tt.fileType, cfg, "anna", "")
+			if err != nil {
+				t.Fatalf("generateDestinationPath failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateDestinationPath_StaysInsideLibrary(t *testing.T) {
+	library := filepath.Join(t.TempDir(), "library")
+	cfg := &Config{Library: library, VideoLib: library}
+	date := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	// Token values cannot introduce path segments
+	got, err := generateDestinationPath("/src/a.jpg", date, HIGH, TypeImage, cfg, "../../etc", "")
+	if err != nil {
+		t.Fatalf("generateDestinationPath failed: %v", err)
+	}
+	if rel, _ := filepath.Rel(library, got); rel != filepath.Join(".._.._etc", "2024", "03", "15", "a.jpg") {
+		t.Errorf("Unexpected destination %s", got)
+	}
+
+	// An unvalidated template that escapes the root is refused
+	cfg.Layout.Image = "../outside/{name}"
+	if _, err := generateDestinationPath("/src/a.jpg", date, HIGH, TypeImage, cfg, "user", ""); err == nil {
+		t.Errorf("Expected escaping template to be rejected")
+	}
+}
+
+func TestNamingConfig_Validate(t *testing.T) {
+	tests := []struct {
+		naming  NamingConfig
+		wantErr bool
+	}{
+		{NamingConfig{}, false},
+		{NamingConfig{Template: "{yyyy}{mm}{dd}_{HH}{MM}{SS}_{hash8}.{ext}", Extension: ExtensionNormalize}, false},
+		{NamingConfig{Template: "{stem}_{hash}.{ext}"}, false},
+		{NamingConfig{Template: "{yyyy}/{stem}.{ext}"}, true},
+		{NamingConfig{Template: "{yyyy}{mm}{dd}"}, true},
+		{NamingConfig{Template: "{name}.{ext}"}, true},
+		// The dot is part of the template, as in layouts
+		{NamingConfig{Template: "{stem}{ext}"}, true},
+		{NamingConfig{Extension: "upper"}, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.naming.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tt.naming, err, tt.wantErr)
+		}
+	}
+}
+
+func TestGenerateDestinationPath_NamingTemplate(t *testing.T) {
+	library := filepath.Join(t.TempDir(), "library")
+	date := time.Date(2024, 3, 15, 14, 30, 22, 0, time.UTC)
+	hash := "0123456789abcdef"
+
+	tests := []struct {
+		naming NamingConfig
+		src    string
+		want   string
+	}{
+		{NamingConfig{}, "/src/IMG_0001.JPEG", "IMG_0001.JPEG"},
+		{NamingConfig{Extension: ExtensionLower}, "/src/IMG_0001.JPEG", "IMG_0001.jpeg"},
+		{NamingConfig{Extension: ExtensionNormalize}, "/src/IMG_0001.JPEG", "IMG_0001.jpg"},
+		{NamingConfig{Template: "{yyyy}{mm}{dd}_{HH}{MM}{SS}_{hash8}.{ext}", Extension: ExtensionNormalize}, "/src/IMG_0001.JPEG", "20240315_143022_01234567.jpg"},
+		{NamingConfig{Template: "{stem}_{yy}.{ext}"}, "/src/scan.TIF", "scan_24.TIF"},
+	}
+
+	for _, tt := range tests {
+		cfg := &Config{Library: library, VideoLib: library, Naming: tt.naming}
+		got, err := generateDestinationPath(tt.src, date, HIGH, TypeImage, cfg, "user", hash)
+		if err != nil {
+			t.Fatalf("generateDestinationPath failed: %v", err)
+		}
+		want := filepath.Join(library, "user", "2024", "03", "15", tt.want)
+		if got != want {
+			t.Errorf("naming %+v: got %s, want %s", tt.naming, got, want)
+		}
+	}
+}
+
+func TestProcessFile_NamingTemplateRecordsOriginalName(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	srcDir := filepath.Join(tempDir, "src")
+	os.MkdirAll(srcDir, 0755)
+
+	cfg := &Config{
+		User:     "user",
+		Library:  library,
+		VideoLib: library,
+		ImageExt: []string{".jpg"},
+		VideoExt: []string{".mp4"},
+		Naming:   NamingConfig{Template: "{yyyy}{mm}{dd}_{hash8}.{ext}", Extension: ExtensionLower},
+	}
+
+	src := filepath.Join(srcDir, "IMG_0001.JPG")
+	os.WriteFile(src, []byte("renamed photo"), 0644)
+	mtime := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	os.Chtimes(src, mtime, mtime)
+	hash, _ := fileHash(src)
+
+	session, err := NewImportSession(library, library, cfg.User, srcDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	if err := ProcessFile(src, cfg, nil, cfg.User, false, session, true); err != nil {
+		t.Fatalf("ProcessFile failed: %v", err)
+	}
+	session.Close()
+
+	want := filepath.Join(library, "user", "noexif", "2024-05", "20240502_"+hash[:8]+".jpg")
+	if _, err := os.Stat(want); err != nil {
+		t.Fatalf("Expected renamed file at %s: %v", want, err)
+	}
+
+	events, err := ReadManifest(filepath.Join(session.SessionDir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	for _, event := range events {
+		if event.Event == "copied" && event.OriginalName != "IMG_0001.JPG" {
+			t.Errorf("Expected original_name IMG_0001.JPG, got %q", event.OriginalName)
+		}
+	}
+}
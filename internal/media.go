@@ -4,39 +4,87 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 )
 
-// ScanMediaFiles scans input directory recursively for media files based on extensions
-func ScanMediaFiles(inputDir string, cfg *Config) ([]string, error) {
-	var files []string
+// ScanResult is what ScanMediaFiles found in an import folder. An ImportRun carries
+// it to ProcessFile.
+type ScanResult struct {
+	Files    []string               // Media files to import, each group's lead before its members
+	InputDir string                 // Import folder that user_rule paths are relative to
+	Excluded []ExcludedFile         // Media files and folders left out by the scan rules
+	Sidecars map[string][]string    // Sidecars keyed by primary media path
+	Groups   map[string]*AssetGroup // RAW+JPEG and Live Photo groups keyed by member path
+	Formats  *FormatCache           // Formats detected by the scan, reused on import
+}
+
+// ScanMediaFiles scans input directory recursively for media files, recognised by
+// extension or, when the extension is unknown, by content (see mediaType).
+// inputDir may also be an archive, and archives found in it are scanned as well;
+// their media files are returned as "<archive>!/<entry>" paths.
+// Files left out by the cfg.Scan rules are recorded in Excluded; excluded folders
+// are recorded once and never read, nor are archives in them opened.
+// Sidecar files found on the way are associated with their primary by stem and stored
+// in Sidecars, and RAW+JPEG and Live Photo pairs in Groups, for ProcessFile.
+func ScanMediaFiles(inputDir string, cfg *Config) (*ScanResult, error) {
+	var candidates, sidecars []string
+	scan := &ScanResult{InputDir: inputDir, Formats: NewFormatCache()}
 	err := filepath.Walk(inputDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 		if info.IsDir() {
+			if excluded, ok := cfg.Scan.excludedFolder(inputDir, path); ok {
+				scan.Excluded = append(scan.Excluded, excluded)
+				return filepath.SkipDir
+			}
 			return nil
 		}
-
-		ext := strings.ToLower(filepath.Ext(info.Name()))
-		for _, e := range cfg.ImageExt {
-			if ext == e {
-				files = append(files, path)
-				return nil
-			}
+		if isSidecar(path, cfg) {
+			sidecars = append(sidecars, path)
+			return nil
 		}
-		for _, e := range cfg.VideoExt {
-			if ext == e {
-				files = append(files, path)
-				return nil
+		if IsArchive(path) {
+			entries, entrySidecars, err := scanArchive(path, cfg, scan.Formats)
+			if err != nil {
+				return err
 			}
+			candidates = append(candidates, entries...)
+			sidecars = append(sidecars, entrySidecars...)
+			return nil
+		}
+
+		if mediaType(path, cfg, scan.Formats) != TypeOther {
+			candidates = append(candidates, path)
 		}
 		return nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error scanning files: %w", err)
 	}
-	return files, nil
+
+	var files []string
+	for _, candidate := range candidates {
+		if reason := cfg.Scan.excludeReason(candidate, scanRelPath(inputDir, candidate), cfg); reason != "" {
+			scan.Excluded = append(scan.Excluded, ExcludedFile{Path: candidate, Reason: reason})
+			continue
+		}
+		files = append(files, candidate)
+	}
+	var keptSidecars []string
+	for _, sidecar := range sidecars {
+		if _, excluded := cfg.Scan.excludedByPattern(scanRelPath(inputDir, sidecar)); !excluded {
+			keptSidecars = append(keptSidecars, sidecar)
+		}
+	}
+
+	scan.Sidecars = associateSidecars(files, keptSidecars)
+	scan.Groups = groupAssets(files, cfg)
+
+	// Each group's lead comes first, so its members can follow it to its final name
+	for _, batch := range GroupBatches(files, scan.Groups) {
+		scan.Files = append(scan.Files, batch...)
+	}
+	return scan, nil
 }
 
 // ScanMediaFilesStream scans input directory and sends files to channel for streaming processing
@@ -48,21 +96,14 @@ func ScanMediaFilesStream(inputDir string, cfg *Config, fileChan chan<- string)
 			return err
 		}
 		if info.IsDir() {
+			if _, ok := cfg.Scan.excludedFolder(inputDir, path); ok {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
-		ext := strings.ToLower(filepath.Ext(info.Name()))
-		for _, e := range cfg.ImageExt {
-			if ext == e {
-				fileChan <- path
-				return nil
-			}
-		}
-		for _, e := range cfg.VideoExt {
-			if ext == e {
-				fileChan <- path
-				return nil
-			}
+		if determineFileType(path, cfg) != TypeOther {
+			fileChan <- path
 		}
 		return nil
 	})
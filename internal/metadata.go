@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// errXattrUnsupported is returned when a filesystem (or platform) has no extended attributes
+var errXattrUnsupported = errors.New("extended attributes not supported")
+
+// mtimeTolerance absorbs the coarse timestamps of FAT, exFAT and some NAS filesystems
+const mtimeTolerance = 2 * time.Second
+
+// copyMetadata gives dest, a fresh copy of src, src's modification and access times,
+// permission bits and user extended attributes. Archive entries only have a
+// modification time. Filesystems without extended attributes are tolerated.
+func copyMetadata(src, dest string) error {
+	if IsArchiveEntry(src) {
+		_, modTime, err := statSource(src)
+		if err != nil {
+			return err
+		}
+		if err := os.Chtimes(dest, modTime, modTime); err != nil {
+			return fmt.Errorf("failed to set times of %s: %w", dest, err)
+		}
+		return nil
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	// Attributes before the mode: setting them needs write access a read-only source's mode takes away
+	if err := copyUserXattrs(src, dest); err != nil && !errors.Is(err, errXattrUnsupported) {
+		return fmt.Errorf("failed to copy extended attributes to %s: %w", dest, err)
+	}
+	if err := os.Chmod(dest, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to set mode of %s: %w", dest, err)
+	}
+	// Times last: nothing may touch the file after them
+	if err := os.Chtimes(dest, fileAccessTime(info), info.ModTime()); err != nil {
+		return fmt.Errorf("failed to set times of %s: %w", dest, err)
+	}
+	return nil
+}
+
+// verifyMetadata checks dest still carries the metadata copyMetadata gave it from src
+func verifyMetadata(src, dest string) error {
+	_, srcModTime, err := statSource(src)
+	if err != nil {
+		return fmt.Errorf("failed to stat source %s: %w", src, err)
+	}
+	destInfo, err := os.Stat(dest)
+	if err != nil {
+		return fmt.Errorf("failed to stat destination %s: %w", dest, err)
+	}
+	if diff := destInfo.ModTime().Sub(srcModTime); diff > mtimeTolerance || diff < -mtimeTolerance {
+		return fmt.Errorf("metadata verification failed after copy %s -> %s: modification time %s, expected %s",
+			src, dest, destInfo.ModTime().Format(time.RFC3339), srcModTime.Format(time.RFC3339))
+	}
+	if IsArchiveEntry(src) {
+		return nil
+	}
+
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("failed to stat source %s: %w", src, err)
+	}
+	if srcInfo.Mode().Perm() != destInfo.Mode().Perm() {
+		return fmt.Errorf("metadata verification failed after copy %s -> %s: mode %v, expected %v",
+			src, dest, destInfo.Mode().Perm(), srcInfo.Mode().Perm())
+	}
+
+	want, err := userXattrs(src)
+	if err != nil {
+		return nil // Nothing to compare
+	}
+	got, err := userXattrs(dest)
+	if errors.Is(err, errXattrUnsupported) {
+		return nil // The library filesystem can't hold them
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read extended attributes of %s: %w", dest, err)
+	}
+	for name, value := range want {
+		if got[name] != value {
+			return fmt.Errorf("metadata verification failed after copy %s -> %s: extended attribute %s differs", src, dest, name)
+		}
+	}
+	return nil
+}
+
+// applyMtimeMode sets the modification time of a library file to its capture date when
+// cfg.Mtime asks for it, keeping its access time. Not used for hardlinks, which share
+// their times with the source.
+func applyMtimeMode(path string, cfg *Config, date captureDate) error {
+	if cfg.Mtime != MtimeCapture || date.Time.IsZero() {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chtimes(path, fileAccessTime(info), date.Time); err != nil {
+		return fmt.Errorf("failed to set modification time of %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+//go:build linux
+
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// fileAccessTime returns the last access time of a stat result
+func fileAccessTime(info os.FileInfo) time.Time {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(st.Atim.Unix())
+	}
+	return info.ModTime()
+}
+
+// xattrUnsupported reports whether err means the filesystem has no (user) xattrs.
+// EPERM is not among them: it is a real failure to report, not a reason to skip.
+func xattrUnsupported(err error) bool {
+	return errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EOPNOTSUPP)
+}
+
+// userXattrs reads the extended attributes of path in the user namespace; the
+// others need privileges and describe the filesystem rather than the file
+func userXattrs(path string) (map[string]string, error) {
+	size, err := syscall.Listxattr(path, nil)
+	if err != nil {
+		if xattrUnsupported(err) {
+			return nil, errXattrUnsupported
+		}
+		return nil, err
+	}
+	attrs := make(map[string]string)
+	if size == 0 {
+		return attrs, nil
+	}
+	list := make([]byte, size)
+	if size, err = syscall.Listxattr(path, list); err != nil {
+		return nil, err
+	}
+	for _, name := range bytes.Split(list[:size], []byte{0}) {
+		if !strings.HasPrefix(string(name), "user.") {
+			continue
+		}
+		n, err := syscall.Getxattr(path, string(name), nil)
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, n)
+		if n, err = syscall.Getxattr(path, string(name), value); err != nil {
+			return nil, err
+		}
+		attrs[string(name)] = string(value[:n])
+	}
+	return attrs, nil
+}
+
+// copyUserXattrs copies the user extended attributes of src to dest
+func copyUserXattrs(src, dest string) error {
+	attrs, err := userXattrs(src)
+	if err != nil {
+		return err
+	}
+	for name, value := range attrs {
+		if err := setUserXattr(dest, name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setUserXattr sets one extended attribute on path
+func setUserXattr(path, name, value string) error {
+	if err := syscall.Setxattr(path, name, []byte(value), 0); err != nil {
+		if xattrUnsupported(err) {
+			return errXattrUnsupported
+		}
+		return err
+	}
+	return nil
+}
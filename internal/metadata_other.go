@@ -0,0 +1,26 @@
+//go:build !linux
+
+package internal
+
+import (
+	"os"
+	"time"
+)
+
+// fileAccessTime falls back to the modification time where access times aren't read
+func fileAccessTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
+
+// Extended attributes are only copied on Linux
+func userXattrs(path string) (map[string]string, error) {
+	return nil, errXattrUnsupported
+}
+
+func copyUserXattrs(src, dest string) error {
+	return errXattrUnsupported
+}
+
+func setUserXattr(path, name, value string) error {
+	return errXattrUnsupported
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCopyFileAtomic_PreservesMetadata(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "photo.jpg")
+	dest := filepath.Join(tempDir, "copy.jpg")
+	os.WriteFile(src, []byte("photo content"), 0644)
+	os.Chmod(src, 0640)
+	modTime := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	os.Chtimes(src, modTime, modTime)
+
+	// Only checked where the temp filesystem keeps user xattrs
+	withXattrs := setUserXattr(src, "user.anduril.test", "kept") == nil
+
+	hash, err := copyFileAtomic(src, dest)
+	if err != nil {
+		t.Fatalf("copyFileAtomic failed: %v", err)
+	}
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(modTime) || info.Mode().Perm() != 0640 {
+		t.Errorf("Expected mtime %v and mode 0640, got %v and %v", modTime, info.ModTime(), info.Mode().Perm())
+	}
+	if withXattrs {
+		attrs, _ := userXattrs(dest)
+		if attrs["user.anduril.test"] != "kept" {
+			t.Errorf("Expected user xattr copied, got %v", attrs)
+		}
+	}
+	if err := verifyCopy(src, dest, hash, VerifyDest); err != nil {
+		t.Errorf("Expected preserved copy to verify: %v", err)
+	}
+
+	// A copy that lost its timestamp fails verification
+	os.Chtimes(dest, time.Now(), time.Now())
+	if err := verifyCopy(src, dest, hash, VerifyDest); err == nil {
+		t.Error("Expected verification to catch a changed modification time")
+	}
+
+	// A read-only source still gets its attributes copied
+	readOnly := filepath.Join(tempDir, "readonly.jpg")
+	os.WriteFile(readOnly, []byte("read-only photo"), 0644)
+	setUserXattr(readOnly, "user.anduril.test", "kept")
+	os.Chmod(readOnly, 0444)
+	readOnlyCopy := filepath.Join(tempDir, "readonly-copy.jpg")
+	if _, err := copyFileAtomic(readOnly, readOnlyCopy); err != nil {
+		t.Fatalf("copyFileAtomic of a read-only source failed: %v", err)
+	}
+	if info, err := os.Stat(readOnlyCopy); err != nil || info.Mode().Perm() != 0444 {
+		t.Errorf("Expected the read-only mode kept, got %v", info)
+	}
+	if withXattrs {
+		if attrs, _ := userXattrs(readOnlyCopy); attrs["user.anduril.test"] != "kept" {
+			t.Errorf("Expected user xattr copied to the read-only copy, got %v", attrs)
+		}
+	}
+}
+
+func TestProcessFile_CaptureMtime(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	inputDir := filepath.Join(tempDir, "input")
+	os.MkdirAll(inputDir, 0755)
+
+	date := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	src := filepath.Join(inputDir, "IMG_1.jpg")
+	writeCameraJPEG(t, src, date, "Apple", "iPhone 12")
+
+	cfg := &Config{
+		User:     "user",
+		Library:  library,
+		VideoLib: library,
+		ImageExt: []string{".jpg"},
+		VideoExt: []string{".mp4"},
+		Timezone: "UTC",
+		Verify:   VerifyDest,
+		Mtime:    MtimeCapture,
+	}
+	if err := ProcessFile(src, cfg, nil, cfg.User, false, nil, true); err != nil {
+		t.Fatalf("ProcessFile failed: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(library, "user", "2023", "05", "01", "IMG_1.jpg"))
+	if err != nil {
+		t.Fatalf("Expected file imported: %v", err)
+	}
+	if !info.ModTime().Equal(date) {
+		t.Errorf("Expected modification time set to the capture date %v, got %v", date, info.ModTime())
+	}
+}
@@ -0,0 +1,456 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Near-duplicate policies for NearDuplicateConfig.Policy, also recorded as the action
+// of near_duplicate manifest events
+const (
+	NearDuplicatesOff  = ""     // No perceptual hashing
+	NearDuplicatesFlag = "flag" // Import, and log which library image it resembles
+	NearDuplicatesSkip = "skip" // Skip when lower quality than the library image, flag otherwise
+)
+
+// DefaultNearDistance is the largest dHash distance (of 64 bits) counted as a near-duplicate
+const DefaultNearDistance = 8
+
+// NearDuplicateConfig controls perceptual near-duplicate detection for images: the
+// recompressed, resized or re-saved copies of a photo that SHA256 sees as different
+type NearDuplicateConfig struct {
+	Policy   string `mapstructure:"policy"`   // "", "flag" or "skip"
+	Distance int    `mapstructure:"distance"` // Largest Hamming distance between dHashes still near
+}
+
+// Validate checks the policy and distance
+func (n NearDuplicateConfig) Validate() error {
+	switch n.Policy {
+	case NearDuplicatesOff, NearDuplicatesFlag, NearDuplicatesSkip:
+	default:
+		return fmt.Errorf("invalid near_duplicates.policy %q (use flag or skip)", n.Policy)
+	}
+	if n.Distance < 0 || n.Distance > 64 {
+		return fmt.Errorf("invalid near_duplicates.distance %d (use 0 to 64)", n.Distance)
+	}
+	return nil
+}
+
+// dHash grid: each of the 8 rows compares 9 neighbouring cells, giving 64 bits
+const (
+	dhashWidth  = 9
+	dhashHeight = 8
+	dhashSample = 16 // Pixels sampled across each cell, per axis
+)
+
+// perceptualHash decodes an image (a file or archive entry) and returns its dHash.
+// Formats Go can't decode (HEIC, RAW) return an error.
+func perceptualHash(path string) (uint64, error) {
+	f, err := openSource(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return dHash(img), nil
+}
+
+// dHash shrinks img to a 9×8 grid of average brightness and sets one bit per
+// horizontal neighbour pair, 1 where brightness increases. Scaling, recompression
+// and small colour changes barely move it.
+func dHash(img image.Image) uint64 {
+	b := img.Bounds()
+	var cells [dhashHeight][dhashWidth]float64
+	for y := 0; y < dhashHeight; y++ {
+		y0 := b.Min.Y + y*b.Dy()/dhashHeight
+		y1 := b.Min.Y + (y+1)*b.Dy()/dhashHeight
+		for x := 0; x < dhashWidth; x++ {
+			x0 := b.Min.X + x*b.Dx()/dhashWidth
+			x1 := b.Min.X + (x+1)*b.Dx()/dhashWidth
+			cells[y][x] = averageLuma(img, x0, y0, x1, y1)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < dhashHeight; y++ {
+		for x := 0; x < dhashWidth-1; x++ {
+			hash <<= 1
+			if cells[y][x] < cells[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// averageLuma returns the mean brightness of a grid of points sampled in the rectangle
+func averageLuma(img image.Image, x0, y0, x1, y1 int) float64 {
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+	var sum float64
+	n := 0
+	for sy := 0; sy < dhashSample; sy++ {
+		y := y0 + sy*(y1-y0)/dhashSample
+		for sx := 0; sx < dhashSample; sx++ {
+			x := x0 + sx*(x1-x0)/dhashSample
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			n++
+		}
+	}
+	return sum / float64(n)
+}
+
+// hammingDistance counts the bits in which two dHashes differ
+func hammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// bkTree finds the dHashes within a distance of a hash without comparing it with all
+// of them. Each node keeps its children by their distance to it; by the triangle
+// inequality only children at a distance within maxDistance of the query's can hold
+// a match. The zero value is an empty tree.
+type bkTree struct {
+	root *bkNode
+}
+
+type bkNode struct {
+	hash     uint64
+	keys     map[string]bool // Paths with this hash; emptied nodes stay to route the search
+	children map[int]*bkNode // By distance to hash
+}
+
+func newBKNode(hash uint64) *bkNode {
+	return &bkNode{hash: hash, keys: make(map[string]bool), children: make(map[int]*bkNode)}
+}
+
+// add files key under hash
+func (t *bkTree) add(hash uint64, key string) {
+	if t.root == nil {
+		t.root = newBKNode(hash)
+	}
+	node := t.root
+	for {
+		d := hammingDistance(hash, node.hash)
+		if d == 0 {
+			node.keys[key] = true
+			return
+		}
+		child := node.children[d]
+		if child == nil {
+			child = newBKNode(hash)
+			node.children[d] = child
+		}
+		node = child
+	}
+}
+
+// remove drops key, filed under hash
+func (t *bkTree) remove(hash uint64, key string) {
+	for node := t.root; node != nil; {
+		d := hammingDistance(hash, node.hash)
+		if d == 0 {
+			delete(node.keys, key)
+			return
+		}
+		node = node.children[d]
+	}
+}
+
+// within calls fn for every key whose hash is at most maxDistance from hash
+func (t *bkTree) within(hash uint64, maxDistance int, fn func(key string, distance int)) {
+	if t.root == nil {
+		return
+	}
+	stack := []*bkNode{t.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		d := hammingDistance(hash, node.hash)
+		if d <= maxDistance {
+			for key := range node.keys {
+				fn(key, d)
+			}
+		}
+		for childDistance, child := range node.children {
+			if childDistance >= d-maxDistance && childDistance <= d+maxDistance {
+				stack = append(stack, child)
+			}
+		}
+	}
+}
+
+// perceptualSimilarity turns a dHash distance into a score from 0 to 1
+func perceptualSimilarity(distance int) float64 {
+	return 1 - float64(distance)/64
+}
+
+// PerceptualIndex maps library images to their dHash so imports can find near-duplicates
+// of photos already stored. It lives in <library>/.anduril/perceptual.jsonl as
+// append-only JSON lines; later lines for a path win, like the hash index.
+type PerceptualIndex struct {
+	path    string
+	file    *os.File          // nil when read-only
+	entries map[string]uint64 // dHash by library path
+	tree    bkTree            // The entries by dHash, for Nearest
+	mu      sync.Mutex
+}
+
+type perceptualIndexEntry struct {
+	Path  string `json:"path"`
+	DHash string `json:"dhash"` // 16 hex digits
+}
+
+// PerceptualIndexPath returns the perceptual index location for a library root
+func PerceptualIndexPath(libraryPath string) string {
+	return filepath.Join(libraryPath, ".anduril", "perceptual.jsonl")
+}
+
+// OpenPerceptualIndex loads the library's perceptual index. With readOnly set nothing
+// is created or written (used for dry runs).
+func OpenPerceptualIndex(libraryPath string, readOnly bool) (*PerceptualIndex, error) {
+	idx := &PerceptualIndex{
+		path:    PerceptualIndexPath(libraryPath),
+		entries: make(map[string]uint64),
+	}
+
+	if err := idx.load(); err != nil {
+		return nil, err
+	}
+	if readOnly {
+		return idx, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create index directory: %w", err)
+	}
+	f, err := os.OpenFile(idx.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open perceptual index: %w", err)
+	}
+	idx.file = f
+	return idx, nil
+}
+
+// load reads existing entries; a missing index is simply empty
+func (x *PerceptualIndex) load() error {
+	f, err := os.Open(x.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read perceptual index: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry perceptualIndexEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// Blank or torn final line after a crash; skip it
+			continue
+		}
+		hash, err := strconv.ParseUint(entry.DHash, 16, 64)
+		if err != nil {
+			continue
+		}
+		x.set(entry.Path, hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read perceptual index: %w", err)
+	}
+	return nil
+}
+
+// set records hash for path in memory; the caller holds x.mu or owns x
+func (x *PerceptualIndex) set(path string, hash uint64) {
+	if old, ok := x.entries[path]; ok {
+		x.tree.remove(old, path)
+	}
+	x.entries[path] = hash
+	x.tree.add(hash, path)
+}
+
+// Len returns the number of indexed images
+func (x *PerceptualIndex) Len() int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return len(x.entries)
+}
+
+// Nearest returns the indexed image closest to hash within maxDistance, and the
+// distance. Entries whose file disappeared are dropped.
+func (x *PerceptualIndex) Nearest(hash uint64, maxDistance int) (string, int, bool) {
+	type candidate struct {
+		path     string
+		distance int
+	}
+	var candidates []candidate
+
+	x.mu.Lock()
+	x.tree.within(hash, maxDistance, func(path string, d int) {
+		candidates = append(candidates, candidate{path, d})
+	})
+	x.mu.Unlock()
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].path < candidates[j].path
+	})
+	for _, c := range candidates {
+		if _, err := os.Stat(c.path); err == nil {
+			return c.path, c.distance, true
+		}
+		x.mu.Lock()
+		if h, ok := x.entries[c.path]; ok {
+			x.tree.remove(h, c.path)
+			delete(x.entries, c.path)
+		}
+		x.mu.Unlock()
+	}
+	return "", 0, false
+}
+
+// Add records the dHash of a library image
+func (x *PerceptualIndex) Add(path string, hash uint64) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if existing, ok := x.entries[absPath]; ok && existing == hash {
+		return nil
+	}
+	x.set(absPath, hash)
+
+	if x.file == nil {
+		return nil
+	}
+	return writePerceptualEntry(x.file, absPath, hash)
+}
+
+// Close closes the index file
+func (x *PerceptualIndex) Close() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.file != nil {
+		err := x.file.Close()
+		x.file = nil
+		return err
+	}
+	return nil
+}
+
+func writePerceptualEntry(f *os.File, path string, hash uint64) error {
+	data, err := json.Marshal(perceptualIndexEntry{Path: path, DHash: fmt.Sprintf("%016x", hash)})
+	if err != nil {
+		return fmt.Errorf("failed to marshal perceptual index entry: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write perceptual index: %w", err)
+	}
+	return nil
+}
+
+// RebuildPerceptualIndex hashes every decodable image in the libraries and atomically
+// replaces the perceptual index
+func RebuildPerceptualIndex(cfg *Config) (int, error) {
+	indexPath := PerceptualIndexPath(cfg.Library)
+	if err := os.MkdirAll(filepath.Dir(indexPath), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create index directory: %w", err)
+	}
+
+	tmp := indexPath + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create perceptual index: %w", err)
+	}
+
+	count := 0
+	err = walkLibraryMedia(cfg, func(path string, info os.FileInfo) error {
+		if determineFileType(path, cfg) != TypeImage {
+			return nil
+		}
+		hash, err := perceptualHash(path)
+		if err != nil {
+			return nil // Not decodable; never a near-duplicate candidate
+		}
+		if err := writePerceptualEntry(out, path, hash); err != nil {
+			return err
+		}
+		count++
+		if count%100 == 0 {
+			fmt.Printf("Hashed %d images\n", count)
+		}
+		return nil
+	})
+	if err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return count, err
+	}
+
+	if err := commitTempFile(out, tmp, indexPath); err != nil {
+		return count, err
+	}
+	return count, nil
+}
+
+// checkNearDuplicate looks src, an image about to be imported, up in the perceptual
+// index dhashes and applies the near-duplicate policy. Returns its dHash (ok is false when
+// it can't be decoded) and whether the import should skip it.
+func checkNearDuplicate(src, srcHash string, cfg *Config, dhashes *PerceptualIndex, session *ImportSession, dryRun, isSilent bool) (hash uint64, ok, skip bool) {
+	hash, err := perceptualHash(src)
+	if err != nil {
+		return 0, false, false
+	}
+	existing, distance, found := dhashes.Nearest(hash, cfg.NearDuplicates.Distance)
+	if !found {
+		return hash, true, false
+	}
+
+	similarity := perceptualSimilarity(distance)
+	action := NearDuplicatesFlag
+	if cfg.NearDuplicates.Policy == NearDuplicatesSkip && compareImageQuality(src, existing) == LOWER {
+		action = NearDuplicatesSkip
+	}
+
+	if !isSilent {
+		prefix := ""
+		if dryRun {
+			prefix = "[dry-run] "
+		}
+		if action == NearDuplicatesSkip {
+			fmt.Printf("%sSkipping near-duplicate (%.0f%% similar, lower quality): %s → %s\n", prefix, similarity*100, src, existing)
+		} else {
+			fmt.Printf("%sNear-duplicate (%.0f%% similar): %s ~ %s\n", prefix, similarity*100, src, existing)
+		}
+	}
+	if session != nil {
+		session.LogNearDuplicate(src, existing, srcHash, similarity, action)
+	}
+	return hash, true, action == NearDuplicatesSkip
+}
@@ -0,0 +1,192 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeTestImage encodes a w×h JPEG of one of two smooth patterns, the same picture
+// at any size
+func writeTestImage(t *testing.T, path string, w, h, quality, pattern int) {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			u, v := float64(x)/float64(w), float64(y)/float64(h)
+			var lum float64
+			if pattern == 0 {
+				lum = 128 + 100*math.Sin(7*u+4*v)*math.Cos(5*v)
+			} else {
+				lum = 128 + 100*math.Cos(11*u*v+3*u)*math.Sin(9*u)
+			}
+			img.SetGray(x, y, color.Gray{Y: uint8(lum)})
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPerceptualHash(t *testing.T) {
+	tempDir := t.TempDir()
+	original := filepath.Join(tempDir, "original.jpg")
+	resized := filepath.Join(tempDir, "resized.jpg")
+	other := filepath.Join(tempDir, "other.jpg")
+	writeTestImage(t, original, 640, 480, 95, 0)
+	writeTestImage(t, resized, 200, 150, 40, 0) // WhatsApp-style: smaller and recompressed
+	writeTestImage(t, other, 640, 480, 95, 1)
+
+	hashes := make(map[string]uint64)
+	for _, path := range []string{original, resized, other} {
+		hash, err := perceptualHash(path)
+		if err != nil {
+			t.Fatalf("perceptualHash(%s) failed: %v", path, err)
+		}
+		hashes[path] = hash
+	}
+	if d := hammingDistance(hashes[original], hashes[resized]); d > DefaultNearDistance {
+		t.Errorf("Expected the resized copy within %d bits, got %d", DefaultNearDistance, d)
+	}
+	if d := hammingDistance(hashes[original], hashes[other]); d <= DefaultNearDistance {
+		t.Errorf("Expected a different picture further than %d bits, got %d", DefaultNearDistance, d)
+	}
+
+	// Analytics groups the two copies, best first, and leaves the other picture out
+	sets := findNearDuplicateSets(map[string][]string{"a": {resized}, "b": {original}, "c": {other}}, DefaultNearDistance, &Config{ImageExt: []string{".jpg"}})
+	if len(sets) != 1 || len(sets[0].Files) != 2 || sets[0].Files[0] != original || !sets[0].Near {
+		t.Fatalf("Expected one near-duplicate set led by the original, got %+v", sets)
+	}
+	if sets[0].Similarity[0] != 1 || sets[0].Similarity[1] < perceptualSimilarity(DefaultNearDistance) {
+		t.Errorf("Expected similarity scores to the original, got %v", sets[0].Similarity)
+	}
+}
+
+func TestProcessFile_NearDuplicates(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	inputDir := filepath.Join(tempDir, "input")
+	os.MkdirAll(inputDir, 0755)
+
+	medium := filepath.Join(inputDir, "medium.jpg")
+	small := filepath.Join(inputDir, "small.jpg")
+	large := filepath.Join(inputDir, "large.jpg")
+	writeTestImage(t, medium, 400, 300, 90, 0)
+	writeTestImage(t, small, 200, 150, 60, 0)
+	writeTestImage(t, large, 800, 600, 90, 0)
+	date := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	for _, f := range []string{medium, small, large} {
+		os.Chtimes(f, date, date)
+	}
+
+	dhashes, err := OpenPerceptualIndex(library, false)
+	if err != nil {
+		t.Fatalf("OpenPerceptualIndex failed: %v", err)
+	}
+	defer dhashes.Close()
+	cfg := &Config{
+		User:           "user",
+		Library:        library,
+		VideoLib:       library,
+		ImageExt:       []string{".jpg"},
+		VideoExt:       []string{".mp4"},
+		Timezone:       "UTC",
+		Verify:         VerifyDest,
+		NearDuplicates: NearDuplicateConfig{Policy: NearDuplicatesSkip, Distance: DefaultNearDistance},
+	}
+	run := &ImportRun{DHashes: dhashes}
+	session, err := NewImportSession(library, library, cfg.User, inputDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	for _, f := range []string{medium, small, large} {
+		if err := ProcessFile(f, cfg, run, cfg.User, false, session, true); err != nil {
+			t.Fatalf("ProcessFile(%s) failed: %v", f, err)
+		}
+	}
+	session.Close()
+
+	dayDir := filepath.Join(library, "user", "noexif", "2023-05")
+	if _, err := os.Stat(filepath.Join(dayDir, "small.jpg")); !os.IsNotExist(err) {
+		t.Errorf("Expected the lower-resolution copy skipped, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dayDir, "large.jpg")); err != nil {
+		t.Errorf("Expected the higher-resolution copy imported: %v", err)
+	}
+
+	events, err := ReadManifest(filepath.Join(session.SessionDir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	actions := make(map[string]string)
+	for _, e := range events {
+		if e.Event == "near_duplicate" {
+			actions[filepath.Base(e.Src)] = e.Action
+			if e.Existing == "" || e.Similarity <= 0 {
+				t.Errorf("Expected the matched library image and a similarity, got %+v", e)
+			}
+		}
+	}
+	if actions["small.jpg"] != NearDuplicatesSkip || actions["large.jpg"] != NearDuplicatesFlag {
+		t.Errorf("Expected small skipped and large flagged, got %v", actions)
+	}
+	if stats := session.GetStats(); stats.NearDuplicates != 2 || stats.SkippedNear != 1 {
+		t.Errorf("Expected 2 near-duplicates with 1 skipped, got %+v", stats)
+	}
+
+	// Imported images are indexed for later imports
+	reopened, err := OpenPerceptualIndex(library, true)
+	if err != nil {
+		t.Fatalf("OpenPerceptualIndex failed: %v", err)
+	}
+	if reopened.Len() != 2 {
+		t.Errorf("Expected 2 indexed images, got %d", reopened.Len())
+	}
+}
+
+func TestBKTree_Within(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	hashes := make(map[string]uint64)
+	var tree bkTree
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprintf("img%d", i)
+		hash := rng.Uint64()
+		if i%5 == 0 {
+			// Some close to an earlier one, as near-duplicates are
+			hash = hashes[fmt.Sprintf("img%d", i/5)] ^ (1 << uint(rng.Intn(64)))
+		}
+		hashes[key] = hash
+		tree.add(hash, key)
+	}
+	tree.remove(hashes["img7"], "img7")
+	delete(hashes, "img7")
+
+	for _, maxDistance := range []int{0, 4, 8, 20} {
+		for _, query := range []string{"img1", "img2", "img100"} {
+			want := make(map[string]int)
+			for key, hash := range hashes {
+				if d := hammingDistance(hashes[query], hash); d <= maxDistance {
+					want[key] = d
+				}
+			}
+			got := make(map[string]int)
+			tree.within(hashes[query], maxDistance, func(key string, d int) { got[key] = d })
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("within(%s, %d) = %v, want %v", query, maxDistance, got, want)
+			}
+		}
+	}
+}
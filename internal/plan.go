@@ -0,0 +1,573 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An import plan is an import worked out without touching the library: for each
+// source its hash, date, destination and what the import would do with it. It is
+// written by `import --plan` as indented JSON for review, and `apply` executes
+// exactly those decisions, refusing sources that changed since planning.
+
+// Plan actions
+const (
+	PlanCopy            = "copy"             // Copy (or link, clone, move) to dest
+	PlanTimestampSuffix = "timestamp_suffix" // Dest holds other content; keep both under a suffixed name
+	PlanReplace         = "replace"          // Dest holds other content the conflict policy replaces; it moves to superseded/
+	PlanSkipConflict    = "skip_conflict"    // Conflict holds other content the conflict policy keeps
+	PlanSkipDuplicate   = "skip_duplicate"   // Content already in the library or earlier in the plan
+	PlanError           = "error"            // Could not be planned; apply leaves it alone
+)
+
+// planVersion is bumped when the plan format changes incompatibly
+const planVersion = 1
+
+// ImportPlan is a reviewed-before-run import
+type ImportPlan struct {
+	Version          int         `json:"version"`
+	Created          string      `json:"created"`
+	User             string      `json:"user"`
+	InputDir         string      `json:"input_dir"` // Absolute path of the folder or archive imported
+	LibraryPath      string      `json:"library_path"`
+	VideoLibraryPath string      `json:"video_library_path"`
+	Options          PlanOptions `json:"options"`
+	Entries          []PlanEntry `json:"entries"`
+	Groups           []PlanGroup `json:"groups,omitempty"` // RAW+JPEG and Live Photo groups among the entries
+}
+
+// PlanOptions are the transfer settings the plan was made with and is applied with
+type PlanOptions struct {
+	Link       bool   `json:"link,omitempty"`
+	Move       bool   `json:"move,omitempty"`
+	Reflink    string `json:"reflink,omitempty"`
+	Verify     string `json:"verify"`
+	Mtime      string `json:"mtime,omitempty"`
+	WriteDates string `json:"write_dates,omitempty"`
+	OnConflict string `json:"on_conflict,omitempty"`
+	HashIndex  bool   `json:"hash_index"`
+}
+
+// PlanEntry is the decision for one source file
+type PlanEntry struct {
+	Src          string   `json:"src"`
+	Size         int64    `json:"size"`
+	ModTime      string   `json:"mtime"` // RFC 3339, UTC
+	Hash         string   `json:"hash,omitempty"`
+	Type         string   `json:"type,omitempty"` // image or video
+	Date         string   `json:"date,omitempty"` // Capture date used for the destination, library timezone
+	Confidence   string   `json:"confidence,omitempty"`
+	DateSource   string   `json:"date_source,omitempty"`
+	OriginalDate string   `json:"original_date,omitempty"` // EXIF date before a clock offset correction
+	ClockOffset  string   `json:"clock_offset,omitempty"`
+	User         string   `json:"user,omitempty"`
+	UserRule     string   `json:"user_rule,omitempty"`
+	Format       string   `json:"format,omitempty"` // Content format when the extension disagrees
+	Action       string   `json:"action"`
+	Dest         string   `json:"dest,omitempty"`
+	Conflict     string   `json:"conflict,omitempty"`      // File with other content at the destination
+	ExistingHash string   `json:"existing_hash,omitempty"` // Content of Conflict when the policy replaces or keeps it
+	Policy       string   `json:"policy,omitempty"`        // Conflict policy that decided a replace or skip_conflict
+	Quality      string   `json:"quality,omitempty"`       // keep-best: how the source compared with Conflict
+	Existing     string   `json:"existing,omitempty"`      // Library file a skipped duplicate matches
+	DuplicateOf  string   `json:"duplicate_of,omitempty"`  // Earlier source in the plan with the same content
+	Sidecars     []string `json:"sidecars,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// PlanGroup records an asset group so apply logs it like an import would
+type PlanGroup struct {
+	ID      string   `json:"id"`
+	Kind    string   `json:"kind"`
+	Lead    string   `json:"lead"`
+	Members []string `json:"members"`
+}
+
+// confidenceNames spell DateConfidence levels in plans
+var confidenceNames = map[DateConfidence]string{
+	HIGH: "high", MEDIUM: "medium", LOW: "low", VERY_LOW: "very_low",
+}
+
+func parseConfidence(name string) (DateConfidence, error) {
+	for level, n := range confidenceNames {
+		if n == name {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown date confidence %q", name)
+}
+
+// BuildImportPlan works out what importing the files of run (as found by ScanMediaFiles)
+// would do, reading sources and the library but writing nothing. Hashes and dates are
+// computed on cfg.Jobs workers; decisions are then made in scan order, so files that
+// collide within the plan get the same outcome a sequential import would give them.
+func BuildImportPlan(cfg *Config, run *ImportRun, user string) (*ImportPlan, error) {
+	files := run.Files
+	inputAbs, err := filepath.Abs(run.InputDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve input path: %w", err)
+	}
+	libraryAbs, err := filepath.Abs(cfg.Library)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve library path: %w", err)
+	}
+	videoAbs, err := filepath.Abs(cfg.VideoLib)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve video library path: %w", err)
+	}
+
+	plan := &ImportPlan{
+		Version:          planVersion,
+		Created:          time.Now().UTC().Format(time.RFC3339),
+		User:             user,
+		InputDir:         inputAbs,
+		LibraryPath:      libraryAbs,
+		VideoLibraryPath: videoAbs,
+		Options: PlanOptions{
+			Link:       cfg.UseHardlinks,
+			Move:       cfg.MoveFiles,
+			Reflink:    cfg.Reflink,
+			Verify:     cfg.Verify,
+			Mtime:      cfg.Mtime,
+			WriteDates: cfg.WriteDates,
+			OnConflict: cfg.OnConflict,
+			HashIndex:  cfg.UseHashIndex,
+		},
+		Entries: make([]PlanEntry, len(files)),
+	}
+
+	jobs := cfg.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				plan.Entries[i] = planFacts(files[i], cfg, run, user)
+			}
+		}()
+	}
+	for i := range files {
+		work <- i
+		if (i+1)%100 == 0 {
+			fmt.Printf("Planning: %d/%d files\n", i+1, len(files))
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	p := planner{cfg: cfg, run: run, byHash: make(map[string]int), claimed: make(map[string]bool), leadDests: make(map[*AssetGroup]string)}
+	for i := range plan.Entries {
+		p.decide(plan.Entries, i)
+	}
+
+	seen := make(map[*AssetGroup]bool)
+	for _, f := range files {
+		if group := run.Groups[f]; group != nil && !seen[group] {
+			seen[group] = true
+			plan.Groups = append(plan.Groups, PlanGroup{ID: group.ID, Kind: group.Kind, Lead: group.Lead, Members: group.Members})
+		}
+	}
+	return plan, nil
+}
+
+// planFacts gathers everything about src that doesn't depend on other files
+func planFacts(src string, cfg *Config, run *ImportRun, user string) PlanEntry {
+	entry := PlanEntry{Src: src}
+	fail := func(err error) PlanEntry {
+		entry.Action = PlanError
+		entry.Error = err.Error()
+		return entry
+	}
+
+	media := identifyMedia(src, cfg, run.Formats)
+	if media.Type == TypeOther {
+		return fail(fmt.Errorf("not a media file"))
+	}
+	entry.Type = "image"
+	if media.Type == TypeVideo {
+		entry.Type = "video"
+	}
+	if media.FixedExt != "" {
+		entry.Format = media.Format.Name
+	}
+
+	size, modTime, err := statSource(src)
+	if err != nil {
+		return fail(fmt.Errorf("failed to stat source: %w", err))
+	}
+	entry.Size, entry.ModTime = size, modTime.UTC().Format(time.RFC3339Nano)
+
+	entry.Hash, err = fileHash(src)
+	if err != nil {
+		return fail(fmt.Errorf("failed to hash source: %w", err))
+	}
+
+	date, err := assetDate(src, cfg, run)
+	if err != nil {
+		return fail(fmt.Errorf("failed to get file date: %w", err))
+	}
+	entry.Date = date.Time.Format(time.RFC3339)
+	entry.Confidence = confidenceNames[date.Confidence]
+	entry.DateSource = date.Source
+	if date.Offset != nil {
+		entry.OriginalDate = date.Original.Format(time.RFC3339)
+		entry.ClockOffset = date.Offset.String()
+	}
+
+	owner := assetUser(src, cfg, run, user)
+	entry.User, entry.UserRule = owner.User, owner.Rule
+
+	entry.Dest, err = assetDestinationPath(src, date.Time, date.Confidence, media.Type, cfg, run, owner.User, entry.Hash, nil)
+	if err != nil {
+		return fail(err)
+	}
+	entry.Sidecars = run.Sidecars[src]
+	return entry
+}
+
+// planner decides plan actions in order, tracking what earlier entries claim
+type planner struct {
+	cfg       *Config
+	run       *ImportRun
+	byHash    map[string]int         // Entry index placing each content hash
+	claimed   map[string]bool        // Destinations taken by earlier entries
+	leadDests map[*AssetGroup]string // Where each group's lead ends up, for its members
+}
+
+// decide sets the action for entries[i], the way ProcessFile would at that point of a
+// sequential import. Entries colliding with one placed earlier in the plan are always
+// kept side by side with a timestamp suffix; the conflict policy only decides against
+// files already in the library.
+func (p *planner) decide(entries []PlanEntry, i int) {
+	e := &entries[i]
+	if e.Action == PlanError {
+		return
+	}
+
+	// Members follow their lead, decided earlier, to its final name
+	if group := p.run.Groups[e.Src]; group != nil {
+		if group.Lead == e.Src {
+			defer func() {
+				p.leadDests[group] = e.Dest
+				if e.Action == PlanSkipDuplicate {
+					p.leadDests[group] = e.Existing
+				}
+			}()
+		} else if leadDest := p.leadDests[group]; leadDest != "" {
+			e.Dest = memberDestination(e.Src, leadDest, p.cfg)
+		}
+	}
+
+	if p.run.Index != nil {
+		if existing, ok := p.run.Index.Lookup(e.Hash); ok {
+			e.Action, e.Existing, e.Dest = PlanSkipDuplicate, existing, ""
+			return
+		}
+	}
+	if first, ok := p.byHash[e.Hash]; ok {
+		e.Action, e.Existing, e.DuplicateOf, e.Dest = PlanSkipDuplicate, entries[first].Dest, entries[first].Src, ""
+		return
+	}
+
+	// Sources colliding within the plan are kept side by side whatever the policy, as
+	// an import never replaces a file it placed itself
+	e.Action = PlanCopy
+	if p.claimed[e.Dest] {
+		e.Action, e.Conflict = PlanTimestampSuffix, e.Dest
+	} else if _, err := os.Stat(e.Dest); err == nil {
+		fileType := TypeImage
+		if e.Type == "video" {
+			fileType = TypeVideo
+		}
+		finalPath, skip, existing, conflict, err := handleDuplicateFile(e.Src, e.Hash, e.Dest, fileType, p.cfg.OnConflict, p.cfg, true)
+		if conflict != nil && conflict.Resolution != ResolutionSuffixed {
+			e.Conflict, e.ExistingHash, e.Policy, e.Quality = e.Dest, conflict.ExistingHash, conflict.Policy, conflict.Quality
+		}
+		switch {
+		case err != nil:
+			e.Action, e.Error = PlanError, err.Error()
+			return
+		case skip && conflict != nil:
+			e.Action, e.Dest = PlanSkipConflict, ""
+			return
+		case skip:
+			e.Action, e.Existing, e.Dest = PlanSkipDuplicate, existing, ""
+			return
+		case conflict != nil && conflict.Resolution == ResolutionReplaced:
+			e.Action = PlanReplace
+		case finalPath != "":
+			e.Action, e.Conflict = PlanTimestampSuffix, e.Dest
+		}
+	}
+	if e.Action == PlanTimestampSuffix {
+		e.Dest = p.suffixedPath(e.Conflict)
+	}
+
+	p.claimed[e.Dest] = true
+	p.byHash[e.Hash] = i
+}
+
+// suffixedPath returns a timestamp-suffixed name for dest that is free both on disk
+// and in the plan
+func (p *planner) suffixedPath(dest string) string {
+	target := timestampSuffixCopyPath(dest)
+	ext := filepath.Ext(target)
+	base := strings.TrimSuffix(target, ext)
+	for n := 2; p.claimed[target]; n++ {
+		candidate := fmt.Sprintf("%s_%d%s", base, n, ext)
+		if _, err := os.Stat(candidate); err == nil {
+			continue
+		}
+		target = candidate
+	}
+	return target
+}
+
+// Counts returns how many entries have each action
+func (p *ImportPlan) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, e := range p.Entries {
+		counts[e.Action]++
+	}
+	return counts
+}
+
+// WriteImportPlan saves the plan as indented JSON, atomically
+func WriteImportPlan(plan *ImportPlan, path string) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode plan: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	return nil
+}
+
+// ReadImportPlan loads a plan written by WriteImportPlan
+func ReadImportPlan(path string) (*ImportPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+	var plan ImportPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan %s: %w", path, err)
+	}
+	if plan.Version != planVersion {
+		return nil, fmt.Errorf("plan %s has version %d, expected %d", path, plan.Version, planVersion)
+	}
+	if !filepath.IsAbs(plan.LibraryPath) || !filepath.IsAbs(plan.VideoLibraryPath) {
+		return nil, fmt.Errorf("plan %s has no absolute library paths", path)
+	}
+	for i, e := range plan.Entries {
+		if err := e.checkPaths(plan.LibraryPath, plan.VideoLibraryPath); err != nil {
+			return nil, fmt.Errorf("plan entry %d: %w", i+1, err)
+		}
+		switch e.Action {
+		case PlanCopy, PlanTimestampSuffix:
+			if e.Dest == "" {
+				return nil, fmt.Errorf("plan entry %d (%s) has no dest", i+1, e.Src)
+			}
+		case PlanReplace, PlanSkipConflict:
+			if e.Conflict == "" || e.ExistingHash == "" {
+				return nil, fmt.Errorf("plan entry %d (%s) has no conflicting file", i+1, e.Src)
+			}
+		case PlanSkipDuplicate:
+			if e.Existing == "" {
+				return nil, fmt.Errorf("plan entry %d (%s) has no existing file", i+1, e.Src)
+			}
+		case PlanError:
+		default:
+			return nil, fmt.Errorf("plan entry %d (%s) has unknown action %q", i+1, e.Src, e.Action)
+		}
+	}
+	return &plan, nil
+}
+
+// Run returns what executes the plan: a copy of cfg with the plan's libraries and
+// transfer options, and the run with its entries, sidecars and groups in place of a
+// scan and the sources to pass to ProcessFile in plan order. cfg is left as loaded.
+func (p *ImportPlan) Run(loaded *Config) (*Config, *ImportRun) {
+	c := *loaded
+	cfg := &c
+	cfg.Library = p.LibraryPath
+	cfg.VideoLib = p.VideoLibraryPath
+	cfg.UseHardlinks = p.Options.Link
+	cfg.MoveFiles = p.Options.Move
+	cfg.Reflink = p.Options.Reflink
+	if cfg.Reflink == "" {
+		// Plans written before "never" was spelled out
+		cfg.Reflink = ReflinkNever
+	}
+	cfg.Verify = p.Options.Verify
+	if p.Options.Mtime != "" {
+		cfg.Mtime = p.Options.Mtime
+	}
+	cfg.WriteDates = p.Options.WriteDates
+	cfg.OnConflict = p.Options.OnConflict
+	cfg.UseHashIndex = p.Options.HashIndex
+
+	run := NewImportRun(&ScanResult{
+		InputDir: p.InputDir,
+		Sidecars: make(map[string][]string),
+		Groups:   make(map[string]*AssetGroup),
+		Formats:  NewFormatCache(),
+	})
+	run.Plan = make(map[string]*PlanEntry)
+	for i := range p.Entries {
+		e := &p.Entries[i]
+		if e.Action == PlanError {
+			continue
+		}
+		run.Plan[e.Src] = e
+		if len(e.Sidecars) > 0 {
+			run.Sidecars[e.Src] = e.Sidecars
+		}
+		run.Files = append(run.Files, e.Src)
+	}
+	for _, g := range p.Groups {
+		group := &AssetGroup{ID: g.ID, Kind: g.Kind, Lead: g.Lead, Members: g.Members}
+		for _, m := range g.Members {
+			run.Groups[m] = group
+		}
+	}
+	return cfg, run
+}
+
+// captureDate rebuilds the date recorded at planning time
+func (e *PlanEntry) captureDate() (captureDate, error) {
+	t, err := time.Parse(time.RFC3339, e.Date)
+	if err != nil {
+		return captureDate{}, fmt.Errorf("plan entry for %s has an invalid date: %w", e.Src, err)
+	}
+	confidence, err := parseConfidence(e.Confidence)
+	if err != nil {
+		return captureDate{}, fmt.Errorf("plan entry for %s: %w", e.Src, err)
+	}
+	date := captureDate{Time: t, Confidence: confidence, Source: e.DateSource}
+	if e.ClockOffset != "" {
+		rule, err := ParseClockOffsetFlag(e.ClockOffset)
+		if err != nil {
+			return captureDate{}, fmt.Errorf("plan entry for %s: %w", e.Src, err)
+		}
+		original, err := time.Parse(time.RFC3339, e.OriginalDate)
+		if err != nil {
+			return captureDate{}, fmt.Errorf("plan entry for %s has an invalid original date: %w", e.Src, err)
+		}
+		date.Original, date.Offset = original, &rule
+	}
+	return date, nil
+}
+
+// checkPaths refuses an entry whose library paths lie outside both libraries, so an
+// edited plan can't write, replace or point duplicates anywhere else
+func (e *PlanEntry) checkPaths(library, videoLibrary string) error {
+	for _, path := range []string{e.Dest, e.Conflict, e.Existing} {
+		if path != "" && !insideRoot(library, path) && !insideRoot(videoLibrary, path) {
+			return fmt.Errorf("%s (%s) is outside the library", path, e.Src)
+		}
+	}
+	return nil
+}
+
+// checkSource refuses a source whose size, modification time or hash differ from
+// planning. The hash is checked up front whatever the transfer: copies reuse it
+// instead of hashing while copying, and links, clones and moves don't read the data.
+func (e *PlanEntry) checkSource() error {
+	size, modTime, err := statSource(e.Src)
+	if err != nil {
+		return fmt.Errorf("planned source %s: %w", e.Src, err)
+	}
+	if size != e.Size || modTime.UTC().Format(time.RFC3339Nano) != e.ModTime {
+		return fmt.Errorf("source %s changed since planning (size or modification time)", e.Src)
+	}
+	hash, err := fileHash(e.Src)
+	if err != nil {
+		return fmt.Errorf("failed to hash source %s: %w", e.Src, err)
+	}
+	if hash != e.Hash {
+		return fmt.Errorf("source %s changed since planning (hash differs)", e.Src)
+	}
+	return nil
+}
+
+// applyPlannedFile carries out the plan entry for src and returns where its content
+// ended up in the library, and its hash
+func applyPlannedFile(src string, planned *PlanEntry, fileType FileType, cfg *Config, run *ImportRun, session *ImportSession, isSilent bool) (string, string, error) {
+	if err := planned.checkPaths(cfg.Library, cfg.VideoLib); err != nil {
+		return "", "", err
+	}
+	if err := planned.checkSource(); err != nil {
+		return "", "", err
+	}
+	date, err := planned.captureDate()
+	if err != nil {
+		return "", "", err
+	}
+	details := importDetails{
+		Date:   date,
+		Owner:  userAttribution{User: planned.User, Rule: planned.UserRule},
+		Format: planned.Format,
+	}
+	if group := run.Groups[src]; group != nil {
+		details.Group = group.ID
+	}
+
+	switch planned.Action {
+	case PlanSkipDuplicate:
+		// A duplicate of an earlier entry is placed by that entry, which runs before it
+		// (see Batches); a library file must still be there
+		if planned.DuplicateOf != "" {
+			if _, ok := run.planPlaced.Load(planned.DuplicateOf); !ok {
+				return "", "", fmt.Errorf("planned duplicate of %s, which was not imported", planned.DuplicateOf)
+			}
+		} else if _, err := os.Stat(planned.Existing); err != nil {
+			return "", "", fmt.Errorf("planned duplicate %s is no longer in the library", planned.Existing)
+		}
+		if !isSilent {
+			fmt.Printf("Skipping duplicate file (as planned): %s → %s\n", src, planned.Existing)
+		}
+		if session != nil {
+			session.LogSkippedDuplicate(src, planned.Existing, planned.Hash)
+		}
+		queueSourceRemoval(cfg, session, src, planned.Existing, planned.Hash)
+		return planned.Existing, planned.Hash, nil
+
+	case PlanSkipConflict:
+		if !isSilent {
+			fmt.Printf("Keeping existing file with different content (as planned): %s → %s\n", src, planned.Conflict)
+		}
+		if session != nil {
+			session.LogConflict(src, planned.Hash, &fileConflict{Dest: planned.Conflict, ExistingHash: planned.ExistingHash, Policy: planned.Policy, Resolution: ResolutionSkipped, Quality: planned.Quality})
+		}
+		return "", "", nil
+
+	case PlanCopy, PlanTimestampSuffix, PlanReplace:
+		origDestPath := planned.Dest
+		if planned.Conflict != "" {
+			origDestPath = planned.Conflict
+		}
+		dest, hash, err := placeFile(src, planned.Dest, origDestPath, planned.Hash, fileType, cfg, run, session, details, planned, isSilent)
+		if err == nil && dest != "" {
+			run.planPlaced.Store(src, true)
+		}
+		return dest, hash, err
+	}
+	return "", "", fmt.Errorf("plan entry for %s has no action to apply (%s)", src, planned.Action)
+}
@@ -0,0 +1,272 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportPlan(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	inputDir := filepath.Join(tempDir, "input")
+	date := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	dayDir := filepath.Join(library, "family", "2023", "05", "01")
+	os.MkdirAll(inputDir, 0755)
+	os.MkdirAll(dayDir, 0755)
+	writeCameraJPEG(t, filepath.Join(inputDir, "a.jpg"), date, "Apple", "iPhone 12")
+	writeCameraJPEG(t, filepath.Join(inputDir, "b.jpg"), date, "Apple", "iPhone 12") // Same content as a.jpg
+	writeCameraJPEG(t, filepath.Join(inputDir, "c.jpg"), date, "Apple", "iPhone 13")
+	writeCameraJPEG(t, filepath.Join(inputDir, "d.jpg"), date, "Apple", "iPhone 14")
+	writeCameraJPEG(t, filepath.Join(dayDir, "c.jpg"), date, "Nikon", "D750")      // Other content at c's destination
+	writeCameraJPEG(t, filepath.Join(dayDir, "d.jpg"), date, "Apple", "iPhone 14") // Already imported
+
+	cfg := &Config{
+		User:     "family",
+		Library:  library,
+		VideoLib: library,
+		ImageExt: []string{".jpg"},
+		VideoExt: []string{".mp4"},
+		Timezone: "UTC",
+		Verify:   VerifyDest,
+		Jobs:     2,
+	}
+	scan, err := ScanMediaFiles(inputDir, cfg)
+	if err != nil {
+		t.Fatalf("ScanMediaFiles failed: %v", err)
+	}
+	plan, err := BuildImportPlan(cfg, NewImportRun(scan), cfg.User)
+	if err != nil {
+		t.Fatalf("BuildImportPlan failed: %v", err)
+	}
+
+	want := map[string]string{
+		"a.jpg": PlanCopy,
+		"b.jpg": PlanSkipDuplicate,
+		"c.jpg": PlanTimestampSuffix,
+		"d.jpg": PlanSkipDuplicate,
+	}
+	for _, e := range plan.Entries {
+		name := filepath.Base(e.Src)
+		if e.Action != want[name] {
+			t.Errorf("Expected %s planned as %s, got %+v", name, want[name], e)
+		}
+		if e.Hash == "" || e.Confidence != "high" || e.Date == "" {
+			t.Errorf("Expected hash, date and confidence for %s, got %+v", name, e)
+		}
+	}
+	if entries, _ := os.ReadDir(dayDir); len(entries) != 2 {
+		t.Fatalf("Expected planning to leave the library untouched, found %d files", len(entries))
+	}
+
+	planPath := filepath.Join(tempDir, "plan.json")
+	if err := WriteImportPlan(plan, planPath); err != nil {
+		t.Fatalf("WriteImportPlan failed: %v", err)
+	}
+	plan, err = ReadImportPlan(planPath)
+	if err != nil {
+		t.Fatalf("ReadImportPlan failed: %v", err)
+	}
+
+	applyCfg, run := plan.Run(&Config{ImageExt: cfg.ImageExt, VideoExt: cfg.VideoExt})
+	session, err := NewImportSession(applyCfg.Library, applyCfg.VideoLib, plan.User, plan.InputDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	for _, f := range run.Files {
+		if err := ProcessFile(f, applyCfg, run, plan.User, false, session, true); err != nil {
+			t.Fatalf("ProcessFile(%s) failed: %v", f, err)
+		}
+	}
+	session.Close()
+
+	if _, err := os.Stat(filepath.Join(dayDir, "a.jpg")); err != nil {
+		t.Errorf("Expected a.jpg imported: %v", err)
+	}
+	for _, e := range plan.Entries {
+		if e.Action == PlanTimestampSuffix {
+			if _, err := os.Stat(e.Dest); err != nil {
+				t.Errorf("Expected c.jpg imported under its planned suffixed name: %v", err)
+			}
+		}
+	}
+	if entries, _ := os.ReadDir(dayDir); len(entries) != 4 {
+		t.Errorf("Expected 4 files after applying the plan, found %d", len(entries))
+	}
+}
+
+func TestApplyPlan_SourceChanged(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	inputDir := filepath.Join(tempDir, "input")
+	os.MkdirAll(inputDir, 0755)
+
+	date := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	src := filepath.Join(inputDir, "a.jpg")
+	writeCameraJPEG(t, src, date, "Apple", "iPhone 12")
+
+	cfg := &Config{
+		User:     "family",
+		Library:  library,
+		VideoLib: library,
+		ImageExt: []string{".jpg"},
+		VideoExt: []string{".mp4"},
+		Timezone: "UTC",
+		Verify:   VerifyDest,
+	}
+	scan, err := ScanMediaFiles(inputDir, cfg)
+	if err != nil {
+		t.Fatalf("ScanMediaFiles failed: %v", err)
+	}
+	plan, err := BuildImportPlan(cfg, NewImportRun(scan), cfg.User)
+	if err != nil {
+		t.Fatalf("BuildImportPlan failed: %v", err)
+	}
+
+	// Same size, different content and modification time
+	writeCameraJPEG(t, src, date, "Apple", "iPhone 13")
+	os.Chtimes(src, date, date)
+
+	applyCfg, run := plan.Run(&Config{ImageExt: cfg.ImageExt, VideoExt: cfg.VideoExt})
+	for _, f := range run.Files {
+		if err := ProcessFile(f, applyCfg, run, plan.User, false, nil, true); err == nil {
+			t.Errorf("Expected %s refused after changing since planning", f)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(library, "family", "2023", "05", "01", "a.jpg")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing imported, got %v", err)
+	}
+}
+
+func TestApplyPlan_HashCheckedWithoutVerify(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	inputDir := filepath.Join(tempDir, "input")
+	os.MkdirAll(inputDir, 0755)
+
+	date := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	src := filepath.Join(inputDir, "a.jpg")
+	writeCameraJPEG(t, src, date, "Apple", "iPhone 12")
+	info, _ := os.Stat(src)
+
+	cfg := &Config{
+		User:     "family",
+		Library:  library,
+		VideoLib: library,
+		ImageExt: []string{".jpg"},
+		VideoExt: []string{".mp4"},
+		Timezone: "UTC",
+		Verify:   VerifyNone,
+	}
+	plan, err := BuildImportPlan(cfg, NewImportRun(&ScanResult{Files: []string{src}, InputDir: inputDir}), cfg.User)
+	if err != nil {
+		t.Fatalf("BuildImportPlan failed: %v", err)
+	}
+
+	// Same size and modification time, different content
+	writeCameraJPEG(t, src, date, "Apple", "iPhone 13")
+	os.Chtimes(src, info.ModTime(), info.ModTime())
+
+	applyCfg, run := plan.Run(&Config{ImageExt: cfg.ImageExt, VideoExt: cfg.VideoExt})
+	for _, f := range run.Files {
+		if err := ProcessFile(f, applyCfg, run, plan.User, false, nil, true); err == nil || !strings.Contains(err.Error(), "hash differs") {
+			t.Errorf("Expected %s refused by its hash, got %v", f, err)
+		}
+	}
+}
+
+func TestReadImportPlan_RejectsPathsOutsideLibrary(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	planPath := filepath.Join(tempDir, "plan.json")
+
+	tests := []PlanEntry{
+		{Src: "/in/a.jpg", Action: PlanCopy, Dest: filepath.Join(tempDir, "elsewhere", "a.jpg")},
+		{Src: "/in/a.jpg", Action: PlanCopy, Dest: filepath.Join(library, "..", "a.jpg")},
+		{Src: "/in/a.jpg", Action: PlanReplace, Dest: filepath.Join(library, "a.jpg"), Conflict: "/etc/passwd", ExistingHash: "abc"},
+		{Src: "/in/a.jpg", Action: PlanSkipDuplicate, Existing: library},
+	}
+	for _, e := range tests {
+		plan := &ImportPlan{Version: planVersion, LibraryPath: library, VideoLibraryPath: library, Entries: []PlanEntry{e}}
+		if err := WriteImportPlan(plan, planPath); err != nil {
+			t.Fatalf("WriteImportPlan failed: %v", err)
+		}
+		if _, err := ReadImportPlan(planPath); err == nil || !strings.Contains(err.Error(), "outside the library") {
+			t.Errorf("Expected %+v rejected, got %v", e, err)
+		}
+	}
+
+	inside := PlanEntry{Src: "/in/a.jpg", Action: PlanCopy, Dest: filepath.Join(library, "family", "a.jpg")}
+	plan := &ImportPlan{Version: planVersion, LibraryPath: library, VideoLibraryPath: library, Entries: []PlanEntry{inside}}
+	if err := WriteImportPlan(plan, planPath); err != nil {
+		t.Fatalf("WriteImportPlan failed: %v", err)
+	}
+	if _, err := ReadImportPlan(planPath); err != nil {
+		t.Errorf("Expected a plan inside the library accepted, got %v", err)
+	}
+}
+
+func TestApplyPlan_DuplicateFollowsItsFirstEntry(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	inputDir := filepath.Join(tempDir, "input")
+	os.MkdirAll(filepath.Join(inputDir, "copy"), 0755)
+
+	date := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	first := filepath.Join(inputDir, "a.jpg")
+	dup := filepath.Join(inputDir, "copy", "b.jpg")
+	other := filepath.Join(inputDir, "c.jpg")
+	writeCameraJPEG(t, first, date, "Apple", "iPhone 12")
+	data, _ := os.ReadFile(first)
+	os.WriteFile(dup, data, 0644)
+	writeCameraJPEG(t, other, date, "Apple", "iPhone 13")
+
+	cfg := &Config{
+		User:     "family",
+		Library:  library,
+		VideoLib: library,
+		ImageExt: []string{".jpg"},
+		VideoExt: []string{".mp4"},
+		Timezone: "UTC",
+		Verify:   VerifyDest,
+	}
+	plan, err := BuildImportPlan(cfg, NewImportRun(&ScanResult{Files: []string{first, other, dup}, InputDir: inputDir}), cfg.User)
+	if err != nil {
+		t.Fatalf("BuildImportPlan failed: %v", err)
+	}
+	if e := plan.Entries[2]; e.Action != PlanSkipDuplicate || e.DuplicateOf != first {
+		t.Fatalf("Expected b.jpg planned as a duplicate of a.jpg, got %+v", e)
+	}
+
+	loaded := &Config{ImageExt: cfg.ImageExt, VideoExt: cfg.VideoExt, Verify: VerifyFull}
+	applyCfg, run := plan.Run(loaded)
+	if loaded.Library != "" || loaded.Verify != VerifyFull || applyCfg.Library != library || applyCfg.Verify != VerifyDest {
+		t.Errorf("Expected the plan's settings in a copy of the loaded config, got %+v and %+v", loaded, applyCfg)
+	}
+
+	// The duplicate is processed right after the entry that places its content
+	batches := run.Batches(run.Files)
+	if len(batches) != 2 || len(batches[0]) != 2 || batches[0][1] != dup {
+		t.Fatalf("Expected b.jpg batched after a.jpg, got %v", batches)
+	}
+
+	// a.jpg fails: its planned destination appeared since planning
+	os.MkdirAll(filepath.Dir(plan.Entries[0].Dest), 0755)
+	os.WriteFile(plan.Entries[0].Dest, []byte("appeared"), 0644)
+	if err := ProcessFile(first, applyCfg, run, plan.User, false, nil, true); err == nil {
+		t.Fatal("Expected a.jpg refused")
+	}
+	if err := ProcessFile(dup, applyCfg, run, plan.User, false, nil, true); err == nil || !strings.Contains(err.Error(), "not imported") {
+		t.Errorf("Expected the duplicate of a refused entry refused, got %v", err)
+	}
+
+	// Once a.jpg is placed, its duplicate is skipped
+	os.Remove(plan.Entries[0].Dest)
+	for _, f := range []string{first, dup} {
+		if err := ProcessFile(f, applyCfg, run, plan.User, false, nil, true); err != nil {
+			t.Fatalf("ProcessFile(%s) failed: %v", f, err)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// freeSpaceReserve is kept free beyond the media itself, for manifests, the hash
+// index and filesystem overhead
+const freeSpaceReserve = 64 << 20
+
+var errFreeSpaceUnknown = errors.New("free space cannot be determined on this platform")
+
+// PreflightTarget is one filesystem an import writes to
+type PreflightTarget struct {
+	Paths      []string // Library roots on this filesystem
+	Files      int      // Files expected to add data
+	Needed     int64    // Bytes expected to be written, including sidecars
+	Free       int64    // Bytes available (-1 when unknown)
+	Duplicates int      // Files predicted to be skipped as duplicates
+	InPlace    int      // Files hardlinked, reflinked or moved within the filesystem (no data growth)
+
+	device      uint64 // Filesystem ID, when free space is known
+	deviceKnown bool
+}
+
+// Short reports whether the filesystem lacks room for the import
+func (t *PreflightTarget) Short() bool {
+	return t.Free >= 0 && t.Needed+freeSpaceReserve > t.Free
+}
+
+// PreflightReport is what an import needs before it starts
+type PreflightReport struct {
+	Targets  []*PreflightTarget
+	Problems []string // Missing space or permissions; the import must not start
+}
+
+// OK reports whether the import can start
+func (r *PreflightReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// PreflightImport checks that files (of run, as found by ScanMediaFiles) fit in the
+// libraries and that the libraries and their imports/ folder are writable, before
+// anything is copied. Files the hash index (or an applied plan) already places in
+// the library add no data, and neither do files hardlinked, reflinked or moved from
+// the same filesystem; the largest compressed-tar entry
+// spooled in ArchiveSpoolDir does, while it is imported. With probeWrite unset (dry runs)
+// nothing is created and only space is checked.
+func PreflightImport(files []string, cfg *Config, run *ImportRun, probeWrite bool) (*PreflightReport, error) {
+	report := &PreflightReport{}
+
+	// Libraries on the same filesystem share its free space
+	targets := make(map[string]*PreflightTarget) // By library root
+	byDevice := make(map[uint64]*PreflightTarget)
+	target := func(root string) (*PreflightTarget, error) {
+		if t, ok := targets[root]; ok {
+			return t, nil
+		}
+		t := &PreflightTarget{Free: -1}
+		free, device, err := diskSpace(existingAncestor(root))
+		switch {
+		case err == nil:
+			if shared, ok := byDevice[device]; ok {
+				t = shared
+			} else {
+				t.Free = int64(free)
+				t.device, t.deviceKnown = device, true
+				byDevice[device] = t
+				report.Targets = append(report.Targets, t)
+			}
+		case errors.Is(err, errFreeSpaceUnknown):
+			report.Targets = append(report.Targets, t)
+		default:
+			return nil, fmt.Errorf("failed to check free space of %s: %w", root, err)
+		}
+		t.Paths = append(t.Paths, root)
+		targets[root] = t
+		return t, nil
+	}
+
+	// Compressed tars are read one entry at a time, so one spooled entry is on disk at once
+	var spool int64
+	for _, src := range files {
+		if size := spooledSize(src); size > spool {
+			spool = size
+		}
+	}
+	if spool > 0 {
+		t, err := target(cfg.Library)
+		if err != nil {
+			return nil, err
+		}
+		t.Needed += spool
+	}
+
+	srcDevices := make(map[string]uint64) // Filesystem of each source folder
+	for _, src := range files {
+		fileType := mediaType(src, cfg, run.Formats)
+		if fileType == TypeOther {
+			continue
+		}
+		root := cfg.Library
+		if fileType == TypeVideo && cfg.VideoLib != "" {
+			root = cfg.VideoLib
+		}
+		t, err := target(root)
+		if err != nil {
+			return nil, err
+		}
+
+		size, _, err := statSource(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat source %s: %w", src, err)
+		}
+		if predictDuplicate(src, size, run) {
+			t.Duplicates++
+			continue
+		}
+		if inPlaceTransfer(src, t, cfg, srcDevices) {
+			t.InPlace++
+			continue
+		}
+		t.Files++
+		t.Needed += size
+		for _, sidecar := range run.Sidecars[src] {
+			if size, _, err := statSource(sidecar); err == nil {
+				t.Needed += size
+			}
+		}
+	}
+
+	for _, t := range report.Targets {
+		if t.Short() {
+			report.Problems = append(report.Problems, fmt.Sprintf("not enough space on %s: need %s (plus %s reserve), %s free",
+				strings.Join(t.Paths, ", "), formatBytes(t.Needed), formatBytes(freeSpaceReserve), formatBytes(t.Free)))
+		}
+	}
+
+	if probeWrite {
+		dirs := []string{cfg.Library, filepath.Join(cfg.Library, "imports")}
+		if cfg.VideoLib != "" && cfg.VideoLib != cfg.Library {
+			dirs = append(dirs, cfg.VideoLib)
+		}
+		for _, dir := range dirs {
+			if err := probeWritable(dir); err != nil {
+				report.Problems = append(report.Problems, err.Error())
+			}
+		}
+	}
+	return report, nil
+}
+
+// inPlaceTransfer reports whether src reaches target without writing its data:
+// hardlinked, or reflinked or moved (renamed) within the filesystem. Archive entries
+// are always written.
+func inPlaceTransfer(src string, t *PreflightTarget, cfg *Config, srcDevices map[string]uint64) bool {
+	if IsArchiveEntry(src) {
+		return false
+	}
+	if cfg.UseHardlinks {
+		return true
+	}
+	reflinks := cfg.Reflink == ReflinkAuto || cfg.Reflink == ReflinkAlways
+	if (!cfg.MoveFiles && !reflinks) || !t.deviceKnown {
+		return false
+	}
+	dir := filepath.Dir(src)
+	device, ok := srcDevices[dir]
+	if !ok {
+		_, dev, err := diskSpace(dir)
+		if err != nil {
+			return false
+		}
+		device = dev
+		srcDevices[dir] = device
+	}
+	return device == t.device
+}
+
+// predictDuplicate reports whether the import will skip src as content already in
+// the library (or, applying a plan, as planned). Only sources whose size some indexed
+// file has are hashed, and entries of compressed tars (which can only be read front to
+// back) are not. The hash is kept in the source cache for the import to reuse.
+func predictDuplicate(src string, size int64, run *ImportRun) bool {
+	if planned := run.Plan[src]; planned != nil {
+		return planned.Action == PlanSkipDuplicate || planned.Action == PlanSkipConflict
+	}
+	if run.Index == nil || !run.Index.HasSize(size) {
+		return false
+	}
+	if IsArchiveEntry(src) && NeedsSequentialRead([]string{src}) {
+		return false
+	}
+	fp, err := fingerprintSource(src)
+	if err != nil {
+		return false
+	}
+	hash, err := hashSource(src, run.Sources)
+	if err != nil {
+		return false
+	}
+	run.Sources.rememberHash(src, fp, hash)
+	_, ok := run.Index.Lookup(hash)
+	return ok
+}
+
+// existingAncestor returns path or its nearest existing parent, so the free space of
+// a library that doesn't exist yet can be checked
+func existingAncestor(path string) string {
+	path, _ = filepath.Abs(path)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// probeWritable creates dir if needed and checks a file can be written in it
+func probeWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create %s: %w", dir, err)
+	}
+	f, err := os.CreateTemp(dir, ".anduril-write-test-*")
+	if err != nil {
+		return fmt.Errorf("cannot write to %s: %w", dir, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil
+}
+
+// Report describes the space each library filesystem needs and any problems found
+func (r *PreflightReport) Report() string {
+	var report strings.Builder
+	for _, t := range r.Targets {
+		free := "unknown"
+		if t.Free >= 0 {
+			free = formatBytes(t.Free)
+		}
+		report.WriteString(fmt.Sprintf("  %s: %s needed for %d files, %s free", strings.Join(t.Paths, ", "), formatBytes(t.Needed), t.Files, free))
+		var notes []string
+		if t.Duplicates > 0 {
+			notes = append(notes, fmt.Sprintf("%d duplicates skipped", t.Duplicates))
+		}
+		if t.InPlace > 0 {
+			notes = append(notes, fmt.Sprintf("%d linked or moved in place", t.InPlace))
+		}
+		if len(notes) > 0 {
+			report.WriteString(" (" + strings.Join(notes, ", ") + ")")
+		}
+		report.WriteString("\n")
+	}
+	for _, problem := range r.Problems {
+		report.WriteString(fmt.Sprintf("  ❌ %s\n", problem))
+	}
+	return report.String()
+}
@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreflightImport(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	srcDir := filepath.Join(tempDir, "src")
+	os.MkdirAll(srcDir, 0755)
+
+	// One source is already in the library, the other is new
+	known := filepath.Join(srcDir, "known.jpg")
+	fresh := filepath.Join(srcDir, "fresh.jpg")
+	os.WriteFile(known, []byte("already imported"), 0644)
+	os.WriteFile(fresh, []byte("new photo content"), 0644)
+	libFile := filepath.Join(library, "user", "known.jpg")
+	os.MkdirAll(filepath.Dir(libFile), 0755)
+	os.WriteFile(libFile, []byte("already imported"), 0644)
+
+	index, err := OpenHashIndex(library, true)
+	if err != nil {
+		t.Fatalf("OpenHashIndex failed: %v", err)
+	}
+	hash, _ := fileHash(known)
+	index.Add(hash, libFile, int64(len("already imported")))
+
+	cfg := &Config{
+		Library:  library,
+		VideoLib: library,
+		ImageExt: []string{".jpg"},
+		VideoExt: []string{".mp4"},
+	}
+	run := &ImportRun{Index: index}
+	files := []string{known, fresh}
+
+	report, err := PreflightImport(files, cfg, run, true)
+	if err != nil {
+		t.Fatalf("PreflightImport failed: %v", err)
+	}
+	if !report.OK() || len(report.Targets) != 1 {
+		t.Fatalf("Expected one writable target, got %+v", report)
+	}
+	target := report.Targets[0]
+	if target.Needed != int64(len("new photo content")) || target.Files != 1 || target.Duplicates != 1 {
+		t.Errorf("Expected only the new file counted, got %+v", target)
+	}
+	if _, err := os.Stat(filepath.Join(library, "imports")); err != nil {
+		t.Errorf("Expected imports/ to be created by the write check: %v", err)
+	}
+
+	// Hardlinks add no data
+	cfg.UseHardlinks = true
+	report, err = PreflightImport(files, cfg, run, false)
+	if err != nil {
+		t.Fatalf("PreflightImport failed: %v", err)
+	}
+	if target := report.Targets[0]; target.Needed != 0 || target.InPlace != 1 {
+		t.Errorf("Expected hardlinked import to need no space, got %+v", target)
+	}
+
+	// So do moves and reflinks within the filesystem
+	cfg.UseHardlinks = false
+	for _, move := range []bool{true, false} {
+		cfg.MoveFiles, cfg.Reflink = move, ReflinkAuto
+		if move {
+			cfg.Reflink = ReflinkNever
+		}
+		report, err = PreflightImport(files, cfg, run, false)
+		if err != nil {
+			t.Fatalf("PreflightImport failed: %v", err)
+		}
+		if target := report.Targets[0]; target.Needed != 0 || target.InPlace != 1 {
+			t.Errorf("Expected a same-filesystem import (move %v) to need no space, got %+v", move, target)
+		}
+	}
+}
+
+func TestPreflightImport_ReusesHash(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	src := filepath.Join(tempDir, "src", "a.jpg")
+	os.MkdirAll(filepath.Dir(src), 0755)
+	os.WriteFile(src, []byte("photo content"), 0644)
+
+	index, err := OpenHashIndex(library, true)
+	if err != nil {
+		t.Fatalf("OpenHashIndex failed: %v", err)
+	}
+	index.Add("other", filepath.Join(library, "b.jpg"), int64(len("photo content")))
+	sources, err := OpenSourceCache(library, true)
+	if err != nil {
+		t.Fatalf("OpenSourceCache failed: %v", err)
+	}
+	cfg := &Config{Library: library, VideoLib: library, ImageExt: []string{".jpg"}}
+	run := &ImportRun{Index: index, Sources: sources}
+
+	if _, err := PreflightImport([]string{src}, cfg, run, false); err != nil {
+		t.Fatalf("PreflightImport failed: %v", err)
+	}
+	want, _ := fileHash(src)
+	if hash, ok := sources.knownHash(src); !ok || hash != want {
+		t.Fatalf("Expected the size-matching source's hash kept for the import, got %q", hash)
+	}
+
+	// A source changed since is hashed again
+	os.WriteFile(src, []byte("other content"), 0644)
+	if _, ok := sources.knownHash(src); ok {
+		t.Error("Expected the hash of a changed source forgotten")
+	}
+}
+
+func TestPreflightImport_Problems(t *testing.T) {
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	os.MkdirAll(srcDir, 0755)
+	src := filepath.Join(srcDir, "photo.jpg")
+	os.WriteFile(src, []byte("photo content"), 0644)
+
+	// A library below a regular file can never be created
+	blocker := filepath.Join(tempDir, "blocker")
+	os.WriteFile(blocker, nil, 0644)
+	cfg := &Config{
+		Library:  filepath.Join(blocker, "library"),
+		VideoLib: filepath.Join(blocker, "library"),
+		ImageExt: []string{".jpg"},
+		VideoExt: []string{".mp4"},
+	}
+	report, err := PreflightImport([]string{src}, cfg, &ImportRun{}, true)
+	if err != nil {
+		t.Fatalf("PreflightImport failed: %v", err)
+	}
+	if report.OK() || !strings.Contains(report.Report(), "cannot create") {
+		t.Errorf("Expected an unwritable library reported, got %q", report.Report())
+	}
+
+	short := &PreflightTarget{Needed: 10 << 20, Free: 20 << 20}
+	if !short.Short() {
+		t.Error("Expected the reserve to count against free space")
+	}
+	if (&PreflightTarget{Needed: 10 << 30, Free: -1}).Short() {
+		t.Error("Expected unknown free space not to block the import")
+	}
+}
@@ -0,0 +1,26 @@
+//go:build linux
+
+package internal
+
+import (
+	"errors"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl request (_IOW(0x94, 9, int)) understood by btrfs, XFS and others
+const ficlone = 0x40049409
+
+// cloneFile shares src's extents with dst (both open) without copying data
+func cloneFile(dstFd, srcFd uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dstFd, ficlone, srcFd)
+	if errno == 0 {
+		return nil
+	}
+	// Only these mean the filesystem can't clone; anything else (EBADF included) is a
+	// real failure that must not quietly turn into a copy
+	if errors.Is(errno, syscall.EOPNOTSUPP) || errors.Is(errno, syscall.EXDEV) ||
+		errors.Is(errno, syscall.EINVAL) {
+		return ErrReflinkUnsupported
+	}
+	return errno
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package internal
+
+// cloneFile is only implemented on Linux; elsewhere reflinks are reported as unsupported
+func cloneFile(dstFd, srcFd uintptr) error {
+	return ErrReflinkUnsupported
+}
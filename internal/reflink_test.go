@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcessFile_ReflinkAutoRecordsMethod(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	srcDir := filepath.Join(tempDir, "src")
+	os.MkdirAll(srcDir, 0755)
+
+	cfg := &Config{
+		User:     "user",
+		Library:  library,
+		VideoLib: library,
+		ImageExt: []string{".jpg"},
+		VideoExt: []string{".mp4"},
+		Reflink:  ReflinkAuto,
+	}
+
+	// Whether the temp filesystem supports reflinks decides the expected method
+	want := TransferReflink
+	if err := TestReflinkSupport(srcDir, library); err != nil {
+		if !errors.Is(err, ErrReflinkUnsupported) {
+			t.Fatalf("TestReflinkSupport failed unexpectedly: %v", err)
+		}
+		want = TransferCopy
+	}
+
+	src := filepath.Join(srcDir, "photo.jpg")
+	os.WriteFile(src, []byte("photo content"), 0644)
+
+	session, err := NewImportSession(library, library, cfg.User, srcDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+
+	if err := ProcessFile(src, cfg, nil, cfg.User, false, session, true); err != nil {
+		t.Fatalf("ProcessFile failed: %v", err)
+	}
+	session.Close()
+
+	events, err := ReadManifest(filepath.Join(session.SessionDir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+
+	found := false
+	for _, event := range events {
+		if event.Event != "copied" {
+			continue
+		}
+		found = true
+		if event.Method != want {
+			t.Errorf("Expected method %q, got %q", want, event.Method)
+		}
+		data, err := os.ReadFile(event.Dest)
+		if err != nil || string(data) != "photo content" {
+			t.Errorf("Imported file content mismatch: %q, %v", data, err)
+		}
+	}
+	if !found {
+		t.Fatalf("No copied event in manifest")
+	}
+}
+
+func TestCloneOrCopy_AlwaysFailsWithoutReflink(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "a.jpg")
+	dest := filepath.Join(tempDir, "b.jpg")
+	os.WriteFile(src, []byte("data"), 0644)
+
+	if err := TestReflinkSupport(tempDir, tempDir); err == nil {
+		t.Skip("filesystem supports reflinks")
+	}
+
+	if _, _, err := cloneOrCopy(src, dest, ReflinkAlways, "", false); !errors.Is(err, ErrReflinkUnsupported) {
+		t.Errorf("Expected ErrReflinkUnsupported, got %v", err)
+	}
+	if _, err := os.Stat(dest + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Temp file should have been removed")
+	}
+
+	method, _, err := cloneOrCopy(src, dest, ReflinkAuto, "", false)
+	if err != nil || method != TransferCopy {
+		t.Errorf("Expected auto fallback to copy, got %q, %v", method, err)
+	}
+}
@@ -0,0 +1,73 @@
+package internal
+
+import "sync"
+
+// ImportRun is the state of one import: what the scan (or an applied plan) found and
+// the library indexes opened for it. It is kept out of Config, so one Config serves
+// any number of runs, including the watcher's concurrent ones. A nil run imports
+// files on their own, without indexes.
+type ImportRun struct {
+	ScanResult
+	Index     *HashIndex            // Library hash index, when hash_index is on
+	DHashes   *PerceptualIndex      // Library perceptual index, when near_duplicates.policy is set
+	Sources   *SourceCache          // Library source cache
+	Plan      map[string]*PlanEntry // Decisions of an applied import plan keyed by source
+	Unchanged int                   // Sources skipped by SkipUnchangedSources
+
+	planPlaced sync.Map // Plan entries placed in the library so far, keyed by source
+}
+
+// NewImportRun starts a run importing what scan found
+func NewImportRun(scan *ScanResult) *ImportRun {
+	return &ImportRun{ScanResult: *scan}
+}
+
+// Batches splits files into the units an import processes in order on one worker, as
+// GroupBatches does. A planned duplicate of an earlier entry joins that entry's batch,
+// so it is only recorded once the content it points to was placed.
+func (r *ImportRun) Batches(files []string) [][]string {
+	batches := GroupBatches(files, r.Groups)
+	if r.Plan == nil {
+		return batches
+	}
+	var result [][]string
+	at := make(map[string]int)
+	for _, batch := range batches {
+		if len(batch) == 1 {
+			if planned := r.Plan[batch[0]]; planned != nil && planned.DuplicateOf != "" {
+				if i, ok := at[planned.DuplicateOf]; ok {
+					result[i] = append(result[i], batch[0])
+					continue
+				}
+			}
+		}
+		for _, f := range batch {
+			at[f] = len(result)
+		}
+		result = append(result, batch)
+	}
+	return result
+}
+
+// SkipUnchangedSources drops the files the source cache knows as imported and
+// unchanged with their sidecars, counting them in Unchanged. A new, changed or
+// removed sidecar brings its primary back to be imported.
+func (r *ImportRun) SkipUnchangedSources() {
+	if r.Sources == nil {
+		return
+	}
+	var pending []string
+	r.Unchanged = 0
+	for _, f := range r.Files {
+		if dest, ok := r.Sources.Unchanged(f, r.Sidecars[f]); ok {
+			r.Unchanged++
+			// Group members still to import follow the lead to where it already is
+			if group := r.Groups[f]; group != nil && group.Lead == f {
+				group.setLeadDest(dest)
+			}
+			continue
+		}
+		pending = append(pending, f)
+	}
+	r.Files = pending
+}
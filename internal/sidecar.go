@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Sidecars are small companion files carrying edits or metadata for a media file:
+// .xmp (Lightroom/darktable edits), .aae (iOS adjustments), .thm/.lrv (camera
+// thumbnails and low-res proxies) and .srt (drone telemetry). They are matched to
+// their primary file by stem, either IMG_1.xmp or IMG_1.JPG.xmp, and follow it into
+// the library under the primary's final name.
+
+// isSidecar reports whether path has one of the configured sidecar extensions
+func isSidecar(path string, cfg *Config) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range cfg.SidecarExt {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// associateSidecars maps each sidecar to the primary files in its directory. A sidecar
+// named after a full file name (IMG_1.CR2.xmp) belongs to that file only; one named
+// after a stem (IMG_1.xmp) belongs to every primary with that stem, e.g. RAW and JPEG.
+// Matching is case-insensitive. Sidecars without a primary are left out.
+func associateSidecars(primaries, sidecars []string) map[string][]string {
+	byName := make(map[string]string)
+	byStem := make(map[string][]string)
+	for _, primary := range primaries {
+		dir := filepath.Dir(primary)
+		name := strings.ToLower(filepath.Base(primary))
+		byName[filepath.Join(dir, name)] = primary
+		stem := strings.TrimSuffix(name, filepath.Ext(name))
+		byStem[filepath.Join(dir, stem)] = append(byStem[filepath.Join(dir, stem)], primary)
+	}
+
+	result := make(map[string][]string)
+	for _, sidecar := range sidecars {
+		dir := filepath.Dir(sidecar)
+		name := strings.ToLower(filepath.Base(sidecar))
+		key := filepath.Join(dir, strings.TrimSuffix(name, filepath.Ext(name)))
+
+		if primary, ok := byName[key]; ok {
+			result[primary] = append(result[primary], sidecar)
+			continue
+		}
+		for _, primary := range byStem[key] {
+			result[primary] = append(result[primary], sidecar)
+		}
+	}
+	return result
+}
+
+// sidecarDestPath names a sidecar after its primary's library file parentDest, so
+// renames and timestamp suffixes carry over: IMG_1.xmp next to IMG_1_1700000000.jpg
+// becomes IMG_1_1700000000.xmp, and IMG_1.JPG.xmp becomes IMG_1_1700000000.jpg.xmp.
+func sidecarDestPath(sidecar, parentSrc, parentDest string, cfg *Config) string {
+	ext := cfg.Naming.extension(filepath.Ext(sidecar))
+	rest := strings.TrimSuffix(filepath.Base(sidecar), filepath.Ext(sidecar))
+
+	parentBase := filepath.Base(parentDest)
+	if strings.EqualFold(rest, filepath.Base(parentSrc)) {
+		return filepath.Join(filepath.Dir(parentDest), parentBase+ext)
+	}
+	parentStem := strings.TrimSuffix(parentBase, filepath.Ext(parentBase))
+	return filepath.Join(filepath.Dir(parentDest), parentStem+ext)
+}
+
+// placeSidecars brings the sidecars of src next to parentDest, where the primary now
+// lives (imported or already present). Failures are reported but never fail the
+// primary import.
+func placeSidecars(src, parentDest string, cfg *Config, run *ImportRun, session *ImportSession, dryRun, isSilent bool) bool {
+	placed := true
+	for _, sidecar := range run.Sidecars[src] {
+		dest := sidecarDestPath(sidecar, src, parentDest, cfg)
+		if dryRun {
+			if !isSilent {
+				fmt.Printf("[dry-run] sidecar %s → %s\n", sidecar, dest)
+			}
+			continue
+		}
+
+		if err := placeSidecar(sidecar, src, dest, parentDest, cfg, session, isSilent); err != nil {
+			fmt.Printf("Warning: failed to import sidecar %s: %v\n", sidecar, err)
+			if session != nil {
+				session.LogError(sidecar, err)
+			}
+			placed = false
+		}
+	}
+	return placed
+}
+
+// placeSidecar copies (or links) a single sidecar to dest with verification. A dest
+// holding other content (likely edited in the library since) is never overwritten: like
+// a media conflict, the sidecar is kept next to it under a timestamp-suffixed name.
+func placeSidecar(sidecar, parentSrc, dest, parentDest string, cfg *Config, session *ImportSession, isSilent bool) error {
+	unlock := lockDestination(dest)
+	defer func() { unlock() }()
+
+	if _, err := os.Stat(dest); err == nil {
+		srcHash, err := fileHash(sidecar)
+		if err != nil {
+			return fmt.Errorf("failed to hash sidecar: %w", err)
+		}
+		destHash, err := fileHash(dest)
+		if err != nil {
+			return fmt.Errorf("failed to hash existing sidecar %s: %w", dest, err)
+		}
+		if srcHash == destHash {
+			queueSourceRemoval(cfg, session, sidecar, dest, srcHash)
+			return nil
+		}
+
+		// Suffixed the way the parent would be, so the pair still reads as one
+		existing := dest
+		unlock()
+		dest = sidecarDestPath(sidecar, parentSrc, timestampSuffixCopyPath(parentDest), cfg)
+		unlock = lockDestination(dest)
+		if _, err := os.Stat(dest); err == nil {
+			dest = safeCopyPath(dest)
+		}
+		if !isSilent {
+			fmt.Printf("Sidecar %s differs from %s, keeping both as %s\n", sidecar, existing, dest)
+		}
+		if session != nil {
+			session.LogSidecarConflict(sidecar, dest, existing, parentDest, srcHash, destHash)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	var hash, method string
+	if cfg.UseHardlinks && !IsArchiveEntry(sidecar) {
+		if err := linkFile(sidecar, dest); err != nil {
+			return fmt.Errorf("failed to link %s: %w", dest, err)
+		}
+		hash, _ = fileHash(dest)
+		method = TransferHardlink
+	} else {
+		copyHash, err := copyFileAtomic(sidecar, dest)
+		if err != nil {
+			return fmt.Errorf("failed to copy to %s: %w", dest, err)
+		}
+		if err := verifyCopy(sidecar, dest, copyHash, cfg.Verify); err != nil {
+			_ = os.Remove(dest)
+			return err
+		}
+		hash = copyHash
+		method = TransferCopy
+	}
+
+	if !isSilent {
+		fmt.Printf("Sidecar %s → %s\n", sidecar, dest)
+	}
+	if session != nil {
+		size, _ := getFileSize(dest)
+		session.LogSidecar(sidecar, dest, parentDest, hash, size, method)
+	}
+	queueSourceRemoval(cfg, session, sidecar, dest, hash)
+
+	return nil
+}
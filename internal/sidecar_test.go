@@ -0,0 +1,214 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestAssociateSidecars(t *testing.T) {
+	primaries := []string{
+		"/in/IMG_1.CR2",
+		"/in/IMG_1.JPG",
+		"/in/VID_2.MP4",
+		"/in/sub/VID_2.MP4",
+	}
+	sidecars := []string{
+		"/in/IMG_1.xmp",     // stem: both RAW and JPEG
+		"/in/IMG_1.CR2.xmp", // full name: RAW only
+		"/in/vid_2.srt",     // case-insensitive
+		"/in/sub/VID_2.LRV",
+		"/in/orphan.aae",
+	}
+
+	got := associateSidecars(primaries, sidecars)
+	for _, list := range got {
+		sort.Strings(list)
+	}
+
+	want := map[string][]string{
+		"/in/IMG_1.CR2":     {"/in/IMG_1.CR2.xmp", "/in/IMG_1.xmp"},
+		"/in/IMG_1.JPG":     {"/in/IMG_1.xmp"},
+		"/in/VID_2.MP4":     {"/in/vid_2.srt"},
+		"/in/sub/VID_2.MP4": {"/in/sub/VID_2.LRV"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("associateSidecars = %v, want %v", got, want)
+	}
+}
+
+func TestSidecarDestPath(t *testing.T) {
+	cfg := &Config{}
+	tests := []struct {
+		sidecar, parentSrc, parentDest, want string
+	}{
+		{"/in/IMG_1.xmp", "/in/IMG_1.JPG", "/lib/IMG_1.JPG", "/lib/IMG_1.xmp"},
+		{"/in/IMG_1.xmp", "/in/IMG_1.JPG", "/lib/IMG_1_1700000000.JPG", "/lib/IMG_1_1700000000.xmp"},
+		{"/in/IMG_1.JPG.xmp", "/in/IMG_1.JPG", "/lib/20240315_abcd.jpg", "/lib/20240315_abcd.jpg.xmp"},
+	}
+	for _, tt := range tests {
+		if got := sidecarDestPath(tt.sidecar, tt.parentSrc, tt.parentDest, cfg); got != tt.want {
+			t.Errorf("sidecarDestPath(%s) = %s, want %s", tt.sidecar, got, tt.want)
+		}
+	}
+}
+
+func TestProcessFile_SidecarFollowsTimestampedCopy(t *testing.T) {
+	originalNow := timeNow
+	defer func() { timeNow = originalNow }()
+	timeNow = func() time.Time { return time.Unix(1700000000, 0) }
+
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	inputDir := filepath.Join(tempDir, "input")
+	os.MkdirAll(inputDir, 0755)
+
+	cfg := &Config{
+		User:       "user",
+		Library:    library,
+		VideoLib:   library,
+		ImageExt:   []string{".jpg"},
+		VideoExt:   []string{".mp4"},
+		SidecarExt: []string{".xmp", ".aae"},
+	}
+
+	// A different photo already occupies the destination name
+	mtime := time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC)
+	destDir := filepath.Join(library, "user", "noexif", "2024-02")
+	os.MkdirAll(destDir, 0755)
+	os.WriteFile(filepath.Join(destDir, "IMG_1.jpg"), []byte("older photo"), 0644)
+
+	src := filepath.Join(inputDir, "IMG_1.jpg")
+	os.WriteFile(src, []byte("new photo"), 0644)
+	os.Chtimes(src, mtime, mtime)
+	os.WriteFile(filepath.Join(inputDir, "IMG_1.xmp"), []byte("<xmp/>"), 0644)
+	os.WriteFile(filepath.Join(inputDir, "IMG_1.AAE"), []byte("<plist/>"), 0644)
+
+	scan, err := ScanMediaFiles(inputDir, cfg)
+	if err != nil {
+		t.Fatalf("ScanMediaFiles failed: %v", err)
+	}
+	run := NewImportRun(scan)
+	files := run.Files
+	if len(files) != 1 || len(run.Sidecars[src]) != 2 {
+		t.Fatalf("Expected 1 media file with 2 sidecars, got %v / %v", files, run.Sidecars)
+	}
+
+	session, err := NewImportSession(library, library, cfg.User, inputDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	if err := ProcessFile(src, cfg, run, cfg.User, false, session, true); err != nil {
+		t.Fatalf("ProcessFile failed: %v", err)
+	}
+	session.Close()
+
+	parent := filepath.Join(destDir, "IMG_1_1700000000.jpg")
+	for _, name := range []string{"IMG_1_1700000000.xmp", "IMG_1_1700000000.AAE"} {
+		if _, err := os.Stat(filepath.Join(destDir, name)); err != nil {
+			t.Errorf("Expected sidecar %s next to %s: %v", name, parent, err)
+		}
+	}
+
+	events, err := ReadManifest(filepath.Join(session.SessionDir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	sidecarEvents := 0
+	for _, event := range events {
+		if event.Event == "copied_sidecar" {
+			sidecarEvents++
+			if event.Parent != parent {
+				t.Errorf("Expected parent %s, got %s", parent, event.Parent)
+			}
+		}
+	}
+	if sidecarEvents != 2 || session.GetStats().Sidecars != 2 {
+		t.Errorf("Expected 2 sidecar events, got %d", sidecarEvents)
+	}
+
+	// Undo removes sidecars with their parent
+	result, err := UndoImportSession(library, session.ID, false)
+	if err != nil {
+		t.Fatalf("UndoImportSession failed: %v", err)
+	}
+	if len(result.Removed) != 3 {
+		t.Errorf("Expected photo and 2 sidecars removed, got %v", result.Removed)
+	}
+}
+
+func TestProcessFile_SidecarConflictKeepsBoth(t *testing.T) {
+	originalNow := timeNow
+	defer func() { timeNow = originalNow }()
+	timeNow = func() time.Time { return time.Unix(1700000000, 0) }
+
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	inputDir := filepath.Join(tempDir, "input")
+	os.MkdirAll(inputDir, 0755)
+
+	cfg := &Config{
+		User:       "user",
+		Library:    library,
+		VideoLib:   library,
+		ImageExt:   []string{".jpg"},
+		VideoExt:   []string{".mp4"},
+		SidecarExt: []string{".xmp"},
+	}
+
+	// The photo was imported before, and its sidecar edited in the library since
+	mtime := time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC)
+	destDir := filepath.Join(library, "user", "noexif", "2024-02")
+	os.MkdirAll(destDir, 0755)
+	os.WriteFile(filepath.Join(destDir, "IMG_1.jpg"), []byte("photo"), 0644)
+	existing := filepath.Join(destDir, "IMG_1.xmp")
+	os.WriteFile(existing, []byte("<xmp>library edits</xmp>"), 0644)
+
+	src := filepath.Join(inputDir, "IMG_1.jpg")
+	os.WriteFile(src, []byte("photo"), 0644)
+	os.Chtimes(src, mtime, mtime)
+	os.WriteFile(filepath.Join(inputDir, "IMG_1.xmp"), []byte("<xmp>camera edits</xmp>"), 0644)
+
+	scan, err := ScanMediaFiles(inputDir, cfg)
+	if err != nil {
+		t.Fatalf("ScanMediaFiles failed: %v", err)
+	}
+	run := NewImportRun(scan)
+	files := run.Files
+	session, err := NewImportSession(library, library, cfg.User, inputDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	if err := ProcessFile(files[0], cfg, run, cfg.User, false, session, true); err != nil {
+		t.Fatalf("ProcessFile failed: %v", err)
+	}
+	session.Close()
+
+	if data, _ := os.ReadFile(existing); string(data) != "<xmp>library edits</xmp>" {
+		t.Errorf("Expected the library sidecar untouched, got %q", data)
+	}
+	suffixed := filepath.Join(destDir, "IMG_1_1700000000.xmp")
+	if data, _ := os.ReadFile(suffixed); string(data) != "<xmp>camera edits</xmp>" {
+		t.Errorf("Expected the incoming sidecar kept at %s, got %q", suffixed, data)
+	}
+
+	events, err := ReadManifest(filepath.Join(session.SessionDir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	conflicts := 0
+	for _, event := range events {
+		if event.Event == "sidecar_conflict" {
+			conflicts++
+			if event.Dest != suffixed || event.Existing != existing || event.Hash == event.ExistingHash {
+				t.Errorf("Unexpected sidecar_conflict event: %+v", event)
+			}
+		}
+	}
+	if conflicts != 1 {
+		t.Errorf("Expected 1 sidecar_conflict event, got %d", conflicts)
+	}
+}
@@ -0,0 +1,307 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// SourceCache remembers the sources a library imported: their path, size,
+// modification time and inode, with the content hash and the library file they
+// went to, and the fingerprints of their sidecars. A repeated import of the same
+// folder skips sources whose fingerprint and sidecars are unchanged and whose
+// library file is still there, without reading them.
+//
+// The cache lives in <library>/.anduril/sources.jsonl as append-only JSON lines;
+// later lines win, and superseded and stale lines are compacted away on Close, like
+// the hash index.
+type SourceCache struct {
+	path    string
+	file    *os.File // nil when read-only
+	entries map[string]sourceCacheEntry
+	hashed  map[string]sourceCacheEntry // Hashes taken this run before importing (by preflight), not recorded
+	lines   int                         // Lines in the file, compacted on Close when more than entries
+	mu      sync.Mutex
+}
+
+// sourceFingerprint identifies a version of a source file without reading it
+type sourceFingerprint struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"`           // Unix nanoseconds
+	Inode   uint64 `json:"inode,omitempty"` // 0 for archive entries and where unavailable
+}
+
+type sourceCacheEntry struct {
+	Src string `json:"src"` // Absolute path, or archive entry path
+	sourceFingerprint
+	Hash     string                       `json:"hash"`
+	Dest     string                       `json:"dest"`
+	Sidecars map[string]sourceFingerprint `json:"sidecars,omitempty"` // By absolute path
+}
+
+// sameSidecars reports whether two sets of sidecar fingerprints are equal
+func sameSidecars(a, b map[string]sourceFingerprint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, fp := range a {
+		if other, ok := b[path]; !ok || other != fp {
+			return false
+		}
+	}
+	return true
+}
+
+// SourceCachePath returns the cache location for a library root
+func SourceCachePath(libraryPath string) string {
+	return filepath.Join(libraryPath, ".anduril", "sources.jsonl")
+}
+
+// OpenSourceCache loads the library's source cache. With readOnly set nothing is
+// created or written (used for dry runs).
+func OpenSourceCache(libraryPath string, readOnly bool) (*SourceCache, error) {
+	c := &SourceCache{
+		path:    SourceCachePath(libraryPath),
+		entries: make(map[string]sourceCacheEntry),
+		hashed:  make(map[string]sourceCacheEntry),
+	}
+
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	if readOnly {
+		return c, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create source cache directory: %w", err)
+	}
+	f, err := os.OpenFile(c.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source cache: %w", err)
+	}
+	c.file = f
+	return c, nil
+}
+
+// load reads existing entries; a missing cache is simply empty
+func (c *SourceCache) load() error {
+	f, err := os.Open(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read source cache: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		c.lines++
+		var entry sourceCacheEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// Blank or torn final line after a crash; skip it
+			continue
+		}
+		c.entries[entry.Src] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read source cache: %w", err)
+	}
+	return nil
+}
+
+// fingerprintSource stats src (a file or archive entry)
+func fingerprintSource(src string) (sourceFingerprint, error) {
+	if IsArchiveEntry(src) {
+		size, modTime, err := statSource(src)
+		if err != nil {
+			return sourceFingerprint{}, err
+		}
+		return sourceFingerprint{Size: size, ModTime: modTime.UnixNano()}, nil
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return sourceFingerprint{}, err
+	}
+	return sourceFingerprint{Size: info.Size(), ModTime: info.ModTime().UnixNano(), Inode: fileInode(info)}, nil
+}
+
+// fingerprintSidecars stats the sidecars of a source, keyed by absolute path
+func fingerprintSidecars(sidecars []string) (map[string]sourceFingerprint, error) {
+	if len(sidecars) == 0 {
+		return nil, nil
+	}
+	fps := make(map[string]sourceFingerprint, len(sidecars))
+	for _, sidecar := range sidecars {
+		fp, err := fingerprintSource(sidecar)
+		if err != nil {
+			return nil, err
+		}
+		fps[sourceKey(sidecar)] = fp
+	}
+	return fps, nil
+}
+
+// sourceKey is the absolute form of src that entries are stored under
+func sourceKey(src string) string {
+	if abs, err := filepath.Abs(src); err == nil {
+		return abs
+	}
+	return src
+}
+
+// Unchanged returns the library file src was imported to, if src still has the
+// fingerprint it was imported with, its sidecars are the ones imported with it and
+// unchanged, and that library file still exists. Entries whose library file is gone
+// are dropped.
+func (c *SourceCache) Unchanged(src string, sidecars []string) (string, bool) {
+	c.mu.Lock()
+	entry, ok := c.entries[sourceKey(src)]
+	c.mu.Unlock()
+	if !ok {
+		return "", false
+	}
+
+	fp, err := fingerprintSource(src)
+	if err != nil || fp != entry.sourceFingerprint {
+		return "", false
+	}
+	sidecarFps, err := fingerprintSidecars(sidecars)
+	if err != nil || !sameSidecars(sidecarFps, entry.Sidecars) {
+		return "", false
+	}
+	if _, err := os.Stat(entry.Dest); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.mu.Lock()
+			if current, ok := c.entries[entry.Src]; ok && current.Dest == entry.Dest {
+				delete(c.entries, entry.Src)
+			}
+			c.mu.Unlock()
+		}
+		return "", false
+	}
+	return entry.Dest, true
+}
+
+// rememberHash keeps the hash of src, taken while it had fingerprint fp, for the
+// rest of the run so importing it doesn't read it again. A nil cache forgets it.
+func (c *SourceCache) rememberHash(src string, fp sourceFingerprint, hash string) {
+	if c == nil {
+		return
+	}
+	key := sourceKey(src)
+	c.mu.Lock()
+	c.hashed[key] = sourceCacheEntry{Src: key, sourceFingerprint: fp, Hash: hash}
+	c.mu.Unlock()
+}
+
+// knownHash returns the hash remembered for src this run, if src still has the
+// fingerprint it was hashed with
+func (c *SourceCache) knownHash(src string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	entry, ok := c.hashed[sourceKey(src)]
+	c.mu.Unlock()
+	if !ok {
+		return "", false
+	}
+	if fp, err := fingerprintSource(src); err != nil || fp != entry.sourceFingerprint {
+		return "", false
+	}
+	return entry.Hash, true
+}
+
+// hashSource returns the content hash of src, reusing one taken earlier in the run
+func hashSource(src string, sources *SourceCache) (string, error) {
+	if hash, ok := sources.knownHash(src); ok {
+		return hash, nil
+	}
+	return fileHash(src)
+}
+
+// Record remembers that src, with fingerprint fp and sidecars fingerprinted as
+// sidecars, holds content hash stored at dest
+func (c *SourceCache) Record(src string, fp sourceFingerprint, sidecars map[string]sourceFingerprint, hash, dest string) error {
+	absDest, err := filepath.Abs(dest)
+	if err != nil {
+		return err
+	}
+	entry := sourceCacheEntry{Src: sourceKey(src), sourceFingerprint: fp, Hash: hash, Dest: absDest, Sidecars: sidecars}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if existing, ok := c.entries[entry.Src]; ok && existing.sourceFingerprint == entry.sourceFingerprint &&
+		existing.Hash == entry.Hash && existing.Dest == entry.Dest && sameSidecars(existing.Sidecars, entry.Sidecars) {
+		return nil
+	}
+	c.entries[entry.Src] = entry
+
+	if c.file == nil {
+		return nil
+	}
+	if err := writeSourceEntry(c.file, entry); err != nil {
+		return err
+	}
+	c.lines++
+	return nil
+}
+
+// Close closes the cache file, first rewriting it without superseded or stale lines
+// when it has any
+func (c *SourceCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	if err != nil {
+		return err
+	}
+	if c.lines > len(c.entries) {
+		return c.compactLocked()
+	}
+	return nil
+}
+
+// compactLocked atomically replaces the cache file with the current entries
+func (c *SourceCache) compactLocked() error {
+	tmp := c.path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to compact source cache: %w", err)
+	}
+	for _, entry := range c.entries {
+		if err := writeSourceEntry(out, entry); err != nil {
+			out.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := commitTempFile(out, tmp, c.path); err != nil {
+		return fmt.Errorf("failed to compact source cache: %w", err)
+	}
+	c.lines = len(c.entries)
+	return nil
+}
+
+func writeSourceEntry(f *os.File, entry sourceCacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal source cache entry: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write source cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSourceCache(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	srcDir := filepath.Join(tempDir, "src")
+	os.MkdirAll(srcDir, 0755)
+
+	fileTime := time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC)
+	var files []string
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		path := filepath.Join(srcDir, name)
+		os.WriteFile(path, []byte("photo "+name), 0644)
+		os.Chtimes(path, fileTime, fileTime)
+		files = append(files, path)
+	}
+
+	sources, err := OpenSourceCache(library, false)
+	if err != nil {
+		t.Fatalf("OpenSourceCache failed: %v", err)
+	}
+	cfg := &Config{
+		User:     "user",
+		Library:  library,
+		VideoLib: library,
+		ImageExt: []string{".jpg"},
+		VideoExt: []string{".mp4"},
+	}
+	run := &ImportRun{ScanResult: ScanResult{Files: files}, Sources: sources}
+	for _, f := range files {
+		if err := ProcessFile(f, cfg, run, cfg.User, false, nil, true); err != nil {
+			t.Fatalf("ProcessFile(%s) failed: %v", f, err)
+		}
+	}
+	sources.Close()
+
+	// Reopened, the cache knows all three; then b changes and c's library copy goes away
+	sources, err = OpenSourceCache(library, true)
+	if err != nil {
+		t.Fatalf("OpenSourceCache failed: %v", err)
+	}
+	skip := func() *ImportRun {
+		run := &ImportRun{ScanResult: ScanResult{Files: files}, Sources: sources}
+		run.SkipUnchangedSources()
+		return run
+	}
+	if run := skip(); len(run.Files) != 0 || run.Unchanged != 3 {
+		t.Fatalf("Expected all sources unchanged, got %v pending", run.Files)
+	}
+
+	later := fileTime.Add(time.Hour)
+	os.Chtimes(files[1], later, later)
+	dest, ok := sources.Unchanged(files[2], nil)
+	if !ok {
+		t.Fatal("Expected c.jpg in the source cache")
+	}
+	os.Remove(dest)
+
+	run = skip()
+	pending := run.Files
+	if len(pending) != 2 || pending[0] != files[1] || pending[1] != files[2] || run.Unchanged != 1 {
+		t.Errorf("Expected the touched source and the one missing from the library pending, got %v", pending)
+	}
+}
+
+func TestSourceCache_Sidecars(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	srcDir := filepath.Join(tempDir, "src")
+	os.MkdirAll(srcDir, 0755)
+
+	fileTime := time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC)
+	src := filepath.Join(srcDir, "a.jpg")
+	xmp := filepath.Join(srcDir, "a.xmp")
+	os.WriteFile(src, []byte("photo a"), 0644)
+	os.WriteFile(xmp, []byte("<x:xmpmeta/>"), 0644)
+	for _, path := range []string{src, xmp} {
+		os.Chtimes(path, fileTime, fileTime)
+	}
+
+	sources, err := OpenSourceCache(library, false)
+	if err != nil {
+		t.Fatalf("OpenSourceCache failed: %v", err)
+	}
+	defer sources.Close()
+	cfg := &Config{
+		User:       "user",
+		Library:    library,
+		VideoLib:   library,
+		ImageExt:   []string{".jpg"},
+		VideoExt:   []string{".mp4"},
+		SidecarExt: []string{".xmp"},
+	}
+	rescan := func() *ImportRun {
+		t.Helper()
+		scan, err := ScanMediaFiles(srcDir, cfg)
+		if err != nil {
+			t.Fatalf("ScanMediaFiles failed: %v", err)
+		}
+		run := NewImportRun(scan)
+		run.Sources = sources
+		run.SkipUnchangedSources()
+		return run
+	}
+	run := rescan()
+	for _, f := range run.Files {
+		if err := ProcessFile(f, cfg, run, cfg.User, false, nil, true); err != nil {
+			t.Fatalf("ProcessFile(%s) failed: %v", f, err)
+		}
+	}
+	if pending := rescan().Files; len(pending) != 0 {
+		t.Fatalf("Expected the source and its sidecar unchanged, got %v pending", pending)
+	}
+
+	// An edited sidecar brings its primary back
+	later := fileTime.Add(time.Hour)
+	os.WriteFile(xmp, []byte("<x:xmpmeta rating='5'/>"), 0644)
+	os.Chtimes(xmp, later, later)
+	if pending := rescan().Files; len(pending) != 1 || pending[0] != src {
+		t.Errorf("Expected the primary of an edited sidecar pending, got %v", pending)
+	}
+
+	// So does a sidecar removed since
+	os.Remove(xmp)
+	if pending := rescan().Files; len(pending) != 1 {
+		t.Errorf("Expected the primary of a removed sidecar pending, got %v", pending)
+	}
+}
+
+func TestSourceCache_CompactsOnClose(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	dest := filepath.Join(library, "a.jpg")
+	gone := filepath.Join(library, "b.jpg")
+	os.MkdirAll(library, 0755)
+	os.WriteFile(dest, []byte("photo"), 0644)
+	os.WriteFile(gone, []byte("other photo"), 0644)
+	srcA := filepath.Join(tempDir, "a.jpg")
+	srcB := filepath.Join(tempDir, "b.jpg")
+	os.WriteFile(srcA, []byte("photo"), 0644)
+	os.WriteFile(srcB, []byte("other photo"), 0644)
+
+	sources, err := OpenSourceCache(library, false)
+	if err != nil {
+		t.Fatalf("OpenSourceCache failed: %v", err)
+	}
+	fp, _ := fingerprintSource(srcA)
+	sources.Record(srcA, fp, nil, "h1", dest)
+	sources.Record(srcA, sourceFingerprint{Size: 1}, nil, "h0", dest) // Superseded line
+	sources.Record(srcA, fp, nil, "h1", dest)
+	fpB, _ := fingerprintSource(srcB)
+	sources.Record(srcB, fpB, nil, "h2", gone)
+	os.Remove(gone)
+	if _, ok := sources.Unchanged(srcB, nil); ok {
+		t.Fatal("Expected b.jpg no longer unchanged once its library copy is gone")
+	}
+	if err := sources.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(SourceCachePath(library))
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Errorf("Expected the cache compacted to 1 line, got %d", lines)
+	}
+	sources, _ = OpenSourceCache(library, true)
+	if got, ok := sources.Unchanged(srcA, nil); !ok || got != dest {
+		t.Errorf("Expected a.jpg still known after compaction, got %q (ok=%v)", got, ok)
+	}
+}
@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Google Takeout strips EXIF dates from many files and keeps the capture time in a
+// JSON file next to each one: IMG_1.jpg gets IMG_1.jpg.json (newer exports use
+// IMG_1.jpg.supplemental-metadata.json). Google's naming has a few quirks:
+//   - JSON names are cut to 51 characters, ".json" included
+//   - duplicates put the counter after the extension: IMG_1(1).jpg → IMG_1.jpg(1).json
+//   - edited copies share the original's JSON: IMG_1-edited.jpg → IMG_1.jpg.json
+//   - some exports drop the media extension: IMG_1.json
+
+// takeoutMaxBase is the longest JSON file name Google writes before ".json"
+const takeoutMaxBase = 46
+
+// takeoutSuffixes are appended to the media file name to form the JSON name
+var takeoutSuffixes = []string{"", ".supplemental-metadata"}
+
+// takeoutEditedSuffixes mark edited copies, which have no JSON of their own
+var takeoutEditedSuffixes = []string{"-edited"}
+
+var takeoutCounterPattern = regexp.MustCompile(`^(.*)(\(\d+\))$`)
+
+// ErrNoTakeoutDate is returned when no Takeout JSON with a capture time is found
+var ErrNoTakeoutDate = errors.New("no Google Takeout date found")
+
+// takeoutMetadata is the part of a Takeout JSON sidecar we use
+type takeoutMetadata struct {
+	PhotoTakenTime struct {
+		Timestamp string `json:"timestamp"` // Unix seconds, UTC
+	} `json:"photoTakenTime"`
+}
+
+// takeoutJSONCandidates returns the possible Takeout JSON paths for a media file, most
+// likely first. Works for archive entries too, since only the name changes.
+func takeoutJSONCandidates(mediaPath string) []string {
+	dir := filepath.Dir(mediaPath)
+	name := filepath.Base(mediaPath)
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+
+	counter := ""
+	if m := takeoutCounterPattern.FindStringSubmatch(stem); m != nil {
+		stem, counter = m[1], m[2]
+	}
+	stems := []string{stem}
+	for _, edited := range takeoutEditedSuffixes {
+		if trimmed := strings.TrimSuffix(stem, edited); trimmed != stem && trimmed != "" {
+			stems = append(stems, trimmed)
+		}
+	}
+
+	var candidates []string
+	seen := make(map[string]bool)
+	add := func(base string) {
+		p := filepath.Join(dir, base+".json")
+		if !seen[p] {
+			seen[p] = true
+			candidates = append(candidates, p)
+		}
+	}
+	for _, s := range stems {
+		for _, suffix := range takeoutSuffixes {
+			base := s + ext + suffix
+			add(base + counter)
+			add(truncateRunes(base, takeoutMaxBase) + counter)
+		}
+		add(s + counter)
+	}
+	return candidates
+}
+
+// truncateRunes shortens s to at most n characters
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+// getTakeoutDate returns the photoTakenTime from the Takeout JSON sidecar of mediaPath
+func getTakeoutDate(mediaPath string) (time.Time, error) {
+	for _, candidate := range takeoutJSONCandidates(mediaPath) {
+		if _, _, err := statSource(candidate); err != nil {
+			continue
+		}
+		meta, err := readTakeoutMetadata(candidate)
+		if err != nil {
+			continue
+		}
+		seconds, err := strconv.ParseInt(meta.PhotoTakenTime.Timestamp, 10, 64)
+		if err != nil || seconds <= 0 {
+			continue
+		}
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Time{}, ErrNoTakeoutDate
+}
+
+// readTakeoutMetadata parses a Takeout JSON sidecar
+func readTakeoutMetadata(path string) (*takeoutMetadata, error) {
+	f, err := openSource(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var meta takeoutMetadata
+	if err := json.NewDecoder(f).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &meta, nil
+}
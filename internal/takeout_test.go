@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTakeoutJSONCandidates(t *testing.T) {
+	longStem := strings.Repeat("a", 50)
+	tests := []struct {
+		media string
+		want  string
+	}{
+		{"/t/IMG_1.jpg", "/t/IMG_1.jpg.json"},
+		{"/t/IMG_1.jpg", "/t/IMG_1.jpg.supplemental-metadata.json"},
+		{"/t/IMG_1(1).jpg", "/t/IMG_1.jpg(1).json"},
+		{"/t/IMG_1-edited.jpg", "/t/IMG_1.jpg.json"},
+		{"/t/IMG_1.jpg", "/t/IMG_1.json"},
+		{"/t/" + longStem + ".jpg", "/t/" + longStem[:46] + ".json"},
+		{"/t/PXL_20200101_120000123.jpg", "/t/PXL_20200101_120000123.jpg.supplemental-metada.json"},
+		{"/t/photos.zip!/Takeout/IMG_1.jpg", "/t/photos.zip!/Takeout/IMG_1.jpg.json"},
+	}
+	for _, tt := range tests {
+		found := false
+		for _, candidate := range takeoutJSONCandidates(tt.media) {
+			if candidate == tt.want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("takeoutJSONCandidates(%s) = %v, missing %s", tt.media, takeoutJSONCandidates(tt.media), tt.want)
+		}
+	}
+}
+
+func writeTakeoutJSON(t *testing.T, path string, taken time.Time) {
+	t.Helper()
+	content := fmt.Sprintf(`{"title": "x", "photoTakenTime": {"timestamp": "%d", "formatted": ""}, "geoData": {"latitude": 0.0}}`, taken.Unix())
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcessFile_TakeoutDate(t *testing.T) {
+	tempDir := t.TempDir()
+	library := filepath.Join(tempDir, "library")
+	inputDir := filepath.Join(tempDir, "Takeout", "Google Photos", "Photos from 2019")
+	os.MkdirAll(inputDir, 0755)
+
+	cfg := &Config{
+		User:     "user",
+		Library:  library,
+		VideoLib: library,
+		ImageExt: []string{".jpg"},
+		VideoExt: []string{".mp4"},
+	}
+
+	// Export date as mtime, real capture time in the JSON
+	exported := time.Date(2024, 8, 1, 0, 0, 0, 0, time.Local)
+	taken := time.Date(2019, 7, 14, 10, 30, 0, 0, time.Local)
+
+	src := filepath.Join(inputDir, "beach(1).jpg")
+	os.WriteFile(src, []byte("no exif"), 0644)
+	os.Chtimes(src, exported, exported)
+	writeTakeoutJSON(t, filepath.Join(inputDir, "beach.jpg(1).json"), taken)
+
+	plain := filepath.Join(inputDir, "plain.jpg")
+	os.WriteFile(plain, []byte("no json either"), 0644)
+	os.Chtimes(plain, exported, exported)
+
+	date, confidence, err := getBestFileDate(src, cfg)
+	if err != nil || !date.Equal(taken) || confidence != MEDIUM {
+		t.Fatalf("getBestFileDate = %v, %v, %v; want %v, MEDIUM", date, confidence, err, taken)
+	}
+
+	session, err := NewImportSession(library, library, cfg.User, inputDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	for _, f := range []string{src, plain} {
+		if err := ProcessFile(f, cfg, nil, cfg.User, false, session, true); err != nil {
+			t.Fatalf("ProcessFile(%s) failed: %v", f, err)
+		}
+	}
+	session.Close()
+
+	if _, err := os.Stat(filepath.Join(library, "user", "2019", "07", "14", "beach(1).jpg")); err != nil {
+		t.Errorf("Expected Takeout-dated file in a dated folder: %v", err)
+	}
+
+	events, err := ReadManifest(filepath.Join(session.SessionDir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	sources := make(map[string]string)
+	for _, event := range events {
+		if event.Event == "copied" {
+			sources[filepath.Base(event.Src)] = event.DateSource
+		}
+	}
+	if sources["beach(1).jpg"] != DateSourceTakeout || sources["plain.jpg"] != DateSourceModTime {
+		t.Errorf("Expected date sources takeout and mtime, got %v", sources)
+	}
+}
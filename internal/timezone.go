@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	exif "github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+)
+
+// Capture dates come in two kinds. EXIF DateTimeOriginal and filename dates are wall
+// clock times in whatever zone the camera was set to; they are kept as they read. An
+// EXIF date with OffsetTimeOriginal, a QuickTime CreateDate (always UTC), a Takeout
+// timestamp or a file mtime is an instant, converted to the library timezone so a photo
+// and a video shot the same evening land in the same day folder.
+
+// EXIF 2.3 tags that goexif does not load by itself
+const (
+	exifOffsetTime          exif.FieldName = "OffsetTime"
+	exifOffsetTimeOriginal  exif.FieldName = "OffsetTimeOriginal"
+	exifOffsetTimeDigitized exif.FieldName = "OffsetTimeDigitized"
+	exifBodySerialNumber    exif.FieldName = "BodySerialNumber"
+)
+
+var exifExtraFields = map[uint16]exif.FieldName{
+	0x9010: exifOffsetTime,
+	0x9011: exifOffsetTimeOriginal,
+	0x9012: exifOffsetTimeDigitized,
+	0xa431: exifBodySerialNumber,
+}
+
+// exifDateOffsets pairs each EXIF date field with its offset field
+var exifDateOffsets = map[exif.FieldName]exif.FieldName{
+	exif.DateTimeOriginal:  exifOffsetTimeOriginal,
+	exif.DateTimeDigitized: exifOffsetTimeDigitized,
+	exif.DateTime:          exifOffsetTime,
+}
+
+// quickTimeExtensions are containers whose CreateDate, TrackCreateDate and
+// MediaCreateDate are stored in UTC
+var quickTimeExtensions = map[string]bool{
+	".mov": true, ".mp4": true, ".m4v": true, ".3gp": true, ".3g2": true,
+}
+
+// quickTimeUTCTags are the QuickTime date tags that are UTC by specification
+var quickTimeUTCTags = map[string]bool{
+	"CreateDate": true, "TrackCreateDate": true, "MediaCreateDate": true,
+}
+
+// loadExtraExifTags adds the offset and serial number tags from the EXIF sub-IFD to x
+func loadExtraExifTags(x *exif.Exif) {
+	tag, err := x.Get(exif.ExifIFDPointer)
+	if err != nil {
+		return
+	}
+	offset, err := tag.Int64(0)
+	if err != nil {
+		return
+	}
+	r := bytes.NewReader(x.Raw)
+	if _, err := r.Seek(offset, 0); err != nil {
+		return
+	}
+	dir, _, err := tiff.DecodeDir(r, x.Tiff.Order)
+	if err != nil {
+		return
+	}
+	x.LoadTags(dir, exifExtraFields, false)
+}
+
+// exifDateWithOffset applies the offset tag paired with field (or OffsetTime) to the
+// wall clock t. Reports false when the file records no usable offset.
+func exifDateWithOffset(x *exif.Exif, field exif.FieldName, t time.Time) (time.Time, bool) {
+	for _, offsetField := range []exif.FieldName{exifDateOffsets[field], exifOffsetTime} {
+		tag, err := x.Get(offsetField)
+		if err != nil {
+			continue
+		}
+		value, err := tag.StringVal()
+		if err != nil {
+			continue
+		}
+		if zone, ok := parseUTCOffset(value); ok {
+			return wallClockIn(t, zone), true
+		}
+	}
+	return t, false
+}
+
+// parseUTCOffset parses an EXIF offset such as "+02:00" or "-05:30"
+func parseUTCOffset(value string) (*time.Location, bool) {
+	value = strings.TrimSpace(strings.Trim(value, "\x00"))
+	t, err := time.Parse("-07:00", value)
+	if err != nil {
+		return nil, false
+	}
+	_, seconds := t.Zone()
+	return time.FixedZone(value, seconds), true
+}
+
+// wallClockIn returns the same wall clock time as t in loc
+func wallClockIn(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+// inLibraryZone places a capture date in the library timezone: instants are converted,
+// wall clock dates keep their reading
+func inLibraryZone(t time.Time, instant bool, loc *time.Location) time.Time {
+	if instant {
+		return t.In(loc)
+	}
+	return wallClockIn(t, loc)
+}
+
+// timezones holds the locations loaded so far, by name
+var timezones sync.Map
+
+// loadTimezone returns the location for an IANA name; "" or "Local" is the system timezone
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return time.Local, nil
+	}
+	if loc, ok := timezones.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", name, err)
+	}
+	timezones.Store(name, loc)
+	return loc, nil
+}
+
+// SetTimezone sets the library timezone from an IANA name such as "Europe/Rome";
+// "" or "Local" use the system timezone
+func (c *Config) SetTimezone(name string) error {
+	if name == "" {
+		name = "Local"
+	}
+	if _, err := loadTimezone(name); err != nil {
+		return err
+	}
+	c.Timezone = name
+	return nil
+}
+
+// location returns the library timezone, the system one when unset (SetTimezone
+// rejects names that don't load)
+func (c *Config) location() *time.Location {
+	loc, err := loadTimezone(c.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
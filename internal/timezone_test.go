@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDetectFileDate_LibraryTimezone(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := &Config{ImageExt: []string{".jpg"}, VideoExt: []string{".mp4"}}
+	if err := cfg.SetTimezone("America/New_York"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 23:30 in Rome on June 1st is 17:30 in New York: still June 1st
+	shot := time.Date(2024, 6, 1, 23, 30, 0, 0, time.UTC)
+
+	// Without an offset the camera's wall clock is kept as is
+	plain := filepath.Join(tempDir, "plain.jpg")
+	writeExifJPEG(t, plain, shot)
+	date, err := detectFileDate(plain, cfg, nil)
+	if err != nil {
+		t.Fatalf("detectFileDate failed: %v", err)
+	}
+	if got := date.Time.Format("2006-01-02 15:04"); got != "2024-06-01 23:30" {
+		t.Errorf("Expected wall clock 2024-06-01 23:30, got %s", got)
+	}
+
+	// With OffsetTimeOriginal the date is an instant, converted to the library zone
+	withOffset := filepath.Join(tempDir, "offset.jpg")
+	writeExifJPEGWithOffset(t, withOffset, shot, "+02:00")
+	date, err = detectFileDate(withOffset, cfg, nil)
+	if err != nil {
+		t.Fatalf("detectFileDate failed: %v", err)
+	}
+	if got := date.Time.Format("2006-01-02 15:04 MST"); got != "2024-06-01 17:30 EDT" {
+		t.Errorf("Expected 2024-06-01 17:30 EDT, got %s", got)
+	}
+
+	// File timestamps are instants too: 02:00 UTC is the previous evening in New York
+	mtime := time.Date(2024, 6, 2, 2, 0, 0, 0, time.UTC)
+	noExif := filepath.Join(tempDir, "noexif.jpg")
+	os.WriteFile(noExif, []byte("no exif"), 0644)
+	os.Chtimes(noExif, mtime, mtime)
+	date, err = detectFileDate(noExif, cfg, nil)
+	if err != nil {
+		t.Fatalf("detectFileDate failed: %v", err)
+	}
+	if date.Time.Day() != 1 {
+		t.Errorf("Expected mtime on June 1st in New York, got %s", date.Time)
+	}
+}
+
+func TestParseUTCOffset(t *testing.T) {
+	tests := []struct {
+		value   string
+		seconds int
+		ok      bool
+	}{
+		{"+02:00", 7200, true},
+		{"-05:30\x00", -19800, true},
+		{"   ", 0, false},
+		{"local", 0, false},
+	}
+	for _, tt := range tests {
+		loc, ok := parseUTCOffset(tt.value)
+		if ok != tt.ok {
+			t.Errorf("parseUTCOffset(%q) ok = %v, want %v", tt.value, ok, tt.ok)
+			continue
+		}
+		if ok {
+			if _, seconds := time.Date(2024, 1, 1, 0, 0, 0, 0, loc).Zone(); seconds != tt.seconds {
+				t.Errorf("parseUTCOffset(%q) = %d seconds, want %d", tt.value, seconds, tt.seconds)
+			}
+		}
+	}
+}
+
+func TestSetTimezone(t *testing.T) {
+	cfg := &Config{}
+	if err := cfg.SetTimezone("Mars/Olympus_Mons"); err == nil {
+		t.Error("Expected unknown timezone to be rejected")
+	}
+	if err := cfg.SetTimezone(""); err != nil || cfg.location() != time.Local {
+		t.Errorf("Expected empty timezone to mean the system zone, got %v (%v)", cfg.location(), err)
+	}
+}
@@ -0,0 +1,334 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// UndoResult summarizes what undoing an import session removed (or would remove in dry-run)
+type UndoResult struct {
+	SessionID     string
+	Removed       []string // Library files deleted (or that would be deleted)
+	Restored      []string // Sources deleted by --move and put back at their original path
+	Reinstated    []string // Replaced library files moved back from superseded/ to their path
+	Conflicts     []string // Moved-in or replaced files kept because the path to return to is occupied
+	Modified      []string // Library files kept because their content changed since import
+	Missing       []string // Library files that no longer exist
+	BrowseRemoved int      // Session browse hardlinks removed
+	DirsRemoved   []string // Empty date directories removed
+	DryRun        bool
+}
+
+// UndoImportSession reverses an import by walking its manifest. A destination file is
+// removed only if its current SHA256 still matches the hash recorded at import time,
+// so files edited or replaced since then are never touched. Files imported with --move
+// are the only remaining copy, so they are moved back to their source instead, and
+// sources --move deleted as duplicates are copied back from the library file they
+// duplicate. Library files the import replaced return from superseded/ once their
+// replacement is gone.
+func UndoImportSession(libraryPath, sessionID string, dryRun bool) (*UndoResult, error) {
+	if sessionID == "" || filepath.Base(sessionID) != sessionID {
+		return nil, fmt.Errorf("invalid session id: %q", sessionID)
+	}
+
+	sessionDir := filepath.Join(libraryPath, "imports", sessionID)
+	manifestPath := filepath.Join(sessionDir, "manifest.jsonl")
+
+	events, err := ReadManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UndoResult{SessionID: sessionID, DryRun: dryRun}
+
+	// Directory cleanup stops at each library's user folder, or at the library root
+	// for layouts without one. Files placed by a user rule name their own user.
+	var roots, users []string
+	for _, event := range events {
+		switch {
+		case event.Event == "session_start" && roots == nil:
+			for _, root := range []string{event.LibraryPath, event.VideoLibraryPath} {
+				if root != "" {
+					roots = append(roots, root)
+				}
+			}
+			if event.User != "" {
+				users = append(users, event.User)
+			}
+		case event.UserRule != "" && event.User != "":
+			users = append(users, event.User)
+		}
+	}
+	var stopDirs []string
+	for _, root := range roots {
+		stopDirs = append(stopDirs, root)
+		for _, user := range users {
+			stopDirs = append(stopDirs, filepath.Join(root, user))
+		}
+	}
+
+	// Sources deleted by --move, keyed by source
+	removedSources := make(map[string]ManifestEvent)
+	for _, event := range events {
+		if event.Event == "source_removed" {
+			removedSources[event.Src] = event
+		}
+	}
+
+	// A source deleted as a duplicate of a library file is copied back from it before
+	// any library file is moved back or removed; the library file stays
+	for _, event := range events {
+		if event.Event != "skipped_duplicate" {
+			continue
+		}
+		removal, ok := removedSources[event.Src]
+		if !ok {
+			continue
+		}
+		if _, err := os.Lstat(removal.Src); err == nil {
+			result.Conflicts = append(result.Conflicts, removal.Src)
+			continue
+		}
+		hash, err := fileHash(removal.Dest)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			result.Missing = append(result.Missing, removal.Dest)
+			continue
+		case err != nil:
+			return result, fmt.Errorf("failed to hash %s: %w", removal.Dest, err)
+		case hash != removal.Hash:
+			result.Modified = append(result.Modified, removal.Dest)
+			continue
+		}
+		if dryRun {
+			fmt.Printf("[dry-run] would restore %s from %s\n", removal.Src, removal.Dest)
+		} else if err := restoreDuplicateSource(removal.Dest, removal.Src, removal.Hash); err != nil {
+			return result, fmt.Errorf("failed to restore %s from %s: %w", removal.Src, removal.Dest, err)
+		}
+		result.Restored = append(result.Restored, removal.Src)
+	}
+
+	var cleanupDirs []string
+	for _, event := range events {
+		if event.Event != "copied" && event.Event != "copied_timestamped" && event.Event != "copied_sidecar" && event.Event != "date_sidecar" {
+			continue
+		}
+		if event.Dest == "" {
+			continue
+		}
+
+		hash, err := fileHash(event.Dest)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			result.Missing = append(result.Missing, event.Dest)
+		case err != nil:
+			return result, fmt.Errorf("failed to hash %s: %w", event.Dest, err)
+		case hash != event.Hash:
+			result.Modified = append(result.Modified, event.Dest)
+		case removedSources[event.Src].Dest == event.Dest:
+			src := event.Src
+			if _, err := os.Lstat(src); err == nil {
+				result.Conflicts = append(result.Conflicts, event.Dest)
+				break
+			}
+			if dryRun {
+				fmt.Printf("[dry-run] would restore %s → %s\n", event.Dest, src)
+			} else if err := restoreMovedFile(event.Dest, src); err != nil {
+				return result, fmt.Errorf("failed to restore %s to %s: %w", event.Dest, src, err)
+			}
+			result.Restored = append(result.Restored, src)
+			cleanupDirs = append(cleanupDirs, filepath.Dir(event.Dest))
+		default:
+			if dryRun {
+				fmt.Printf("[dry-run] would remove %s\n", event.Dest)
+			} else if err := os.Remove(event.Dest); err != nil {
+				return result, fmt.Errorf("failed to remove %s: %w", event.Dest, err)
+			}
+			result.Removed = append(result.Removed, event.Dest)
+			cleanupDirs = append(cleanupDirs, filepath.Dir(event.Dest))
+		}
+
+		// The browse hardlink only points at the imported copy; drop it either way
+		if event.Browse != "" {
+			browsePath := filepath.Join(sessionDir, event.Browse)
+			if _, err := os.Lstat(browsePath); err == nil {
+				if dryRun {
+					fmt.Printf("[dry-run] would remove browse link %s\n", browsePath)
+					result.BrowseRemoved++
+				} else if err := os.Remove(browsePath); err == nil {
+					result.BrowseRemoved++
+				}
+			}
+		}
+	}
+
+	// Replacements have been removed above; what they displaced goes back in their place
+	removed := make(map[string]bool)
+	for _, path := range result.Removed {
+		removed[path] = true
+	}
+	for _, event := range events {
+		if event.Event != "conflict" || event.Superseded == "" {
+			continue
+		}
+
+		hash, err := fileHash(event.Superseded)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			result.Missing = append(result.Missing, event.Superseded)
+			continue
+		case err != nil:
+			return result, fmt.Errorf("failed to hash %s: %w", event.Superseded, err)
+		case hash != event.ExistingHash:
+			result.Modified = append(result.Modified, event.Superseded)
+			continue
+		}
+		if _, err := os.Lstat(event.Dest); err == nil && !(dryRun && removed[event.Dest]) {
+			result.Conflicts = append(result.Conflicts, event.Superseded)
+			continue
+		}
+
+		if dryRun {
+			fmt.Printf("[dry-run] would reinstate %s → %s\n", event.Superseded, event.Dest)
+		} else if err := os.Rename(event.Superseded, event.Dest); err != nil {
+			return result, fmt.Errorf("failed to reinstate %s to %s: %w", event.Superseded, event.Dest, err)
+		}
+		result.Reinstated = append(result.Reinstated, event.Dest)
+		cleanupDirs = append(cleanupDirs, filepath.Dir(event.Superseded))
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	for _, dir := range cleanupDirs {
+		result.DirsRemoved = append(result.DirsRemoved, removeEmptyParents(dir, stopDirs)...)
+	}
+
+	event := ManifestEvent{
+		Event:      "undo",
+		Ts:         time.Now().UTC().Format(time.RFC3339),
+		Removed:    len(result.Removed),
+		Restored:   len(result.Restored),
+		Reinstated: len(result.Reinstated),
+		Modified:   len(result.Modified),
+		Missing:    len(result.Missing),
+		SessionDir: sessionDir,
+	}
+	if err := appendManifestEvent(manifestPath, event); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// restoreMovedFile puts a moved-in library file back at its original source path,
+// linking it back when possible and otherwise copying with verification before removal
+func restoreMovedFile(libraryPath, src string) error {
+	if err := os.MkdirAll(filepath.Dir(src), 0755); err != nil {
+		return err
+	}
+
+	// Never restore over a file that took the source's place since
+	err := moveIntoLibrary(libraryPath, src)
+	if err == nil {
+		return os.Remove(libraryPath)
+	}
+	if !errors.Is(err, errLinkUnsupported) {
+		return err
+	}
+	if _, err := os.Lstat(src); err == nil {
+		return fmt.Errorf("%s exists, moved file kept at %s", src, libraryPath)
+	}
+
+	libHash, err := copyFileAtomic(libraryPath, src)
+	if err != nil {
+		return err
+	}
+	srcHash, err := fileHash(src)
+	if err != nil {
+		return err
+	}
+	if libHash != srcHash {
+		_ = os.Remove(src)
+		return fmt.Errorf("hash verification failed after restore %s -> %s", libraryPath, src)
+	}
+	return os.Remove(libraryPath)
+}
+
+// restoreDuplicateSource copies the library file libraryPath back to src, the source
+// --move deleted as its duplicate, and verifies the copy against hash
+func restoreDuplicateSource(libraryPath, src, hash string) error {
+	if err := os.MkdirAll(filepath.Dir(src), 0755); err != nil {
+		return err
+	}
+	copyHash, err := copyFileAtomic(libraryPath, src)
+	if err != nil {
+		return err
+	}
+	if copyHash != hash {
+		_ = os.Remove(src)
+		return fmt.Errorf("hash verification failed after restore %s -> %s", libraryPath, src)
+	}
+	return nil
+}
+
+// removeEmptyParents removes dir and its ancestors while they are empty,
+// stopping at (and never removing) any of stopDirs or anything outside them.
+func removeEmptyParents(dir string, stopDirs []string) []string {
+	var removed []string
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return removed
+	}
+
+	for {
+		inside := false
+		for _, stop := range stopDirs {
+			absStop, err := filepath.Abs(stop)
+			if err != nil {
+				continue
+			}
+			if absDir == absStop {
+				return removed
+			}
+			if strings.HasPrefix(absDir, absStop+string(filepath.Separator)) {
+				inside = true
+			}
+		}
+		if !inside {
+			return removed
+		}
+
+		// os.Remove refuses non-empty directories, which is exactly the check we want
+		if err := os.Remove(absDir); err != nil {
+			return removed
+		}
+		removed = append(removed, absDir)
+		absDir = filepath.Dir(absDir)
+	}
+}
+
+// appendManifestEvent appends a single event to an existing manifest
+func appendManifestEvent(manifestPath string, event ManifestEvent) error {
+	f, err := os.OpenFile(manifestPath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer f.Close()
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write to manifest: %w", err)
+	}
+	return f.Sync()
+}
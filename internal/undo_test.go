@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUndoImportSession(t *testing.T) {
+	tempDir := t.TempDir()
+	inputDir := filepath.Join(tempDir, "input")
+	library := filepath.Join(tempDir, "library")
+	os.MkdirAll(inputDir, 0755)
+
+	cfg := &Config{
+		User:     "user",
+		Library:  library,
+		VideoLib: library,
+		ImageExt: []string{".jpg"},
+		VideoExt: []string{".mp4"},
+	}
+
+	keepTime := time.Date(2024, 1, 10, 8, 0, 0, 0, time.UTC)
+	goneTime := time.Date(2023, 6, 5, 8, 0, 0, 0, time.UTC)
+	keepSrc := filepath.Join(inputDir, "keep.jpg")
+	goneSrc := filepath.Join(inputDir, "gone.jpg")
+	os.WriteFile(keepSrc, []byte("keep"), 0644)
+	os.WriteFile(goneSrc, []byte("gone"), 0644)
+	_ = os.Chtimes(keepSrc, keepTime, keepTime)
+	_ = os.Chtimes(goneSrc, goneTime, goneTime)
+
+	session, err := NewImportSession(library, library, cfg.User, inputDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	session.LogSessionStart(2)
+	for _, src := range []string{keepSrc, goneSrc} {
+		if err := ProcessFile(src, cfg, nil, cfg.User, false, session, true); err != nil {
+			t.Fatalf("ProcessFile failed: %v", err)
+		}
+	}
+	session.Close()
+
+	keepDest := filepath.Join(library, "user", "noexif", "2024-01", "keep.jpg")
+	goneDest := filepath.Join(library, "user", "noexif", "2023-06", "gone.jpg")
+
+	// Edit one library file after import; undo must leave it alone
+	if err := os.WriteFile(keepDest, []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("dry run deletes nothing", func(t *testing.T) {
+		result, err := UndoImportSession(library, session.ID, true)
+		if err != nil {
+			t.Fatalf("UndoImportSession failed: %v", err)
+		}
+		if len(result.Removed) != 1 || result.Removed[0] != goneDest {
+			t.Errorf("Expected %s to be reported for removal, got %v", goneDest, result.Removed)
+		}
+		if _, err := os.Stat(goneDest); err != nil {
+			t.Errorf("Dry run removed %s", goneDest)
+		}
+	})
+
+	t.Run("undo removes unmodified files", func(t *testing.T) {
+		result, err := UndoImportSession(library, session.ID, false)
+		if err != nil {
+			t.Fatalf("UndoImportSession failed: %v", err)
+		}
+
+		if _, err := os.Stat(goneDest); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", goneDest)
+		}
+		if _, err := os.Stat(keepDest); err != nil {
+			t.Errorf("Modified file %s should be kept: %v", keepDest, err)
+		}
+		if len(result.Modified) != 1 || result.Modified[0] != keepDest {
+			t.Errorf("Expected %s reported as modified, got %v", keepDest, result.Modified)
+		}
+		if result.BrowseRemoved != 2 {
+			t.Errorf("Expected 2 browse links removed, got %d", result.BrowseRemoved)
+		}
+
+		// Empty date folder is gone, but the user folder stays
+		if _, err := os.Stat(filepath.Dir(goneDest)); !os.IsNotExist(err) {
+			t.Errorf("Expected empty folder %s to be removed", filepath.Dir(goneDest))
+		}
+		if _, err := os.Stat(filepath.Join(library, "user")); err != nil {
+			t.Errorf("User folder should be kept: %v", err)
+		}
+
+		events, err := ReadManifest(filepath.Join(session.SessionDir, "manifest.jsonl"))
+		if err != nil {
+			t.Fatalf("ReadManifest failed: %v", err)
+		}
+		last := events[len(events)-1]
+		if last.Event != "undo" || last.Removed != 1 || last.Modified != 1 {
+			t.Errorf("Unexpected undo record: %+v", last)
+		}
+	})
+}
+
+func TestUndoImportSession_RestoresMovedFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	inputDir := filepath.Join(tempDir, "input")
+	library := filepath.Join(tempDir, "library")
+	os.MkdirAll(inputDir, 0755)
+
+	cfg := &Config{
+		User:      "user",
+		Library:   library,
+		VideoLib:  library,
+		ImageExt:  []string{".jpg"},
+		VideoExt:  []string{".mp4"},
+		MoveFiles: true,
+	}
+
+	// The second source duplicates the first and is deleted without being imported
+	src := filepath.Join(inputDir, "moved.jpg")
+	dup := filepath.Join(inputDir, "copy", "moved.jpg")
+	os.MkdirAll(filepath.Dir(dup), 0755)
+	mtime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, path := range []string{src, dup} {
+		os.WriteFile(path, []byte("only copy"), 0644)
+		os.Chtimes(path, mtime, mtime)
+	}
+
+	session, err := NewImportSession(library, library, cfg.User, inputDir)
+	if err != nil {
+		t.Fatalf("NewImportSession failed: %v", err)
+	}
+	session.LogSessionStart(2)
+	for _, path := range []string{src, dup} {
+		if err := ProcessFile(path, cfg, nil, cfg.User, false, session, true); err != nil {
+			t.Fatalf("ProcessFile failed: %v", err)
+		}
+	}
+	if removed, _ := session.RemoveQueuedSources(); removed != 2 {
+		t.Fatalf("Expected both sources removed once the import finished, got %d", removed)
+	}
+	session.Close()
+
+	for _, path := range []string{src, dup} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("Expected %s to be removed by the move", path)
+		}
+	}
+
+	result, err := UndoImportSession(library, session.ID, false)
+	if err != nil {
+		t.Fatalf("UndoImportSession failed: %v", err)
+	}
+	if len(result.Restored) != 2 || len(result.Removed) != 0 {
+		t.Errorf("Expected 2 restored and 0 removed, got %+v", result)
+	}
+
+	for _, path := range []string{src, dup} {
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != "only copy" {
+			t.Errorf("Expected %s restored with original content, got %q (%v)", path, data, err)
+		}
+	}
+}
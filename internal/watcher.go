@@ -3,7 +3,6 @@ package internal
 import (
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/fsnotify/fsnotify"
 )
@@ -30,10 +29,11 @@ type Watcher struct {
 	events  chan *WatchEvent
 	errors  chan error
 	done    chan bool
+	cfg     *Config // Decides which files are media
 }
 
 // NewWatcher creates a new filesystem watcher for the given directories
-func NewWatcher(photosDir, videosDir string) (*Watcher, error) {
+func NewWatcher(photosDir, videosDir string, cfg *Config) (*Watcher, error) {
 	fsWatcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
@@ -44,6 +44,7 @@ func NewWatcher(photosDir, videosDir string) (*Watcher, error) {
 		events:  make(chan *WatchEvent, 100),
 		errors:  make(chan error, 10),
 		done:    make(chan bool, 1),
+		cfg:     cfg,
 	}
 
 	// Add directories to watch recursively
@@ -88,7 +89,7 @@ func (w *Watcher) processEvents() {
 			}
 
 			// Only process media files
-			if !isMediaFile(event.Name) {
+			if !isMediaFile(event.Name, w.cfg) {
 				continue
 			}
 
@@ -148,24 +149,6 @@ func (w *Watcher) Close() error {
 }
 
 // isMediaFile checks if a file path represents a media file
-func isMediaFile(path string) bool {
-	ext := strings.ToLower(filepath.Ext(path))
-
-	// Common image extensions
-	imageExts := []string{".jpg", ".jpeg", ".png", ".gif", ".heic", ".tiff", ".cr2", ".nef"}
-	for _, e := range imageExts {
-		if ext == e {
-			return true
-		}
-	}
-
-	// Common video extensions
-	videoExts := []string{".mp4", ".mov", ".avi", ".mkv", ".webm", ".flv", ".wmv", ".m4v"}
-	for _, e := range videoExts {
-		if ext == e {
-			return true
-		}
-	}
-
-	return false
+func isMediaFile(path string, cfg *Config) bool {
+	return determineFileType(path, cfg) != TypeOther
 }